@@ -0,0 +1,594 @@
+// Package badger provides a typed persistent cache based on github.com/dgraph-io/badger
+//
+// Entries are stored on disk and survive process restarts. Expired entries are removed by badger compactions,
+// disk space of overwritten and removed values is reclaimed by the value log GC, see WithValueLogGC
+package badger
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+// Cache represents typed badger database wrapped
+//
+// Keys are prefixed with the base key, so multiple caches can share a database. Safe for concurrent usage
+type Cache[T any] struct {
+	db         *badger.DB
+	ownsDB     bool
+	baseKey    string
+	codec      cache.Codec[T]
+	defaultTTL *time.Duration
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[T]
+
+	stopGC    chan struct{}
+	gcDone    sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewCache creates a Cache instance on top of the opened database with no TTL
+//
+// The database stays owned by the caller and is not closed by Close
+func NewCache[T any](db *badger.DB, baseKey string, codec cache.Codec[T]) (*Cache[T], error) {
+	if db == nil {
+		return nil, errors.New("badger database is nil")
+	}
+
+	return &Cache[T]{
+		db:      db,
+		baseKey: baseKey,
+		codec:   codec,
+		stopGC:  make(chan struct{}),
+	}, nil
+}
+
+// Open opens the database using provided options and creates a Cache instance on top of it with no TTL
+//
+// The database is closed by Close. Compaction is tuned through the options, e.g. badger.Options.WithNumCompactors
+// and badger.Options.WithCompactL0OnClose
+func Open[T any](opts badger.Options, baseKey string, codec cache.Codec[T]) (*Cache[T], error) {
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewCache(db, baseKey, codec)
+	if err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	c.ownsDB = true
+	return c, nil
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
+	c.defaultTTL = &ttl
+	return c
+}
+
+// WithValueLogGC runs the value log GC every interval until Close is called
+//
+// A value log file is rewritten when at least discardRatio of it is occupied by stale values. Every run repeats the
+// GC while it reclaims space. Should be called once
+func (c *Cache[T]) WithValueLogGC(interval time.Duration, discardRatio float64) *Cache[T] {
+	c.gcDone.Add(1)
+	go func() {
+		defer c.gcDone.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stopGC:
+				return
+			case <-ticker.C:
+				_ = c.RunGC(discardRatio)
+			}
+		}
+	}()
+
+	return c
+}
+
+// WithIsolatedFetchErrors stops sharing fetch errors between concurrent GetOrFetch callers of the same key
+//
+// By default callers waiting for a fetch receive its error. When isolated, they run their own fetch instead
+func (c *Cache[T]) WithIsolatedFetchErrors() *Cache[T] {
+	c.flights.IsolateErrors(true)
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// RunGC runs the value log GC until it has nothing to reclaim
+func (c *Cache[T]) RunGC(discardRatio float64) error {
+	for {
+		err := c.db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Compact merges all the LSM tree levels into one using provided number of workers, dropping expired entries
+//
+// Blocks writes of the whole database while running
+func (c *Cache[T]) Compact(workers int) error {
+	return c.db.Flatten(workers)
+}
+
+// Close stops the value log GC and closes the database if it was opened by Open
+func (c *Cache[T]) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stopGC)
+		c.gcDone.Wait()
+
+		if c.ownsDB {
+			err = c.db.Close()
+		}
+	})
+
+	return err
+}
+
+// Clear removes all the entries of the cache
+func (c *Cache[T]) Clear() error {
+	return c.db.DropPrefix(c.prefix())
+}
+
+// Keys returns slice of stored keys
+//
+// Keys are sorted lexicographically
+func (c *Cache[T]) Keys(_ context.Context) ([]string, error) {
+	prefix := c.prefix()
+
+	var keys []string
+	err := c.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().Key()[len(prefix):]))
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
+
+// Len returns the number of stored entries, iterating over their keys
+//
+// Expired entries are skipped by badger
+func (c *Cache[T]) Len(_ context.Context) (int, error) {
+	prefix := c.prefix()
+
+	var n int
+	err := c.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	var value T
+	err := c.db.View(func(txn *badger.Txn) error {
+		var err error
+		value, _, err = c.get(txn, key)
+		return err
+	})
+
+	return value, err
+}
+
+// GetOrFetch tries to obtain cached value from the database. If multiple callers are accessing the same key,
+// later callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.Get(ctx, key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.Get(ctx, key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			err = c.setMulti([]cache.StorageItemMulti[T]{{Key: key, Value: result}}, ttl)
+		}
+
+		return result, err
+	})
+}
+
+// GetOrFetchMulti returns cached values by provided keys, loading all the missing ones with a single fetch call and
+// storing the results. Result slice follows the order of keys and may have fewer items than keys, it means that
+// fetch did not return items by that key
+//
+// Concurrent loads of the same key are deduplicated: keys being loaded by other callers are waited for.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return fetch(ctx, keys)
+	}
+
+	found := make(map[string]T, len(keys))
+	missing := keys
+	if !cache.IsForceRefresh(ctx) {
+		cached, err := c.GetMulti(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range cached {
+			found[item.Key] = item.Value
+		}
+
+		missing = make([]string, 0, len(keys)-len(cached))
+		for _, key := range keys {
+			if _, ok := found[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err := c.flights.DoMulti(ctx, missing, func(ctx context.Context, owned []string) (map[string]T, error) {
+			return c.fetchMulti(ctx, owned, fetch)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(found, loaded)
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, ok := found[key]
+		if !ok {
+			continue
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// Set puts the provided value by cache key
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.setMulti([]cache.StorageItemMulti[T]{{Key: key, Value: value}}, nil)
+}
+
+// GetMulti returns cached values by provided keys, read from a single snapshot of the database.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	err := c.db.View(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			value, _, err := c.get(txn, key)
+			if err != nil {
+				continue
+			}
+
+			res = append(res, cache.StorageItemMulti[T]{
+				Key:   key,
+				Value: value,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetMultiWithTTL returns cached values by provided keys along with their remaining TTL.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMultiWithTTL(ctx context.Context, keys []string) ([]cache.StorageItemMultiWithTTL[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMultiWithTTL[T]{}, nil
+	}
+
+	res := make([]cache.StorageItemMultiWithTTL[T], 0, len(keys))
+	err := c.db.View(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			value, ttl, err := c.get(txn, key)
+			if err != nil {
+				continue
+			}
+
+			res = append(res, cache.StorageItemMultiWithTTL[T]{
+				Key:   key,
+				Value: value,
+				TTL:   ttl,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to cache in a single write batch
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.setMulti(kvs, nil)
+}
+
+// Delete removes cached value by key
+func (c *Cache[T]) Delete(_ context.Context, key string) error {
+	return c.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(c.formatKey(key))
+	})
+}
+
+// DeleteMulti removes cached values by keys in a single write batch
+func (c *Cache[T]) DeleteMulti(_ context.Context, keys []string) error {
+	wb := c.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, key := range keys {
+		if err := wb.Delete(c.formatKey(key)); err != nil {
+			return err
+		}
+	}
+
+	return wb.Flush()
+}
+
+// SetWithTTL puts provided value by cache key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.setMulti([]cache.StorageItemMulti[T]{{Key: key, Value: value}}, &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to cache in a single write batch using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.setMulti(kvs, &ttl)
+}
+
+// get reads the value by key along with its remaining TTL
+func (c *Cache[T]) get(txn *badger.Txn, key string) (T, time.Duration, error) {
+	item, err := txn.Get(c.formatKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return *new(T), 0, cache.NewMissingEntryError(key)
+	}
+
+	if err != nil {
+		return *new(T), 0, err
+	}
+
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return *new(T), 0, err
+	}
+
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		return *new(T), 0, cache.NewFailedToCastEntryError(key, err)
+	}
+
+	ttl := cache.NoExpiration
+	if expiresAt := item.ExpiresAt(); expiresAt != 0 {
+		ttl = time.Until(time.Unix(int64(expiresAt), 0))
+	}
+
+	return value, ttl, nil
+}
+
+func (c *Cache[T]) setMulti(kvs []cache.StorageItemMulti[T], ttl *time.Duration) error {
+	finalTTL := c.defaultTTL
+	if ttl != nil {
+		finalTTL = ttl
+	}
+
+	wb := c.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, kv := range kvs {
+		encoded, err := c.codec.Marshal(kv.Value)
+		if err != nil {
+			return err
+		}
+
+		entry := badger.NewEntry(c.formatKey(kv.Key), encoded)
+		if finalTTL != nil && *finalTTL > 0 {
+			entry = entry.WithTTL(*finalTTL)
+		}
+
+		if err := wb.SetEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return wb.Flush()
+}
+
+func (c *Cache[T]) prefix() []byte {
+	return []byte(c.baseKey + ":")
+}
+
+func (c *Cache[T]) formatKey(key string) []byte {
+	return []byte(c.baseKey + ":" + key)
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
+
+// fetchMulti loads the values of keys claimed by GetOrFetchMulti with a single fetch call and stores them
+func (c *Cache[T]) fetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) (map[string]T, error) {
+	if err := c.fetchRateLimiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	release, err := c.fetchLimiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	items, err := fetch(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.setMulti(items, nil); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(items))
+	for _, item := range items {
+		values[item.Key] = item.Value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,29 @@
+package badger_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/sinu5oid/cache"
+	cachebadger "github.com/sinu5oid/cache/badger"
+	"github.com/sinu5oid/cache/cachetest"
+)
+
+func TestCacher(t *testing.T) {
+	cachetest.RunCacherTests(t, func() cache.TTLCacher[string] {
+		db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+
+		c, err := cachebadger.NewCache[string](db, "test", cache.JSONCodec[string]{})
+		if err != nil {
+			t.Fatalf("NewCache() error = %v", err)
+		}
+
+		return c
+	}, cachetest.TTL[string](2*time.Second)) // badger expires entries with one second granularity
+}
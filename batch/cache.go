@@ -0,0 +1,229 @@
+// Package batch provides a cache wrapper coalescing individual reads and writes into batched backend calls
+//
+// Trades a small latency (the batching window) for fewer round trips to remote backends under high concurrency
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// Cache wraps a cache.Cacher coalescing Get and Set calls arriving within the window into GetMulti and SetMulti calls
+//
+// Get and Set block until the batch they joined is flushed. Other calls are passed to the backend as is.
+// Safe for concurrent usage
+type Cache[T any] struct {
+	backend      cache.Cacher[T]
+	window       time.Duration
+	maxBatchSize int
+
+	mu   sync.Mutex
+	gets *getBatch[T]
+	sets *setBatch[T]
+}
+
+// NewCache creates a Cache flushing batches once the window passes since the first call of the batch
+func NewCache[T any](backend cache.Cacher[T], window time.Duration) *Cache[T] {
+	return &Cache[T]{
+		backend: backend,
+		window:  window,
+	}
+}
+
+// WithMaxBatchSize flushes batches early once they reach the provided number of keys. Zero means no limit
+func (c *Cache[T]) WithMaxBatchSize(size int) *Cache[T] {
+	c.maxBatchSize = size
+	return c
+}
+
+// Get retrieves an item by key as a part of the next GetMulti batch
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return c.backend.Get(ctx, key)
+	}
+
+	b := c.enqueueGet(ctx, key)
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return *new(T), ctx.Err()
+	}
+
+	if b.err != nil {
+		return *new(T), b.err
+	}
+
+	value, ok := b.found[key]
+	if !ok {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return value, nil
+}
+
+// Set puts the provided value by key as a part of the next SetMulti batch
+//
+// Within a batch the last value written by key wins
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return c.backend.Set(ctx, key, value)
+	}
+
+	b := c.enqueueSet(ctx, key, value)
+
+	select {
+	case <-b.done:
+		return b.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetMulti returns cached values by provided keys directly from the backend
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	return c.backend.GetMulti(ctx, keys)
+}
+
+// SetMulti puts provided k/v pairs directly to the backend
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	return c.backend.SetMulti(ctx, kvs)
+}
+
+// Exists reports whether a live entry by key is stored directly in the backend, see cache.Exists
+func (c *Cache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	return cache.Exists(ctx, c.backend, key)
+}
+
+// Delete removes cached value by key directly from the backend
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	return c.backend.Delete(ctx, key)
+}
+
+// DeleteMulti removes cached values by keys from the wrapped cache right away
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.backend.DeleteMulti(ctx, keys)
+}
+
+type getBatch[T any] struct {
+	ctx   context.Context
+	keys  []string
+	seen  map[string]struct{}
+	timer *time.Timer
+
+	done  chan struct{}
+	found map[string]T
+	err   error
+}
+
+type setBatch[T any] struct {
+	ctx    context.Context
+	keys   []string
+	values map[string]T
+	timer  *time.Timer
+
+	done chan struct{}
+	err  error
+}
+
+func (c *Cache[T]) enqueueGet(ctx context.Context, key string) *getBatch[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.gets
+	if b == nil {
+		b = &getBatch[T]{
+			ctx:  context.WithoutCancel(ctx),
+			seen: make(map[string]struct{}),
+			done: make(chan struct{}),
+		}
+		b.timer = time.AfterFunc(c.window, func() { c.flushGets(b) })
+		c.gets = b
+	}
+
+	if _, ok := b.seen[key]; !ok {
+		b.seen[key] = struct{}{}
+		b.keys = append(b.keys, key)
+	}
+
+	if c.maxBatchSize > 0 && len(b.keys) >= c.maxBatchSize {
+		c.gets = nil
+		b.timer.Stop()
+		go c.runGets(b)
+	}
+
+	return b
+}
+
+func (c *Cache[T]) flushGets(b *getBatch[T]) {
+	c.mu.Lock()
+	if c.gets != b {
+		c.mu.Unlock()
+		return // already flushed because of the size limit
+	}
+	c.gets = nil
+	c.mu.Unlock()
+
+	c.runGets(b)
+}
+
+func (c *Cache[T]) runGets(b *getBatch[T]) {
+	items, err := c.backend.GetMulti(b.ctx, b.keys)
+	b.found = cache.AsMap(items)
+	b.err = err
+	close(b.done)
+}
+
+func (c *Cache[T]) enqueueSet(ctx context.Context, key string, value T) *setBatch[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.sets
+	if b == nil {
+		b = &setBatch[T]{
+			ctx:    context.WithoutCancel(ctx),
+			values: make(map[string]T),
+			done:   make(chan struct{}),
+		}
+		b.timer = time.AfterFunc(c.window, func() { c.flushSets(b) })
+		c.sets = b
+	}
+
+	if _, ok := b.values[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.values[key] = value
+
+	if c.maxBatchSize > 0 && len(b.keys) >= c.maxBatchSize {
+		c.sets = nil
+		b.timer.Stop()
+		go c.runSets(b)
+	}
+
+	return b
+}
+
+func (c *Cache[T]) flushSets(b *setBatch[T]) {
+	c.mu.Lock()
+	if c.sets != b {
+		c.mu.Unlock()
+		return // already flushed because of the size limit
+	}
+	c.sets = nil
+	c.mu.Unlock()
+
+	c.runSets(b)
+}
+
+func (c *Cache[T]) runSets(b *setBatch[T]) {
+	kvs := make([]cache.StorageItemMulti[T], 0, len(b.keys))
+	for _, key := range b.keys {
+		kvs = append(kvs, cache.StorageItemMulti[T]{Key: key, Value: b.values[key]})
+	}
+
+	b.err = c.backend.SetMulti(b.ctx, kvs)
+	close(b.done)
+}
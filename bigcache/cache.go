@@ -0,0 +1,471 @@
+// Package bigcache provides a typed in-memory cache based on github.com/allegro/bigcache
+//
+// Entries are serialized into large preallocated byte slices, so the garbage collector does not scan them.
+// Suited for caches holding millions of entries. Values are converted to bytes with a codec
+package bigcache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+// headerSize is the size of the expiration timestamp stored before every value
+const headerSize = 8
+
+// Cache represents typed bigcache wrapped
+//
+// bigcache evicts entries older than the configured LifeWindow. TTLs of entries can only shorten their lifetime,
+// entries never live longer than LifeWindow. Safe for concurrent usage
+type Cache[T any] struct {
+	storage    *bigcache.BigCache
+	codec      cache.Codec[T]
+	defaultTTL *time.Duration
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[T]
+}
+
+// NewCache creates a Cache instance using the provided bigcache config and codec
+func NewCache[T any](config bigcache.Config, codec cache.Codec[T]) (*Cache[T], error) {
+	storage, err := bigcache.New(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache[T]{
+		storage: storage,
+		codec:   codec,
+	}, nil
+}
+
+// NewBytesCache creates a Cache instance storing byte slices as is
+func NewBytesCache(config bigcache.Config) (*Cache[[]byte], error) {
+	return NewCache[[]byte](config, bytesCodec{})
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
+	c.defaultTTL = &ttl
+	return c
+}
+
+// WithIsolatedFetchErrors stops sharing fetch errors between concurrent GetOrFetch callers of the same key
+//
+// By default callers waiting for a fetch receive its error. When isolated, they run their own fetch instead
+func (c *Cache[T]) WithIsolatedFetchErrors() *Cache[T] {
+	c.flights.IsolateErrors(true)
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// Stats returns bigcache hit, miss, delete and collision counters
+func (c *Cache[T]) Stats() bigcache.Stats {
+	return c.storage.Stats()
+}
+
+// Close stops bigcache background cleanup. The cache can not be used afterwards
+func (c *Cache[T]) Close() error {
+	return c.storage.Close()
+}
+
+// Clear removes items from internal storages
+func (c *Cache[T]) Clear() error {
+	return c.storage.Reset()
+}
+
+// Len returns the number of stored entries
+//
+// Entries expired by their TTL are counted until bigcache removes them once LifeWindow passes
+func (c *Cache[T]) Len(_ context.Context) (int, error) {
+	return c.storage.Len(), nil
+}
+
+// Keys returns slice of stored keys
+//
+// The order of keys are not guaranteed. Expired but not yet removed entries are skipped
+func (c *Cache[T]) Keys(_ context.Context) ([]string, error) {
+	now := time.Now()
+	var keys []string
+	for it := c.storage.Iterator(); it.SetNext(); {
+		entry, err := it.Value()
+		if err != nil {
+			continue
+		}
+
+		if _, ok := live(entry.Value(), now); ok {
+			keys = append(keys, entry.Key())
+		}
+	}
+
+	return keys, nil
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return c.get(key)
+}
+
+// GetOrFetch tries to obtain cached value from internal storage. If multiple callers are accessing the same key,
+// later callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.get(key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.get(key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			err = c.set(key, result, ttl)
+		}
+
+		return result, err
+	})
+}
+
+// GetOrFetchMulti returns cached values by provided keys, loading all the missing ones with a single fetch call and
+// storing the results. Result slice follows the order of keys and may have fewer items than keys, it means that
+// fetch did not return items by that key
+//
+// Concurrent loads of the same key are deduplicated: keys being loaded by other callers are waited for.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return fetch(ctx, keys)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	found := make(map[string]T, len(keys))
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !force {
+			value, err := c.get(key)
+			if err == nil {
+				found[key] = value
+				continue
+			}
+
+			if !isMiss(err) {
+				return nil, err
+			}
+		}
+
+		missing = append(missing, key)
+	}
+
+	if len(missing) > 0 {
+		loaded, err := c.flights.DoMulti(ctx, missing, func(ctx context.Context, owned []string) (map[string]T, error) {
+			return c.fetchMulti(ctx, owned, fetch)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(found, loaded)
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, ok := found[key]
+		if !ok {
+			continue
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// Set puts the provided value by cache key to internal storage
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(key, value, nil)
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		val, err := c.get(key)
+		if err != nil {
+			continue
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: val,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to cache
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(kv.Key, kv.Value, nil))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Delete removes cached value from internal storage by key
+func (c *Cache[T]) Delete(_ context.Context, key string) error {
+	err := c.storage.Delete(key)
+	if err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	errs := make([]error, 0, len(keys))
+	for _, key := range keys {
+		errs = append(errs, c.Delete(ctx, key))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetWithTTL puts provided value by cache key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(key, value, &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to cache using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(kv.Key, kv.Value, &ttl))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Cache[T]) get(key string) (T, error) {
+	data, err := c.storage.Get(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	if err != nil {
+		return *new(T), err
+	}
+
+	payload, ok := live(data, time.Now())
+	if !ok {
+		_ = c.storage.Delete(key)
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	value, err := c.codec.Unmarshal(payload)
+	if err != nil {
+		return *new(T), cache.NewFailedToCastEntryError(key, err)
+	}
+
+	return value, nil
+}
+
+func (c *Cache[T]) set(key string, value T, ttl *time.Duration) error {
+	finalTTL := c.defaultTTL
+	if ttl != nil {
+		finalTTL = ttl
+	}
+
+	encoded, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt int64
+	if finalTTL != nil && *finalTTL > 0 {
+		expiresAt = time.Now().Add(*finalTTL).UnixNano()
+	}
+
+	data := make([]byte, headerSize+len(encoded))
+	binary.BigEndian.PutUint64(data, uint64(expiresAt))
+	copy(data[headerSize:], encoded)
+
+	return c.storage.Set(key, data)
+}
+
+// live returns the payload of the stored entry unless it has expired
+func live(data []byte, now time.Time) ([]byte, bool) {
+	if len(data) < headerSize {
+		return nil, false
+	}
+
+	expiresAt := int64(binary.BigEndian.Uint64(data))
+	if expiresAt != 0 && expiresAt <= now.UnixNano() {
+		return nil, false
+	}
+
+	return data[headerSize:], true
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
+
+// fetchMulti loads the values of keys claimed by GetOrFetchMulti with a single fetch call and stores them
+func (c *Cache[T]) fetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) (map[string]T, error) {
+	if err := c.fetchRateLimiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	release, err := c.fetchLimiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	items, err := fetch(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(items))
+	for _, item := range items {
+		if err := c.set(item.Key, item.Value, nil); err != nil {
+			return nil, err
+		}
+		values[item.Key] = item.Value
+	}
+
+	return values, nil
+}
+
+// bytesCodec stores byte slices as is
+type bytesCodec struct{}
+
+func (bytesCodec) Marshal(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (bytesCodec) Unmarshal(data []byte) ([]byte, error) {
+	return data, nil
+}
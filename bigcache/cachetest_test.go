@@ -0,0 +1,23 @@
+package bigcache_test
+
+import (
+	"testing"
+	"time"
+
+	bc "github.com/allegro/bigcache/v3"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/bigcache"
+	"github.com/sinu5oid/cache/cachetest"
+)
+
+func TestCacher(t *testing.T) {
+	cachetest.RunCacherTests(t, func() cache.TTLCacher[string] {
+		c, err := bigcache.NewCache[string](bc.DefaultConfig(time.Hour), cache.JSONCodec[string]{})
+		if err != nil {
+			t.Fatalf("NewCache() error = %v", err)
+		}
+
+		return c
+	})
+}
@@ -0,0 +1,406 @@
+// Package breaker provides a cache wrapper cutting off a failing backend with a circuit breaker
+//
+// While the circuit is open reads miss and writes are skipped without calling the backend, so an outage of a remote
+// backend does not add connection timeouts to every request
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// State is the state of the circuit
+type State int
+
+const (
+	// Closed passes calls to the backend, counting consecutive failures
+	Closed State = iota
+	// Open skips the backend until the cooldown passes
+	Open
+	// HalfOpen passes a single trial call to the backend, closing the circuit if it succeeds
+	HalfOpen
+)
+
+// String returns the state name
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// errPanic is the outcome of backend calls which panicked, passed to the failure check
+var errPanic = errors.New("backend call panicked")
+
+// Cache wraps a cache.Cacher opening the circuit after threshold consecutive backend failures
+//
+// While the circuit is open Get and GetMulti miss, GetOrFetch calls the fetcher without storing its result and
+// writes and deletes are skipped, so entries deleted during an outage may be served once the backend recovers. After
+// the cooldown a single trial call is passed to the backend, closing the circuit if it succeeds and reopening it
+// otherwise. Misses, canceled contexts and errors.ErrUnsupported are not failures, panics are.
+// Safe for concurrent usage if the wrapped cache is
+type Cache[T any] struct {
+	backend   cache.Cacher[T]
+	threshold int
+	cooldown  time.Duration
+	isFailure func(err error) bool
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	listeners []func(from, to State)
+}
+
+// Wrap creates a Cache opening the circuit for the cooldown after threshold consecutive failures
+func Wrap[T any](c cache.Cacher[T], threshold int, cooldown time.Duration) *Cache[T] {
+	return &Cache[T]{
+		backend:   c,
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		isFailure: isFailure,
+	}
+}
+
+// WithFailureCheck assigns the function deciding which backend errors count as failures, replacing the default one
+func (c *Cache[T]) WithFailureCheck(fn func(err error) bool) *Cache[T] {
+	c.isFailure = func(err error) bool {
+		return err != nil && fn(err)
+	}
+	return c
+}
+
+// OnStateChange registers a function called every time the circuit changes its state
+//
+// Called synchronously by the call causing the change, so it must not block or call the Cache
+func (c *Cache[T]) OnStateChange(fn func(from, to State)) *Cache[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.listeners = append(c.listeners, fn)
+	return c
+}
+
+// Unwrap returns the wrapped cache
+func (c *Cache[T]) Unwrap() cache.Cacher[T] {
+	return c.backend
+}
+
+// State returns the current state of the circuit
+func (c *Cache[T]) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+// Degraded reports whether the circuit is not closed
+func (c *Cache[T]) Degraded() bool {
+	return c.State() != Closed
+}
+
+// Get retrieves an item by key, missing while the circuit is open
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	probe, ok := c.allow()
+	if !ok {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+	defer c.recoverProbe(probe)
+
+	value, err := c.backend.Get(ctx, key)
+	c.done(probe, err)
+
+	return value, err
+}
+
+// Set puts the provided value by key, skipped while the circuit is open
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.call(func() error {
+		return c.backend.Set(ctx, key, value)
+	})
+}
+
+// GetMulti returns cached values by provided keys, none while the circuit is open.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	probe, ok := c.allow()
+	if !ok {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+	defer c.recoverProbe(probe)
+
+	items, err := c.backend.GetMulti(ctx, keys)
+	c.done(probe, err)
+
+	return items, err
+}
+
+// SetMulti puts provided k/v pairs, skipped while the circuit is open
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	return c.call(func() error {
+		return c.backend.SetMulti(ctx, kvs)
+	})
+}
+
+// Delete removes cached value by key, skipped while the circuit is open
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	return c.call(func() error {
+		return c.backend.Delete(ctx, key)
+	})
+}
+
+// DeleteMulti removes cached values by keys, skipped while the circuit is open
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.call(func() error {
+		return c.backend.DeleteMulti(ctx, keys)
+	})
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration, skipped while the circuit is open
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return c.call(func() error {
+		return ttlCacher.SetWithTTL(ctx, key, value, ttl)
+	})
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration, skipped while the circuit is open
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return c.call(func() error {
+		return ttlCacher.SetMultiWithTTL(ctx, kvs, ttl)
+	})
+}
+
+// GetOrFetch obtains the value by key, calling the fetcher and storing its result if the value was not found
+//
+// Uses the wrapped cache GetOrFetch if it implements cache.FetchingCacher, otherwise calls Get, then the fetcher and
+// Set. While the circuit is open calls the fetcher without storing its result. Fetcher errors are not failures
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache implements neither cache.FetchingCacher nor cache.TTLCacher
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	probe, ok := c.allow()
+	if !ok {
+		return fetcher(ctx)
+	}
+	defer c.recoverProbe(probe)
+
+	var fetchErr error
+	tracked := func(ctx context.Context) (T, error) {
+		value, err := fetcher(ctx)
+		fetchErr = err
+		return value, err
+	}
+
+	var (
+		value T
+		err   error
+	)
+	switch fetching, ok := c.backend.(cache.FetchingCacher[T]); {
+	case ok && ttl != nil:
+		value, err = fetching.GetOrFetchWithTTL(ctx, key, *ttl, tracked)
+	case ok:
+		value, err = fetching.GetOrFetch(ctx, key, tracked)
+	default:
+		value, err = c.fetchThrough(ctx, key, tracked, ttl)
+	}
+
+	if fetchErr != nil && errors.Is(err, fetchErr) {
+		// the backend served the call, the fetcher failed
+		c.done(probe, nil)
+	} else {
+		c.done(probe, err)
+	}
+
+	return value, err
+}
+
+// fetchThrough implements GetOrFetch for caches not implementing cache.FetchingCacher
+func (c *Cache[T]) fetchThrough(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if !cache.IsForceRefresh(ctx) {
+		value, err := c.backend.Get(ctx, key)
+		if !isMiss(err) {
+			return value, err
+		}
+	}
+
+	value, err := fetcher(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	if ttl == nil {
+		return value, c.backend.Set(ctx, key, value)
+	}
+
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		return value, fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return value, ttlCacher.SetWithTTL(ctx, key, value, *ttl)
+}
+
+// call runs the backend call unless the circuit is open
+func (c *Cache[T]) call(fn func() error) error {
+	probe, ok := c.allow()
+	if !ok {
+		return nil
+	}
+	defer c.recoverProbe(probe)
+
+	err := fn()
+	c.done(probe, err)
+
+	return err
+}
+
+// allow reports whether a call may be passed to the backend and whether it is the trial call of a half-open circuit
+func (c *Cache[T]) allow() (probe bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case Open:
+		if time.Since(c.openedAt) < c.cooldown {
+			return false, false
+		}
+		c.transition(HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if c.probing {
+			return false, false
+		}
+		c.probing = true
+		return true, true
+	default:
+		return false, true
+	}
+}
+
+// done records the outcome of a call passed to the backend
+func (c *Cache[T]) done(probe bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	failed := c.isFailure(err)
+
+	switch c.state {
+	case Closed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+
+		c.failures++
+		if c.failures >= c.threshold {
+			c.open()
+		}
+	case HalfOpen:
+		if !probe {
+			return
+		}
+
+		c.probing = false
+		switch {
+		case failed:
+			c.open()
+		case err == nil || isMiss(err):
+			c.failures = 0
+			c.transition(Closed)
+		}
+		// other errors, e.g. a canceled context, tell nothing about the backend, so the next call is a trial
+	}
+}
+
+// recoverProbe records a panic of the call passed to the backend as errPanic and panics again, so the trial call of
+// a half-open circuit is not held forever
+func (c *Cache[T]) recoverProbe(probe bool) {
+	if r := recover(); r != nil {
+		c.done(probe, errPanic)
+		panic(r)
+	}
+}
+
+func (c *Cache[T]) open() {
+	c.openedAt = time.Now()
+	c.transition(Open)
+}
+
+func (c *Cache[T]) transition(to State) {
+	from := c.state
+	if from == to {
+		return
+	}
+
+	c.state = to
+	for _, fn := range c.listeners {
+		fn(from, to)
+	}
+}
+
+// isFailure is the default failure check
+func isFailure(err error) bool {
+	return err != nil &&
+		!isMiss(err) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, errors.ErrUnsupported)
+}
+
+// isMiss reports whether the read failed because the entry is absent
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
@@ -0,0 +1,88 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/breaker"
+	"github.com/sinu5oid/cache/inmem"
+)
+
+var errBackend = errors.New("backend failed")
+
+// flakyBackend fails or panics on Get while the corresponding flag is set
+type flakyBackend struct {
+	*inmem.Cache[int]
+
+	fail   bool
+	panics bool
+}
+
+func (b *flakyBackend) Get(ctx context.Context, key string) (int, error) {
+	if b.panics {
+		panic("backend panicked")
+	}
+
+	if b.fail {
+		return 0, errBackend
+	}
+
+	return b.Cache.Get(ctx, key)
+}
+
+func TestProbePanicReleasesCircuit(t *testing.T) {
+	ctx := context.Background()
+	backend := &flakyBackend{Cache: inmem.New[int](), fail: true}
+	c := breaker.Wrap[int](backend, 1, time.Millisecond)
+
+	if err := backend.Cache.Set(ctx, "key", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if _, err := c.Get(ctx, "key"); !errors.Is(err, errBackend) {
+		t.Fatalf("Get() error = %v, want %v", err, errBackend)
+	}
+
+	if state := c.State(); state != breaker.Open {
+		t.Fatalf("State() = %v, want %v", state, breaker.Open)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	backend.panics = true
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Get() of the trial call did not panic")
+			}
+		}()
+		_, _ = c.Get(ctx, "key")
+	}()
+
+	backend.panics, backend.fail = false, false
+	time.Sleep(2 * time.Millisecond)
+
+	if got, err := c.Get(ctx, "key"); err != nil || got != 1 {
+		t.Errorf("Get() after the panicked trial call = %v, %v, want 1", got, err)
+	}
+
+	if state := c.State(); state != breaker.Closed {
+		t.Errorf("State() = %v, want %v", state, breaker.Closed)
+	}
+}
+
+func TestMissIsNotFailure(t *testing.T) {
+	ctx := context.Background()
+	c := breaker.Wrap[int](inmem.New[int](), 1, time.Minute)
+
+	var missingEntryError cache.MissingEntryError
+	if _, err := c.Get(ctx, "missing"); !errors.As(err, &missingEntryError) {
+		t.Fatalf("Get() error = %v, want cache.MissingEntryError", err)
+	}
+
+	if state := c.State(); state != breaker.Closed {
+		t.Errorf("State() after a miss = %v, want %v", state, breaker.Closed)
+	}
+}
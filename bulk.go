@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// BulkLoader yields batches of entries to be loaded into a cache
+//
+// Next returns io.EOF once all the batches were yielded. Next is never called concurrently
+type BulkLoader[T any] interface {
+	Next(ctx context.Context) ([]StorageItemMulti[T], error)
+}
+
+// BulkLoaderFunc adapts a function to the BulkLoader interface
+type BulkLoaderFunc[T any] func(ctx context.Context) ([]StorageItemMulti[T], error)
+
+// Next calls f(ctx)
+func (f BulkLoaderFunc[T]) Next(ctx context.Context) ([]StorageItemMulti[T], error) {
+	return f(ctx)
+}
+
+// LoadProgress reports the amount of data written by LoadAll so far
+type LoadProgress struct {
+	Batches int
+	Items   int
+}
+
+// LoadOptions controls LoadAll behavior
+type LoadOptions struct {
+	// Concurrency is the number of batches written simultaneously. Defaults to 1
+	Concurrency int
+	// BatchesPerSecond limits the rate of batch writes. Zero means no limit
+	BatchesPerSecond float64
+	// TTL is applied to loaded entries when the cache implements TTLCacher. Nil uses the cache default TTL
+	TTL *time.Duration
+	// Progress is called after every written batch. Calls are serialized
+	Progress func(LoadProgress)
+}
+
+// LoadAll reads every batch from loader and writes it to the cache
+//
+// Stops at the first loader or cache error and returns it along with the progress made
+func LoadAll[T any](ctx context.Context, c Cacher[T], loader BulkLoader[T], opts LoadOptions) (LoadProgress, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		mu       sync.Mutex
+		progress LoadProgress
+		wg       sync.WaitGroup
+	)
+
+	batches := make(chan []StorageItemMulti[T])
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := writeBatch(ctx, c, batch, opts.TTL); err != nil {
+					cancel(fmt.Errorf("could not write batch: %w", err))
+					return
+				}
+
+				mu.Lock()
+				progress.Batches++
+				progress.Items += len(batch)
+				if opts.Progress != nil {
+					opts.Progress(progress)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if opts.BatchesPerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.BatchesPerSecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	readErr := readBatches(ctx, loader, batches, tick)
+	close(batches)
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return progress, err
+	}
+
+	if readErr != nil {
+		return progress, readErr
+	}
+
+	return progress, context.Cause(ctx)
+}
+
+func readBatches[T any](
+	ctx context.Context,
+	loader BulkLoader[T],
+	batches chan<- []StorageItemMulti[T],
+	tick <-chan time.Time,
+) error {
+	for {
+		batch, err := loader.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not read batch: %w", err)
+		}
+
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func writeBatch[T any](ctx context.Context, c Cacher[T], batch []StorageItemMulti[T], ttl *time.Duration) error {
+	if ttl != nil {
+		if ttlCacher, ok := c.(TTLCacher[T]); ok {
+			return ttlCacher.SetMultiWithTTL(ctx, batch, *ttl)
+		}
+	}
+
+	return c.SetMulti(ctx, batch)
+}
@@ -0,0 +1,389 @@
+// Package cachetest provides a conformance test suite for cache.Cacher implementations
+//
+// Backends, built-in and third-party, call RunCacherTests from their tests to verify they follow the interface
+// contracts.
+//
+// Built-in backends run the suite: inmem, lru, bigcache, badger, fscache, and redis against miniredis without the
+// expiration tests, as miniredis does not expire keys in real time. The suite can not exercise:
+//   - ristretto: writes are applied asynchronously, so reads right after writes may miss
+//   - groupcache: entries are immutable, writes fail
+//   - memcached, dynamo, s3cache and natskv: they need external services with no in-process replacement among the
+//     dependencies
+package cachetest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// defaultTTL is the TTL expiration is tested with unless configured with TTL
+const defaultTTL = 100 * time.Millisecond
+
+// Option configures RunCacherTests
+type Option[T any] func(*suite[T])
+
+// Values assigns the function generating distinct values stored by the tests. Required unless T is an integer, a
+// float, a string or a byte slice
+func Values[T any](gen func(i int) T) Option[T] {
+	return func(s *suite[T]) {
+		s.value = gen
+	}
+}
+
+// TTL assigns the TTL expiration is tested with, 100ms by default. The tests wait for triple the TTL, so backends with
+// coarse expiration, e.g. one second granularity, need a larger one. Zero skips expiration tests
+func TTL[T any](ttl time.Duration) Option[T] {
+	return func(s *suite[T]) {
+		s.ttl = ttl
+	}
+}
+
+type suite[T any] struct {
+	factory func() cache.TTLCacher[T]
+	value   func(i int) T
+	ttl     time.Duration
+	prefix  string
+}
+
+// RunCacherTests runs the conformance tests as subtests of t, creating a new cache with factory for every subtest
+//
+// Keys are prefixed with a random run ID, so caches sharing a backend, e.g. a redis server, may be used. Caches
+// implementing Close() are closed after their subtest
+func RunCacherTests[T any](t *testing.T, factory func() cache.TTLCacher[T], opts ...Option[T]) {
+	t.Helper()
+
+	s := &suite[T]{
+		factory: factory,
+		ttl:     defaultTTL,
+		prefix:  runID(t),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.value == nil {
+		gen, ok := defaultValues[T]()
+		if !ok {
+			t.Fatalf("cachetest: values of type %T can not be generated, provide them with Values", *new(T))
+		}
+		s.value = gen
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c cache.TTLCacher[T])
+	}{
+		{"GetMissing", s.testGetMissing},
+		{"SetGet", s.testSetGet},
+		{"Overwrite", s.testOverwrite},
+		{"Delete", s.testDelete},
+		{"GetMulti", s.testGetMulti},
+		{"SetMulti", s.testSetMulti},
+		{"DeleteMulti", s.testDeleteMulti},
+		{"SetWithTTL", s.testSetWithTTL},
+		{"SetMultiWithTTL", s.testSetMultiWithTTL},
+		{"Concurrency", s.testConcurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := s.factory()
+			if closer, ok := c.(interface{ Close() }); ok {
+				t.Cleanup(closer.Close)
+			}
+
+			tt.run(t, c)
+		})
+	}
+}
+
+func (s *suite[T]) testGetMissing(t *testing.T, c cache.TTLCacher[T]) {
+	_, err := c.Get(context.Background(), s.key(t, 0))
+	requireMissing(t, err)
+}
+
+func (s *suite[T]) testSetGet(t *testing.T, c cache.TTLCacher[T]) {
+	ctx := context.Background()
+
+	requireNoError(t, c.Set(ctx, s.key(t, 0), s.value(0)))
+	s.requireValue(t, c, 0, 0)
+}
+
+func (s *suite[T]) testOverwrite(t *testing.T, c cache.TTLCacher[T]) {
+	ctx := context.Background()
+
+	requireNoError(t, c.Set(ctx, s.key(t, 0), s.value(0)))
+	requireNoError(t, c.Set(ctx, s.key(t, 0), s.value(1)))
+	s.requireValue(t, c, 0, 1)
+}
+
+func (s *suite[T]) testDelete(t *testing.T, c cache.TTLCacher[T]) {
+	ctx := context.Background()
+
+	requireNoError(t, c.Set(ctx, s.key(t, 0), s.value(0)))
+	requireNoError(t, c.Set(ctx, s.key(t, 1), s.value(1)))
+	requireNoError(t, c.Delete(ctx, s.key(t, 0)))
+
+	_, err := c.Get(ctx, s.key(t, 0))
+	requireMissing(t, err)
+	s.requireValue(t, c, 1, 1)
+
+	if err := c.Delete(ctx, s.key(t, 2)); err != nil {
+		t.Fatalf("deleting a missing key failed: %v", err)
+	}
+}
+
+func (s *suite[T]) testGetMulti(t *testing.T, c cache.TTLCacher[T]) {
+	ctx := context.Background()
+
+	requireNoError(t, c.Set(ctx, s.key(t, 0), s.value(0)))
+	requireNoError(t, c.Set(ctx, s.key(t, 2), s.value(2)))
+
+	items, err := c.GetMulti(ctx, []string{s.key(t, 0), s.key(t, 1), s.key(t, 2)})
+	requireNoError(t, err)
+	s.requireItems(t, items, 0, 2)
+
+	items, err = c.GetMulti(ctx, nil)
+	requireNoError(t, err)
+	if len(items) != 0 {
+		t.Fatalf("GetMulti without keys returned %d items", len(items))
+	}
+}
+
+func (s *suite[T]) testSetMulti(t *testing.T, c cache.TTLCacher[T]) {
+	ctx := context.Background()
+
+	requireNoError(t, c.SetMulti(ctx, s.items(t, 0, 1, 2)))
+
+	items, err := c.GetMulti(ctx, []string{s.key(t, 0), s.key(t, 1), s.key(t, 2)})
+	requireNoError(t, err)
+	s.requireItems(t, items, 0, 1, 2)
+
+	requireNoError(t, c.SetMulti(ctx, nil))
+}
+
+func (s *suite[T]) testDeleteMulti(t *testing.T, c cache.TTLCacher[T]) {
+	ctx := context.Background()
+
+	requireNoError(t, c.SetMulti(ctx, s.items(t, 0, 1, 2)))
+	requireNoError(t, c.DeleteMulti(ctx, []string{s.key(t, 0), s.key(t, 2), s.key(t, 3)}))
+
+	items, err := c.GetMulti(ctx, []string{s.key(t, 0), s.key(t, 1), s.key(t, 2)})
+	requireNoError(t, err)
+	s.requireItems(t, items, 1)
+
+	requireNoError(t, c.DeleteMulti(ctx, nil))
+}
+
+func (s *suite[T]) testSetWithTTL(t *testing.T, c cache.TTLCacher[T]) {
+	if s.ttl <= 0 {
+		t.Skip("expiration tests are disabled")
+	}
+
+	ctx := context.Background()
+
+	requireNoError(t, c.SetWithTTL(ctx, s.key(t, 0), s.value(0), s.ttl))
+	requireNoError(t, c.SetWithTTL(ctx, s.key(t, 1), s.value(1), time.Hour))
+	s.requireValue(t, c, 0, 0)
+
+	time.Sleep(3 * s.ttl)
+
+	_, err := c.Get(ctx, s.key(t, 0))
+	requireMissing(t, err)
+	s.requireValue(t, c, 1, 1)
+}
+
+func (s *suite[T]) testSetMultiWithTTL(t *testing.T, c cache.TTLCacher[T]) {
+	if s.ttl <= 0 {
+		t.Skip("expiration tests are disabled")
+	}
+
+	ctx := context.Background()
+
+	requireNoError(t, c.SetMultiWithTTL(ctx, s.items(t, 0, 1), s.ttl))
+
+	items, err := c.GetMulti(ctx, []string{s.key(t, 0), s.key(t, 1)})
+	requireNoError(t, err)
+	s.requireItems(t, items, 0, 1)
+
+	time.Sleep(3 * s.ttl)
+
+	items, err = c.GetMulti(ctx, []string{s.key(t, 0), s.key(t, 1)})
+	requireNoError(t, err)
+	s.requireItems(t, items)
+}
+
+func (s *suite[T]) testConcurrency(t *testing.T, c cache.TTLCacher[T]) {
+	const (
+		workers    = 8
+		iterations = 100
+	)
+
+	ctx := context.Background()
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, workers)
+	)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range iterations {
+				// every worker owns a key and shares another one with the rest
+				own, shared := s.key(t, workers+w), s.key(t, i%2)
+
+				if err := c.Set(ctx, own, s.value(i)); err != nil {
+					errs <- fmt.Errorf("Set: %w", err)
+					return
+				}
+
+				if err := c.Set(ctx, shared, s.value(w)); err != nil {
+					errs <- fmt.Errorf("Set: %w", err)
+					return
+				}
+
+				value, err := c.Get(ctx, own)
+				if err != nil {
+					errs <- fmt.Errorf("Get: %w", err)
+					return
+				}
+
+				if !reflect.DeepEqual(value, s.value(i)) {
+					errs <- fmt.Errorf("Get returned %v, want %v", value, s.value(i))
+					return
+				}
+
+				if _, err := c.Get(ctx, shared); err != nil && !isMissing(err) {
+					errs <- fmt.Errorf("Get: %w", err)
+					return
+				}
+
+				if err := c.Delete(ctx, shared); err != nil {
+					errs <- fmt.Errorf("Delete: %w", err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func (s *suite[T]) key(t *testing.T, i int) string {
+	return fmt.Sprintf("%s:%s:%d", s.prefix, t.Name(), i)
+}
+
+func (s *suite[T]) items(t *testing.T, indexes ...int) []cache.StorageItemMulti[T] {
+	items := make([]cache.StorageItemMulti[T], 0, len(indexes))
+	for _, i := range indexes {
+		items = append(items, cache.StorageItemMulti[T]{
+			Key:   s.key(t, i),
+			Value: s.value(i),
+		})
+	}
+
+	return items
+}
+
+// requireValue checks that the value by the key of index keyIndex is the one of index valueIndex
+func (s *suite[T]) requireValue(t *testing.T, c cache.Cacher[T], keyIndex, valueIndex int) {
+	t.Helper()
+
+	value, err := c.Get(context.Background(), s.key(t, keyIndex))
+	if err != nil {
+		t.Fatalf("Get(%s) failed: %v", s.key(t, keyIndex), err)
+	}
+
+	if want := s.value(valueIndex); !reflect.DeepEqual(value, want) {
+		t.Fatalf("Get(%s) returned %v, want %v", s.key(t, keyIndex), value, want)
+	}
+}
+
+// requireItems checks that items hold exactly the entries of the indexes, in any order
+func (s *suite[T]) requireItems(t *testing.T, items []cache.StorageItemMulti[T], indexes ...int) {
+	t.Helper()
+
+	got := cache.AsMap(items)
+	if len(got) != len(items) {
+		t.Fatalf("GetMulti returned duplicate keys: %v", items)
+	}
+
+	want := cache.AsMap(s.items(t, indexes...))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetMulti returned %v, want %v", got, want)
+	}
+}
+
+func requireNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func requireMissing(t *testing.T, err error) {
+	t.Helper()
+
+	if !isMissing(err) {
+		t.Fatalf("expected cache.MissingEntryError, got %v", err)
+	}
+}
+
+func isMissing(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
+
+func runID(t *testing.T) string {
+	raw := make([]byte, 4)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatalf("cachetest: could not generate run ID: %v", err)
+	}
+
+	return hex.EncodeToString(raw)
+}
+
+// defaultValues returns a generator of distinct values for basic types
+func defaultValues[T any]() (func(i int) T, bool) {
+	typ := reflect.TypeFor[T]()
+
+	var convert func(i int) reflect.Value
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		convert = func(i int) reflect.Value { return reflect.ValueOf(int64(i + 1)) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		convert = func(i int) reflect.Value { return reflect.ValueOf(uint64(i + 1)) }
+	case reflect.Float32, reflect.Float64:
+		convert = func(i int) reflect.Value { return reflect.ValueOf(float64(i) + 0.5) }
+	case reflect.String:
+		convert = func(i int) reflect.Value { return reflect.ValueOf(fmt.Sprintf("value %d", i)) }
+	case reflect.Slice:
+		if typ.Elem().Kind() != reflect.Uint8 {
+			return nil, false
+		}
+		convert = func(i int) reflect.Value { return reflect.ValueOf([]byte(fmt.Sprintf("value %d", i))) }
+	default:
+		return nil, false
+	}
+
+	return func(i int) T {
+		return convert(i).Convert(typ).Interface().(T)
+	}, true
+}
@@ -0,0 +1,446 @@
+// Command cachectl inspects and modifies entries of a redis cache created with this package
+//
+// Usage:
+//
+//	cachectl [flags] <command> [arguments]
+//
+// Commands:
+//
+//	get KEY          print the value by key
+//	set KEY VALUE    put the JSON value by key
+//	del KEY          remove the value by key
+//	keys [PATTERN]   list keys matching the glob-style pattern, all keys by default
+//	stats            print the number of keys and the redis hit ratio
+//	export [FILE]    write all the entries as JSON lines to the file or stdout
+//	import [FILE]    restore entries written by export from the file or stdin
+//	warm FILE        put {"key": ..., "value": ...} JSON lines from the file
+//
+// Values are decoded as msgpack written by go-redis/cache by default, use -raw for values written with a codec.
+// Keys printed by keys and in errors are redacted with -redact hash or -redact all, export writes them as is for import
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	rc "github.com/go-redis/cache/v9"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/redis"
+)
+
+type config struct {
+	dsn         string
+	prefix      string
+	ttl         time.Duration
+	timeout     time.Duration
+	raw         bool
+	concurrency int
+	redactor    cache.KeyRedactor
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.dsn, "redis", "redis://localhost:6379/0", "redis connection URL")
+	flag.StringVar(&cfg.prefix, "prefix", "", "base key the cache was created with")
+	flag.DurationVar(&cfg.ttl, "ttl", 0, "TTL of written entries, the go-redis/cache default if zero")
+	flag.DurationVar(&cfg.timeout, "timeout", 5*time.Second, "timeout of every redis command")
+	flag.BoolVar(&cfg.raw, "raw", false, "treat values as raw strings instead of msgpack")
+	flag.IntVar(&cfg.concurrency, "concurrency", 4, "number of concurrent writers used by warm")
+	flag.Func("redact", "redact printed keys: hash or all", func(mode string) error {
+		switch mode {
+		case "hash":
+			cfg.redactor = cache.RedactHash
+		case "all":
+			cfg.redactor = cache.RedactAll
+		default:
+			return fmt.Errorf("unknown redaction %s", mode)
+		}
+
+		return nil
+	})
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "usage: cachectl [flags] get|set|del|keys|stats|export|import|warm [arguments]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || cfg.prefix == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), cfg, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "cachectl:", cfg.redactor.RedactError(err, flag.Args()[1:]...))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg config, command string, args []string) error {
+	opts, err := goredis.ParseURL(cfg.dsn)
+	if err != nil {
+		return fmt.Errorf("could not parse redis URL: %w", err)
+	}
+
+	client := goredis.NewClient(opts)
+	defer client.Close()
+
+	c := ctl{cfg: cfg, client: client, storage: rc.New(&rc.Options{Redis: client})}
+
+	switch command {
+	case "get":
+		return c.get(ctx, args)
+	case "set":
+		return c.set(ctx, args)
+	case "del":
+		return c.del(ctx, args)
+	case "keys":
+		return c.keys(ctx, args)
+	case "stats":
+		return c.stats(ctx)
+	case "export":
+		return c.export(ctx, args)
+	case "import":
+		return c.restore(ctx, args)
+	case "warm":
+		return c.warm(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %s", command)
+	}
+}
+
+type ctl struct {
+	cfg     config
+	client  *goredis.Client
+	storage *rc.Cache
+}
+
+// entry is a line of export and import files. Value holds the stored bytes as is
+type entry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	TTL   int64  `json:"ttl_ms,omitempty"`
+}
+
+func (c ctl) cache() (*redis.Cache[any], error) {
+	rcache, err := redis.NewCache[any](c.storage, c.cfg.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cfg.raw {
+		rcache = rcache.WithCodec(rawCodec{})
+	} else {
+		rcache = rcache.WithCodec(msgpackCodec{storage: c.storage})
+	}
+
+	return rcache.WithClient(c.client).WithOperationTimeout(c.cfg.timeout), nil
+}
+
+func (c ctl) get(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get KEY")
+	}
+
+	rcache, err := c.cache()
+	if err != nil {
+		return err
+	}
+
+	value, err := rcache.Get(ctx, args[0])
+	var castErr cache.FailedToCastEntryError
+	if errors.As(err, &castErr) && !c.cfg.raw {
+		return fmt.Errorf("%w, use -raw for values written with a codec", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printJSON(value)
+}
+
+func (c ctl) set(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: set KEY VALUE")
+	}
+
+	rcache, err := c.cache()
+	if err != nil {
+		return err
+	}
+
+	value, err := c.parse(args[1])
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.ttl != 0 {
+		return rcache.SetWithTTL(ctx, args[0], value, c.cfg.ttl)
+	}
+
+	return rcache.Set(ctx, args[0], value)
+}
+
+func (c ctl) del(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: del KEY")
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.cfg.timeout)
+	defer cancel()
+
+	return c.client.Del(ctx, c.redisKey(args[0])).Err()
+}
+
+func (c ctl) keys(ctx context.Context, args []string) error {
+	pattern := "*"
+	if len(args) > 0 {
+		pattern = args[0]
+	}
+
+	return c.scan(ctx, pattern, func(key string) error {
+		_, err := fmt.Println(c.cfg.redactor.Redact(strings.TrimPrefix(key, c.cfg.prefix+":")))
+		return err
+	})
+}
+
+func (c ctl) stats(ctx context.Context) error {
+	var count int
+	err := c.scan(ctx, "*", func(string) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("keys: %d\n", count)
+
+	info, err := c.client.Info(ctx, "stats").Result()
+	if err != nil {
+		return fmt.Errorf("could not get redis stats: %w", err)
+	}
+
+	for _, line := range strings.Split(info, "\r\n") {
+		if strings.HasPrefix(line, "keyspace_hits:") || strings.HasPrefix(line, "keyspace_misses:") {
+			fmt.Println(strings.Replace(line, ":", ": ", 1))
+		}
+	}
+
+	return nil
+}
+
+func (c ctl) export(ctx context.Context, args []string) error {
+	out := os.Stdout
+	if len(args) > 0 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	err := c.scan(ctx, "*", func(key string) error {
+		value, err := c.client.Get(ctx, key).Bytes()
+		if errors.Is(err, goredis.Nil) {
+			return nil // expired while scanning
+		}
+		if err != nil {
+			return err
+		}
+
+		ttl, err := c.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		e := entry{
+			Key:   strings.TrimPrefix(key, c.cfg.prefix+":"),
+			Value: value,
+		}
+		if ttl > 0 {
+			e.TTL = ttl.Milliseconds()
+		}
+
+		return enc.Encode(e)
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+func (c ctl) restore(ctx context.Context, args []string) error {
+	in, err := open(args)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var restored int
+	dec := json.NewDecoder(bufio.NewReader(in))
+	for {
+		var e entry
+		if err := dec.Decode(&e); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("could not decode entry: %w", err)
+		}
+
+		ttl := time.Duration(e.TTL) * time.Millisecond
+		if err := c.client.Set(ctx, c.redisKey(e.Key), e.Value, ttl).Err(); err != nil {
+			return fmt.Errorf("could not restore key %s: %w", c.cfg.redactor.Redact(e.Key), err)
+		}
+		restored++
+	}
+
+	fmt.Printf("restored: %d\n", restored)
+
+	return nil
+}
+
+func (c ctl) warm(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: warm FILE")
+	}
+
+	in, err := open(args)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	rcache, err := c.cache()
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 100
+
+	dec := json.NewDecoder(bufio.NewReader(in))
+	loader := cache.BulkLoaderFunc[any](func(context.Context) ([]cache.StorageItemMulti[any], error) {
+		batch := make([]cache.StorageItemMulti[any], 0, batchSize)
+		for len(batch) < batchSize {
+			var line struct {
+				Key   string          `json:"key"`
+				Value json.RawMessage `json:"value"`
+			}
+			if err := dec.Decode(&line); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("could not decode entry: %w", err)
+			}
+
+			value, err := c.parse(string(line.Value))
+			if err != nil {
+				return nil, fmt.Errorf("could not parse value of key %s: %w", c.cfg.redactor.Redact(line.Key), err)
+			}
+
+			batch = append(batch, cache.StorageItemMulti[any]{Key: line.Key, Value: value})
+		}
+
+		if len(batch) == 0 {
+			return nil, io.EOF
+		}
+
+		return batch, nil
+	})
+
+	opts := cache.LoadOptions{Concurrency: c.cfg.concurrency}
+	if c.cfg.ttl != 0 {
+		opts.TTL = &c.cfg.ttl
+	}
+
+	progress, err := cache.LoadAll[any](ctx, rcache, loader, opts)
+	fmt.Printf("warmed: %d\n", progress.Items)
+
+	return err
+}
+
+// parse converts a command line value into the value stored in the cache
+func (c ctl) parse(s string) (any, error) {
+	if c.cfg.raw {
+		var unquoted string
+		if err := json.Unmarshal([]byte(s), &unquoted); err == nil {
+			return unquoted, nil
+		}
+
+		return s, nil
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, fmt.Errorf("value is not valid JSON, use -raw for plain strings: %w", err)
+	}
+
+	return value, nil
+}
+
+func (c ctl) scan(ctx context.Context, pattern string, fn func(key string) error) error {
+	iter := c.client.Scan(ctx, 0, c.redisKey(pattern), 1000).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+func (c ctl) redisKey(key string) string {
+	return fmt.Sprintf("%s:%s", c.cfg.prefix, key)
+}
+
+// msgpackCodec encodes values the same way go-redis/cache does, including strings go-redis/cache would store as is
+type msgpackCodec struct {
+	storage *rc.Cache
+}
+
+func (c msgpackCodec) Marshal(value any) ([]byte, error) {
+	return c.storage.Marshal(&value)
+}
+
+func (c msgpackCodec) Unmarshal(data []byte) (any, error) {
+	var value any
+	err := c.storage.Unmarshal(data, &value)
+	return value, err
+}
+
+// rawCodec stores values as plain strings
+type rawCodec struct{}
+
+func (rawCodec) Marshal(value any) ([]byte, error) {
+	return []byte(fmt.Sprint(value)), nil
+}
+
+func (rawCodec) Unmarshal(data []byte) (any, error) {
+	return string(data), nil
+}
+
+func open(args []string) (io.ReadCloser, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(args[0])
+}
+
+func printJSON(value any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
@@ -0,0 +1,45 @@
+// Package codec provides cache.Codec implementations backed by third-party encodings
+package codec
+
+import (
+	"errors"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Msgpack encodes values as MessagePack
+//
+// Unlike go-redis/cache, which uses MessagePack as well, does not compress values, so they are readable by any
+// MessagePack consumer
+type Msgpack[T any] struct{}
+
+func (Msgpack[T]) Marshal(value T) ([]byte, error) {
+	return msgpack.Marshal(value)
+}
+
+func (Msgpack[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	err := msgpack.Unmarshal(data, &value)
+	return value, err
+}
+
+// Proto encodes protocol buffers messages in the wire format
+//
+// T is expected to be a pointer to a generated message type, e.g. Proto[*pb.User]
+type Proto[T proto.Message] struct{}
+
+func (Proto[T]) Marshal(value T) ([]byte, error) {
+	return proto.Marshal(value)
+}
+
+func (Proto[T]) Unmarshal(data []byte) (T, error) {
+	var zero T
+	value, ok := zero.ProtoReflect().New().Interface().(T)
+	if !ok {
+		return zero, errors.New("could not create protobuf message")
+	}
+
+	err := proto.Unmarshal(data, value)
+	return value, err
+}
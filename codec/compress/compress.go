@@ -0,0 +1,179 @@
+// Package compress provides a cache.Codec compressing serialized values above a size threshold
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sinu5oid/cache"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultThreshold is the serialized size values are compressed from unless configured with WithThreshold
+const defaultThreshold = 1024
+
+// Algorithm selects the compression algorithm. Its value is stored as the header byte of compressed payloads
+type Algorithm byte
+
+const (
+	// None stores values as is
+	None Algorithm = iota
+	// Gzip compresses values with gzip, slow but widely supported
+	Gzip
+	// Snappy compresses values with snappy, fast with a moderate ratio
+	Snappy
+	// Zstd compresses values with zstd, balancing speed and ratio
+	Zstd
+)
+
+// String returns the algorithm name
+func (a Algorithm) String() string {
+	switch a {
+	case None:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Snappy:
+		return "snappy"
+	case Zstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", byte(a))
+	}
+}
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil)
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+)
+
+// Codec wraps a cache.Codec, compressing the values it serializes when they reach the threshold
+//
+// Every payload starts with a header byte naming the algorithm it is compressed with, None for small and
+// incompressible values. Payloads are decompressed according to their header, so the algorithm can be changed without
+// invalidating stored values. Safe for concurrent usage
+type Codec[T any] struct {
+	codec     cache.Codec[T]
+	algorithm Algorithm
+	threshold int
+}
+
+// New creates a Codec compressing values serialized by the codec with the algorithm
+func New[T any](codec cache.Codec[T], algorithm Algorithm) (*Codec[T], error) {
+	if algorithm > Zstd {
+		return nil, fmt.Errorf("unknown compression algorithm %s", algorithm)
+	}
+
+	return &Codec[T]{
+		codec:     codec,
+		algorithm: algorithm,
+		threshold: defaultThreshold,
+	}, nil
+}
+
+// WithThreshold assigns the serialized size in bytes values are compressed from, 1 KiB by default
+func (c *Codec[T]) WithThreshold(size int) *Codec[T] {
+	c.threshold = size
+	return c
+}
+
+// Marshal serializes the value with the wrapped codec, compressing the result if it reaches the threshold
+func (c *Codec[T]) Marshal(value T) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.algorithm != None && len(data) >= c.threshold {
+		compressed, err := compress(c.algorithm, data)
+		if err != nil {
+			return nil, fmt.Errorf("could not compress value with %s: %w", c.algorithm, err)
+		}
+
+		if len(compressed) < len(data) {
+			return compressed, nil
+		}
+	}
+
+	return append([]byte{byte(None)}, data...), nil
+}
+
+// Unmarshal decompresses the payload according to its header and deserializes it with the wrapped codec
+func (c *Codec[T]) Unmarshal(data []byte) (T, error) {
+	if len(data) == 0 {
+		return *new(T), errors.New("missing compression header")
+	}
+
+	algorithm := Algorithm(data[0])
+	decompressed, err := decompress(algorithm, data[1:])
+	if err != nil {
+		return *new(T), fmt.Errorf("could not decompress value with %s: %w", algorithm, err)
+	}
+
+	return c.codec.Unmarshal(decompressed)
+}
+
+// compress returns the data compressed with the algorithm, prefixed with the header byte
+func compress(algorithm Algorithm, data []byte) ([]byte, error) {
+	switch algorithm {
+	case Gzip:
+		buf := bytes.NewBuffer([]byte{byte(Gzip)})
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case Snappy:
+		return append([]byte{byte(Snappy)}, snappy.Encode(nil, data)...), nil
+	case Zstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+
+		return encoder.EncodeAll(data, []byte{byte(Zstd)}), nil
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %s", algorithm)
+	}
+}
+
+// decompress returns the data compressed with the algorithm restored, the header byte is expected to be stripped
+func decompress(algorithm Algorithm, data []byte) ([]byte, error) {
+	switch algorithm {
+	case None:
+		return data, nil
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return io.ReadAll(r)
+	case Snappy:
+		return snappy.Decode(nil, data)
+	case Zstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %s", algorithm)
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+type bypassKey struct{}
+
+type forceRefreshKey struct{}
+
+// WithBypass marks the context so caches skip both reads and writes for the operations using it
+//
+// Reads behave as misses, writes are dropped and GetOrFetch calls the fetcher without storing the result.
+// Deletes are still applied, so invalidations are never lost
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// IsBypassed reports whether the context was marked with WithBypass
+func IsBypassed(ctx context.Context) bool {
+	v, _ := ctx.Value(bypassKey{}).(bool)
+	return v
+}
+
+// WithForceRefresh marks the context so GetOrFetch ignores the cached value, calls the fetcher and stores the result
+func WithForceRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceRefreshKey{}, true)
+}
+
+// IsForceRefresh reports whether the context was marked with WithForceRefresh
+func IsForceRefresh(ctx context.Context) bool {
+	v, _ := ctx.Value(forceRefreshKey{}).(bool)
+	return v
+}
+
+// ContextWithDefaultTimeout derives a context bounded by timeout when ctx has no deadline of its own
+//
+// The context is returned as is when it already has a deadline or timeout is not positive
+func ContextWithDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/sinu5oid/cache/internal/snapshot"
+)
+
+// dumpBatchSize is the number of keys Dump reads with a single call
+const dumpBatchSize = 100
+
+// Dump writes live entries of the cache to w as JSON lines, one entry per line:
+//
+//	{"key":"user:1","value":{"name":"Ann"},"expires_at":"2024-05-01T12:00:00Z"}
+//
+// Values are encoded with encoding/json, expires_at is an RFC 3339 time omitted for entries that never expire or if
+// the cache does not implement TTLMultiGetter. The cache must implement KeyLister. Entries removed while dumping
+// are skipped. The same format is written by SaveTo of inmem and lru caches
+func Dump[T any](ctx context.Context, c Cacher[T], w io.Writer) error {
+	lister, ok := c.(KeyLister)
+	if !ok {
+		return fmt.Errorf("could not dump cache: %T does not list keys", c)
+	}
+
+	keys, err := lister.Keys(ctx)
+	if err != nil {
+		return fmt.Errorf("could not dump cache: failed to list keys: %w", err)
+	}
+
+	var readErr error
+	writeErr := snapshot.Write(w, func(yield func(snapshot.Entry[T]) bool) {
+		for batch := range slices.Chunk(keys, dumpBatchSize) {
+			var entries []snapshot.Entry[T]
+			entries, readErr = dumpBatch(ctx, c, batch)
+			if readErr != nil {
+				return
+			}
+
+			for _, entry := range entries {
+				if !yield(entry) {
+					return
+				}
+			}
+		}
+	})
+	if readErr != nil {
+		return fmt.Errorf("could not dump cache: failed to read values: %w", readErr)
+	}
+
+	if writeErr != nil {
+		return fmt.Errorf("could not dump cache: %w", writeErr)
+	}
+
+	return nil
+}
+
+// Restore writes the entries dumped by Dump from r to the cache, keeping their expiration time
+//
+// Entries expired since they were dumped are skipped. Entries with expiration are written with SetWithTTL if the cache
+// implements TTLCacher, otherwise with Set like the entries without expiration
+func Restore[T any](ctx context.Context, c Cacher[T], r io.Reader) error {
+	ttlCacher, withTTL := c.(TTLCacher[T])
+
+	return snapshot.Read(r, func(entry snapshot.Entry[T]) error {
+		if entry.ExpiresAt == nil || !withTTL {
+			return c.Set(ctx, entry.Key, entry.Value)
+		}
+
+		ttl := time.Until(*entry.ExpiresAt)
+		if ttl <= 0 {
+			return nil
+		}
+
+		return ttlCacher.SetWithTTL(ctx, entry.Key, entry.Value, ttl)
+	})
+}
+
+func dumpBatch[T any](ctx context.Context, c Cacher[T], keys []string) ([]snapshot.Entry[T], error) {
+	getter, ok := c.(TTLMultiGetter[T])
+	if !ok {
+		items, err := c.GetMulti(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := make([]snapshot.Entry[T], 0, len(items))
+		for _, item := range items {
+			entries = append(entries, snapshot.Entry[T]{Key: item.Key, Value: item.Value})
+		}
+
+		return entries, nil
+	}
+
+	items, err := getter.GetMultiWithTTL(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]snapshot.Entry[T], 0, len(items))
+	for _, item := range items {
+		entry := snapshot.Entry[T]{Key: item.Key, Value: item.Value}
+		if item.TTL != NoExpiration {
+			expiresAt := now.Add(item.TTL)
+			entry.ExpiresAt = &expiresAt
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
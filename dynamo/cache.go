@@ -0,0 +1,615 @@
+// Package dynamo provides a typed cache over an Amazon DynamoDB table
+//
+// Every entry is an item keyed by a string partition key, holding the value converted to bytes with a codec and its
+// expiration time in epoch seconds. Enable DynamoDB TTL on the expiration attribute to have expired items removed,
+// until then they are reported as missing
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+const (
+	defaultKeyAttribute   = "key"
+	defaultValueAttribute = "value"
+	defaultTTLAttribute   = "expires_at"
+
+	// maxBatchGet is the maximal number of keys read by a single BatchGetItem call
+	maxBatchGet = 100
+	// maxBatchWrite is the maximal number of items written by a single BatchWriteItem call
+	maxBatchWrite = 25
+	// maxBatchAttempts is the number of BatchGetItem and BatchWriteItem calls made for a batch with unprocessed items
+	maxBatchAttempts = 5
+	// batchBackoff is the delay before the first retry of unprocessed items, doubled for every next one
+	batchBackoff = 50 * time.Millisecond
+)
+
+// Client is the subset of the DynamoDB API used by Cache, implemented by *dynamodb.Client
+type Client interface {
+	GetItem(
+		ctx context.Context,
+		params *dynamodb.GetItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.GetItemOutput, error)
+	PutItem(
+		ctx context.Context,
+		params *dynamodb.PutItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.PutItemOutput, error)
+	DeleteItem(
+		ctx context.Context,
+		params *dynamodb.DeleteItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.DeleteItemOutput, error)
+	BatchGetItem(
+		ctx context.Context,
+		params *dynamodb.BatchGetItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(
+		ctx context.Context,
+		params *dynamodb.BatchWriteItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// Cache represents typed DynamoDB table wrapped
+//
+// TTLs are rounded up to whole seconds, the precision of DynamoDB TTL. Safe for concurrent usage
+type Cache[T any] struct {
+	client     Client
+	table      string
+	codec      cache.Codec[T]
+	defaultTTL *time.Duration
+	keyAttr    string
+	valueAttr  string
+	ttlAttr    string
+	consistent bool
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[T]
+}
+
+// NewCache creates a Cache instance over the table using the codec
+//
+// The table has to exist, with a string partition key named "key" unless WithKeyAttribute is used, and no sort key
+func NewCache[T any](client Client, table string, codec cache.Codec[T]) *Cache[T] {
+	return &Cache[T]{
+		client:    client,
+		table:     table,
+		codec:     codec,
+		keyAttr:   defaultKeyAttribute,
+		valueAttr: defaultValueAttribute,
+		ttlAttr:   defaultTTLAttribute,
+	}
+}
+
+// NewBytesCache creates a Cache instance storing byte slices as is
+func NewBytesCache(client Client, table string) *Cache[[]byte] {
+	return NewCache[[]byte](client, table, bytesCodec{})
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
+	c.defaultTTL = &ttl
+	return c
+}
+
+// WithKeyAttribute assigns the name of the partition key attribute, "key" by default
+func (c *Cache[T]) WithKeyAttribute(name string) *Cache[T] {
+	c.keyAttr = name
+	return c
+}
+
+// WithValueAttribute assigns the name of the binary attribute holding encoded values, "value" by default
+func (c *Cache[T]) WithValueAttribute(name string) *Cache[T] {
+	c.valueAttr = name
+	return c
+}
+
+// WithTTLAttribute assigns the name of the numeric attribute holding expiration time in epoch seconds, "expires_at"
+// by default. Items written without TTL do not have it
+func (c *Cache[T]) WithTTLAttribute(name string) *Cache[T] {
+	c.ttlAttr = name
+	return c
+}
+
+// WithConsistentReads makes reads strongly consistent, so they see all the writes completed before them
+//
+// Strongly consistent reads consume twice the read capacity of eventually consistent ones
+func (c *Cache[T]) WithConsistentReads() *Cache[T] {
+	c.consistent = true
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return c.get(ctx, key)
+}
+
+// GetOrFetch tries to obtain cached value from the table. If multiple callers are accessing the same key, later
+// callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.get(ctx, key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.get(ctx, key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			err = c.set(ctx, key, result, ttl)
+		}
+
+		return result, err
+	})
+}
+
+// Set puts the provided value by cache key to the table
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(ctx, key, value, nil)
+}
+
+// GetMulti returns cached values by provided keys with BatchGetItem, reading up to 100 keys per call.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+
+	unique := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			unique = append(unique, key)
+		}
+	}
+
+	found := make(map[string]T, len(unique))
+	now := time.Now()
+	for start := 0; start < len(unique); start += maxBatchGet {
+		batch := unique[start:min(start+maxBatchGet, len(unique))]
+		items, err := c.batchGet(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			key, value, err := c.decode(item, now)
+			if isMiss(err) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			found[key] = value
+		}
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(found))
+	for _, key := range unique {
+		value, ok := found[key]
+		if !ok {
+			continue
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to the table with BatchWriteItem, writing up to 25 items per call
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetMultiWithTTL
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.setMulti(ctx, kvs, nil)
+}
+
+// Delete removes cached value by key
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	_, err := c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.table),
+		Key:       c.key(key),
+	})
+
+	return err
+}
+
+// DeleteMulti removes cached values by keys with BatchWriteItem, removing up to 25 items per call
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	requests := make(map[string]types.WriteRequest, len(keys))
+	order := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := requests[key]; !ok {
+			order = append(order, key)
+		}
+		requests[key] = types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: c.key(key)}}
+	}
+
+	return c.batchWrite(ctx, order, requests)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(ctx, key, value, &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration, writing up to 25 items per call
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.setMulti(ctx, kvs, &ttl)
+}
+
+// SetNX puts provided value by cache key using provided ttl duration with a conditional write, only if no live item is
+// stored by the key. Reports whether the value was stored
+func (c *Cache[T]) SetNX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error) {
+	return c.setIf(ctx, key, value, ttl, "attribute_not_exists(#k) OR (attribute_exists(#e) AND #e <= :now)")
+}
+
+// SetXX puts provided value by cache key using provided ttl duration with a conditional write, only if a live item is
+// stored by the key. Reports whether the value was stored
+func (c *Cache[T]) SetXX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error) {
+	return c.setIf(ctx, key, value, ttl, "attribute_exists(#k) AND (attribute_not_exists(#e) OR #e > :now)")
+}
+
+func (c *Cache[T]) setIf(ctx context.Context, key string, value T, ttl time.Duration, condition string) (bool, error) {
+	if cache.IsBypassed(ctx) {
+		return false, nil
+	}
+
+	item, err := c.item(key, value, &ttl)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.table),
+		Item:                item,
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]string{
+			"#k": c.keyAttr,
+			"#e": c.ttlAttr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": epoch(time.Now().Unix()),
+		},
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (c *Cache[T]) get(ctx context.Context, key string) (T, error) {
+	out, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.table),
+		Key:            c.key(key),
+		ConsistentRead: aws.Bool(c.consistent),
+	})
+	if err != nil {
+		return *new(T), err
+	}
+
+	if out.Item == nil {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	_, value, err := c.decode(out.Item, time.Now())
+	return value, err
+}
+
+func (c *Cache[T]) set(ctx context.Context, key string, value T, ttl *time.Duration) error {
+	item, err := c.item(key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.table),
+		Item:      item,
+	})
+
+	return err
+}
+
+func (c *Cache[T]) setMulti(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl *time.Duration) error {
+	// BatchWriteItem rejects batches writing a key twice, the last value wins as with sequential writes
+	requests := make(map[string]types.WriteRequest, len(kvs))
+	order := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		item, err := c.item(kv.Key, kv.Value, ttl)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := requests[kv.Key]; !ok {
+			order = append(order, kv.Key)
+		}
+		requests[kv.Key] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+
+	return c.batchWrite(ctx, order, requests)
+}
+
+// batchGet reads the keys with BatchGetItem, retrying unprocessed ones
+func (c *Cache[T]) batchGet(ctx context.Context, keys []string) ([]map[string]types.AttributeValue, error) {
+	request := types.KeysAndAttributes{
+		Keys:           make([]map[string]types.AttributeValue, 0, len(keys)),
+		ConsistentRead: aws.Bool(c.consistent),
+	}
+	for _, key := range keys {
+		request.Keys = append(request.Keys, c.key(key))
+	}
+
+	var items []map[string]types.AttributeValue
+	for attempt := 0; ; attempt++ {
+		out, err := c.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{c.table: request},
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, out.Responses[c.table]...)
+
+		unprocessed, ok := out.UnprocessedKeys[c.table]
+		if !ok || len(unprocessed.Keys) == 0 {
+			return items, nil
+		}
+
+		if err := backoff(ctx, attempt, len(unprocessed.Keys)); err != nil {
+			return nil, err
+		}
+		request = unprocessed
+	}
+}
+
+// batchWrite applies the requests with BatchWriteItem in batches of up to 25 items, retrying unprocessed ones
+func (c *Cache[T]) batchWrite(ctx context.Context, keys []string, requests map[string]types.WriteRequest) error {
+	for start := 0; start < len(keys); start += maxBatchWrite {
+		batch := make([]types.WriteRequest, 0, maxBatchWrite)
+		for _, key := range keys[start:min(start+maxBatchWrite, len(keys))] {
+			batch = append(batch, requests[key])
+		}
+
+		for attempt := 0; len(batch) > 0; attempt++ {
+			out, err := c.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{c.table: batch},
+			})
+			if err != nil {
+				return err
+			}
+
+			batch = out.UnprocessedItems[c.table]
+			if len(batch) == 0 {
+				break
+			}
+
+			if err := backoff(ctx, attempt, len(batch)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// backoff waits before the retry of unprocessed items, failing once the attempts are exhausted
+func backoff(ctx context.Context, attempt, unprocessed int) error {
+	if attempt+1 >= maxBatchAttempts {
+		return fmt.Errorf("%d items left unprocessed after %d attempts: %w", unprocessed, maxBatchAttempts,
+			cache.ErrBackendUnavailable)
+	}
+
+	timer := time.NewTimer(batchBackoff << attempt)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Cache[T]) key(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		c.keyAttr: &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func (c *Cache[T]) item(key string, value T, ttl *time.Duration) (map[string]types.AttributeValue, error) {
+	if ttl == nil {
+		ttl = c.defaultTTL
+	}
+
+	encoded, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal value by key %s: %w", key, err)
+	}
+
+	item := c.key(key)
+	item[c.valueAttr] = &types.AttributeValueMemberB{Value: encoded}
+	if ttl != nil && *ttl > 0 {
+		// round up, so entries never expire before their TTL passes
+		expiresAt := time.Now().Add(*ttl)
+		seconds := expiresAt.Unix()
+		if expiresAt.Nanosecond() > 0 {
+			seconds++
+		}
+		item[c.ttlAttr] = epoch(seconds)
+	}
+
+	return item, nil
+}
+
+// decode returns the key and the value of the item unless it has expired
+func (c *Cache[T]) decode(item map[string]types.AttributeValue, now time.Time) (string, T, error) {
+	keyAttr, ok := item[c.keyAttr].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", *new(T), fmt.Errorf("item has no string attribute %s", c.keyAttr)
+	}
+	key := keyAttr.Value
+
+	if expiresAt, ok := item[c.ttlAttr].(*types.AttributeValueMemberN); ok {
+		seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+		if err != nil {
+			return key, *new(T), fmt.Errorf("could not parse expiration time of key %s: %w", key, err)
+		}
+
+		if seconds <= now.Unix() {
+			return key, *new(T), cache.NewMissingEntryError(key)
+		}
+	}
+
+	valueAttr, ok := item[c.valueAttr].(*types.AttributeValueMemberB)
+	if !ok {
+		return key, *new(T), fmt.Errorf("item by key %s has no binary attribute %s", key, c.valueAttr)
+	}
+
+	value, err := c.codec.Unmarshal(valueAttr.Value)
+	return key, value, err
+}
+
+func epoch(seconds int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(seconds, 10)}
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
+
+// bytesCodec stores byte slices as is
+type bytesCodec struct{}
+
+func (bytesCodec) Marshal(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (bytesCodec) Unmarshal(data []byte) ([]byte, error) {
+	return data, nil
+}
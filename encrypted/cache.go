@@ -0,0 +1,162 @@
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// Cache wraps a byte-oriented cache.Cacher encrypting values with AES-GCM before they are written
+//
+// Ciphertexts are bound to their keys, so a value copied to another key fails to decrypt. Values failing to decrypt
+// are reported as cache.FailedToCastEntryError and skipped by GetMulti. Safe for concurrent usage if the wrapped cache
+// is
+type Cache struct {
+	backend cache.Cacher[[]byte]
+	keyring *Keyring
+}
+
+// Wrap creates an encrypting Cache using the keyring
+func Wrap(c cache.Cacher[[]byte], keyring *Keyring) *Cache {
+	return &Cache{
+		backend: c,
+		keyring: keyring,
+	}
+}
+
+// Unwrap returns the wrapped cache
+func (c *Cache) Unwrap() cache.Cacher[[]byte] {
+	return c.backend
+}
+
+// Get retrieves an item by key and decrypts it
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	envelope, err := c.backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.open(key, envelope)
+}
+
+// Set encrypts the provided value and puts it by key
+func (c *Cache) Set(ctx context.Context, key string, value []byte) error {
+	envelope, err := c.keyring.seal(value, []byte(key))
+	if err != nil {
+		return err
+	}
+
+	return c.backend.Set(ctx, key, envelope)
+}
+
+// GetMulti returns decrypted cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found or failed to decrypt
+func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[[]byte], error) {
+	items, err := c.backend.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]cache.StorageItemMulti[[]byte], 0, len(items))
+	for _, item := range items {
+		value, err := c.open(item.Key, item.Value)
+		if err != nil {
+			continue
+		}
+
+		res = append(res, cache.StorageItemMulti[[]byte]{
+			Key:   item.Key,
+			Value: value,
+		})
+	}
+
+	return res, nil
+}
+
+// SetMulti encrypts and puts provided k/v pairs
+func (c *Cache) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[[]byte]) error {
+	items, err := c.seal(kvs)
+	if err != nil {
+		return err
+	}
+
+	return c.backend.SetMulti(ctx, items)
+}
+
+// Exists reports whether a live entry by key is stored, without decrypting it
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	return cache.Exists(ctx, c.backend, key)
+}
+
+// Delete removes cached value by key
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	return c.backend.Delete(ctx, key)
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.backend.DeleteMulti(ctx, keys)
+}
+
+// SetWithTTL encrypts the provided value and puts it by key using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[[]byte])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	envelope, err := c.keyring.seal(value, []byte(key))
+	if err != nil {
+		return err
+	}
+
+	return ttlCacher.SetWithTTL(ctx, key, envelope, ttl)
+}
+
+// SetMultiWithTTL encrypts and puts provided k/v pairs using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[[]byte], ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[[]byte])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	items, err := c.seal(kvs)
+	if err != nil {
+		return err
+	}
+
+	return ttlCacher.SetMultiWithTTL(ctx, items, ttl)
+}
+
+func (c *Cache) open(key string, envelope []byte) ([]byte, error) {
+	value, err := c.keyring.open(envelope, []byte(key))
+	if err != nil {
+		return nil, cache.NewFailedToCastEntryError(key, err)
+	}
+
+	return value, nil
+}
+
+func (c *Cache) seal(kvs []cache.StorageItemMulti[[]byte]) ([]cache.StorageItemMulti[[]byte], error) {
+	items := make([]cache.StorageItemMulti[[]byte], 0, len(kvs))
+	for _, kv := range kvs {
+		envelope, err := c.keyring.seal(kv.Value, []byte(kv.Key))
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, cache.StorageItemMulti[[]byte]{
+			Key:   kv.Key,
+			Value: envelope,
+		})
+	}
+
+	return items, nil
+}
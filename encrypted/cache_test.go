@@ -0,0 +1,169 @@
+package encrypted_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/encrypted"
+	"github.com/sinu5oid/cache/inmem"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, 32)
+	newKey = bytes.Repeat([]byte{2}, 32)
+)
+
+func newCache(t *testing.T) (*encrypted.Cache, *encrypted.Keyring, *inmem.Cache[[]byte]) {
+	t.Helper()
+
+	keyring, err := encrypted.NewKeyring("old", oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	backend := inmem.New[[]byte]()
+	return encrypted.Wrap(backend, keyring), keyring, backend
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c, _, backend := newCache(t)
+
+	if err := c.Set(ctx, "key", []byte("value")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	stored, err := backend.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("backend Get() error = %v", err)
+	}
+
+	if bytes.Contains(stored, []byte("value")) {
+		t.Errorf("backend stores the plaintext: %q", stored)
+	}
+
+	if got, err := c.Get(ctx, "key"); err != nil || string(got) != "value" {
+		t.Errorf("Get() = %q, %v, want value", got, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	c, keyring, _ := newCache(t)
+
+	if err := c.Set(ctx, "before", []byte("old")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := keyring.Rotate("new", newKey); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if err := c.Set(ctx, "after", []byte("new")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if got, err := c.Get(ctx, "before"); err != nil || string(got) != "old" {
+		t.Errorf("Get() of the value written before Rotate() = %q, %v, want old", got, err)
+	}
+
+	if got, err := c.Get(ctx, "after"); err != nil || string(got) != "new" {
+		t.Errorf("Get() of the value written after Rotate() = %q, %v, want new", got, err)
+	}
+
+	if err := keyring.Remove("new"); err == nil {
+		t.Error("Remove() of the primary key error = nil")
+	}
+
+	if err := keyring.Remove("old"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	var castErr cache.FailedToCastEntryError
+	if _, err := c.Get(ctx, "before"); !errors.As(err, &castErr) {
+		t.Errorf("Get() of the value encrypted with the removed key error = %v, want cache.FailedToCastEntryError", err)
+	}
+
+	if got, err := c.Get(ctx, "after"); err != nil || string(got) != "new" {
+		t.Errorf("Get() after Remove() = %q, %v, want new", got, err)
+	}
+
+	items, err := c.GetMulti(ctx, []string{"before", "after"})
+	if err != nil || len(items) != 1 || items[0].Key != "after" {
+		t.Errorf("GetMulti() = %v, %v, want after only", items, err)
+	}
+}
+
+func TestCopiedValueFailsToDecrypt(t *testing.T) {
+	ctx := context.Background()
+	c, _, backend := newCache(t)
+
+	if err := c.Set(ctx, "alice", []byte("secret")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	stored, err := backend.Get(ctx, "alice")
+	if err != nil {
+		t.Fatalf("backend Get() error = %v", err)
+	}
+
+	if err := backend.Set(ctx, "mallory", stored); err != nil {
+		t.Fatalf("backend Set() error = %v", err)
+	}
+
+	var castErr cache.FailedToCastEntryError
+	if got, err := c.Get(ctx, "mallory"); !errors.As(err, &castErr) {
+		t.Errorf("Get() of the copied value = %q, %v, want cache.FailedToCastEntryError", got, err)
+	}
+}
+
+func TestMalformedEnvelope(t *testing.T) {
+	ctx := context.Background()
+	c, _, backend := newCache(t)
+
+	if err := c.Set(ctx, "key", []byte("value")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	stored, err := backend.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("backend Get() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		envelope []byte
+	}{
+		{name: "empty", envelope: nil},
+		{name: "version only", envelope: stored[:1]},
+		{name: "truncated key ID", envelope: stored[:3]},
+		{name: "truncated nonce", envelope: stored[:2+len("old")+4]},
+		{name: "truncated ciphertext", envelope: stored[:len(stored)-1]},
+		{name: "unknown version", envelope: append([]byte{0}, stored[1:]...)},
+		{name: "unknown key", envelope: append([]byte{stored[0], 3, 'n', 'e', 'w'}, stored[2+len("old"):]...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := backend.Set(ctx, "key", tt.envelope); err != nil {
+				t.Fatalf("backend Set() error = %v", err)
+			}
+
+			var castErr cache.FailedToCastEntryError
+			if got, err := c.Get(ctx, "key"); !errors.As(err, &castErr) {
+				t.Errorf("Get() = %q, %v, want cache.FailedToCastEntryError", got, err)
+			}
+		})
+	}
+}
+
+func TestZeroKeyring(t *testing.T) {
+	c := encrypted.Wrap(inmem.New[[]byte](), &encrypted.Keyring{})
+
+	if err := c.Set(context.Background(), "key", []byte("value")); err == nil {
+		t.Error("Set() with an empty keyring error = nil")
+	}
+}
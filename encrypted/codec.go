@@ -0,0 +1,40 @@
+package encrypted
+
+import "github.com/sinu5oid/cache"
+
+// Codec wraps a cache.Codec, encrypting the values it serializes
+//
+// Unlike Cache, ciphertexts are not bound to their keys, as codecs do not receive them. Safe for concurrent usage if
+// the wrapped codec is
+type Codec[T any] struct {
+	codec   cache.Codec[T]
+	keyring *Keyring
+}
+
+// NewCodec creates a Codec encrypting values serialized by the codec with the keyring
+func NewCodec[T any](codec cache.Codec[T], keyring *Keyring) *Codec[T] {
+	return &Codec[T]{
+		codec:   codec,
+		keyring: keyring,
+	}
+}
+
+// Marshal serializes the value with the wrapped codec and encrypts the result with the primary key
+func (c *Codec[T]) Marshal(value T) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.keyring.seal(data, nil)
+}
+
+// Unmarshal decrypts the data with the key it was encrypted with and deserializes it with the wrapped codec
+func (c *Codec[T]) Unmarshal(data []byte) (T, error) {
+	plaintext, err := c.keyring.open(data, nil)
+	if err != nil {
+		return *new(T), err
+	}
+
+	return c.codec.Unmarshal(plaintext)
+}
@@ -0,0 +1,155 @@
+// Package encrypted provides a cache wrapper and a codec encrypting values at rest with AES-GCM
+package encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// envelopeVersion is the first byte of every envelope, allowing the format to change later
+const envelopeVersion = 1
+
+// maxKeyIDLength is the length limit of key IDs, as it is stored in a single byte of the envelope
+const maxKeyIDLength = 255
+
+// Keyring holds the AES keys values are encrypted with, identified by IDs stored alongside the ciphertext
+//
+// Values are encrypted with the primary key, the other keys are only used to decrypt values written before a rotation.
+// Safe for concurrent usage
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+
+	lock sync.RWMutex
+}
+
+// NewKeyring creates a Keyring with the primary key by id. The key must be 16, 24 or 32 bytes long to select
+// AES-128, AES-192 or AES-256
+func NewKeyring(id string, key []byte) (*Keyring, error) {
+	k := &Keyring{
+		keys: make(map[string]cipher.AEAD),
+	}
+
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Add registers the key by id, so values encrypted with it can be decrypted
+func (k *Keyring) Add(id string, key []byte) error {
+	if len(id) == 0 || len(id) > maxKeyIDLength {
+		return fmt.Errorf("key ID must be 1 to %d bytes long", maxKeyIDLength)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid key %s: %w", id, err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("invalid key %s: %w", id, err)
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key %s is already registered", id)
+	}
+	k.keys[id] = aead
+
+	return nil
+}
+
+// Rotate registers the key by id and makes it primary, so new values are encrypted with it. Values encrypted with
+// the previous keys stay readable until the keys are removed
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+
+	k.lock.Lock()
+	k.primary = id
+	k.lock.Unlock()
+
+	return nil
+}
+
+// Remove drops the key by id, values encrypted with it can not be decrypted anymore. The primary key can not be
+// removed
+func (k *Keyring) Remove(id string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if id == k.primary {
+		return fmt.Errorf("could not remove primary key %s", id)
+	}
+	delete(k.keys, id)
+
+	return nil
+}
+
+// seal encrypts the plaintext with the primary key into the envelope: version, key ID length, key ID, nonce and
+// ciphertext. The additional data is authenticated but not stored
+func (k *Keyring) seal(plaintext, additional []byte) ([]byte, error) {
+	k.lock.RLock()
+	id := k.primary
+	aead, ok := k.keys[id]
+	k.lock.RUnlock()
+
+	if !ok {
+		return nil, errors.New("keyring has no primary key, create it with NewKeyring")
+	}
+
+	envelope := make([]byte, 0, 2+len(id)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	envelope = append(envelope, envelopeVersion, byte(len(id)))
+	envelope = append(envelope, id...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	envelope = append(envelope, nonce...)
+
+	return aead.Seal(envelope, nonce, plaintext, additional), nil
+}
+
+// open decrypts the envelope produced by seal with the key it names
+func (k *Keyring) open(envelope, additional []byte) ([]byte, error) {
+	if len(envelope) < 2 || envelope[0] != envelopeVersion {
+		return nil, errors.New("unknown envelope format")
+	}
+
+	idEnd := 2 + int(envelope[1])
+	if len(envelope) < idEnd {
+		return nil, errors.New("truncated envelope")
+	}
+	id := string(envelope[2:idEnd])
+
+	k.lock.RLock()
+	aead, ok := k.keys[id]
+	k.lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", id)
+	}
+
+	nonceEnd := idEnd + aead.NonceSize()
+	if len(envelope) < nonceEnd {
+		return nil, errors.New("truncated envelope")
+	}
+
+	plaintext, err := aead.Open(nil, envelope[idEnd:nonceEnd], envelope[nonceEnd:], additional)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt with key %s: %w", id, err)
+	}
+
+	return plaintext, nil
+}
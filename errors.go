@@ -1,6 +1,15 @@
 package cache
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBackendUnavailable is returned by remote backends when the storage could not be reached.
+// Allows telling a cache outage apart from a cache miss
+var ErrBackendUnavailable = errors.New("cache backend is unavailable")
 
 type MissingEntryError struct {
 	key string
@@ -14,6 +23,11 @@ func (e MissingEntryError) Error() string {
 	return fmt.Sprintf("key %s is missing", e.key)
 }
 
+// Key returns the key the error is about
+func (e MissingEntryError) Key() string {
+	return e.key
+}
+
 type FailedToCastEntryError struct {
 	key string
 	err error
@@ -30,3 +44,137 @@ func (e FailedToCastEntryError) Error() string {
 
 	return fmt.Sprintf("could not cast value for key %s: interface{} could not be casted to output type", e.key)
 }
+
+// Key returns the key the error is about
+func (e FailedToCastEntryError) Key() string {
+	return e.key
+}
+
+type UnregisteredTypeError struct {
+	name string
+}
+
+func NewUnregisteredTypeError(name string) UnregisteredTypeError {
+	return UnregisteredTypeError{name: name}
+}
+
+func (e UnregisteredTypeError) Error() string {
+	return fmt.Sprintf("type %s is not registered", e.name)
+}
+
+type FrozenCacheError struct {
+	key string
+}
+
+func NewFrozenCacheError(key string) FrozenCacheError {
+	return FrozenCacheError{key: key}
+}
+
+func (e FrozenCacheError) Error() string {
+	return fmt.Sprintf("could not modify key %s: cache is frozen", e.key)
+}
+
+// Key returns the key the error is about
+func (e FrozenCacheError) Key() string {
+	return e.key
+}
+
+type StaleEntryError struct {
+	key string
+	age time.Duration
+}
+
+func NewStaleEntryError(key string, age time.Duration) StaleEntryError {
+	return StaleEntryError{key: key, age: age}
+}
+
+func (e StaleEntryError) Error() string {
+	return fmt.Sprintf("key %s expired %s ago", e.key, e.age)
+}
+
+// Key returns the key the error is about
+func (e StaleEntryError) Key() string {
+	return e.key
+}
+
+// Age returns how long ago the entry expired
+func (e StaleEntryError) Age() time.Duration {
+	return e.age
+}
+
+// ErrNotFound is returned by fetchers to report that the source has no value by the key, GetOrFetch of caches with
+// negative caching enabled remembers such results
+var ErrNotFound = errors.New("value not found")
+
+// ErrNotInteger is returned by counter operations of caches whose values are not integers
+var ErrNotInteger = errors.New("cached value is not an integer")
+
+// ErrCounterOverflow is returned by counter operations whose result does not fit the value type
+var ErrCounterOverflow = errors.New("counter overflow")
+
+// TooManyFetchesError is returned when a fetcher was shed because all the FetchLimiter slots are taken and its queue
+// is full
+//
+// Matches ErrFetchQueueFull with errors.Is
+type TooManyFetchesError struct {
+	maxConcurrent int
+	maxQueued     int
+}
+
+func NewTooManyFetchesError(maxConcurrent, maxQueued int) TooManyFetchesError {
+	return TooManyFetchesError{maxConcurrent: maxConcurrent, maxQueued: maxQueued}
+}
+
+func (e TooManyFetchesError) Error() string {
+	return fmt.Sprintf("too many fetches: %d running, %d queued", e.maxConcurrent, e.maxQueued)
+}
+
+func (e TooManyFetchesError) Unwrap() error {
+	return ErrFetchQueueFull
+}
+
+// FetchRateLimitedError is returned when a fetcher was rejected by a FetchRateLimiter
+type FetchRateLimitedError struct {
+	err error
+}
+
+func NewFetchRateLimitedError(err error) FetchRateLimitedError {
+	return FetchRateLimitedError{err: err}
+}
+
+func (e FetchRateLimitedError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("fetch rate limit exceeded: %s", e.err)
+	}
+
+	return "fetch rate limit exceeded"
+}
+
+func (e FetchRateLimitedError) Unwrap() error {
+	return e.err
+}
+
+// TimeoutError is returned by TimeoutCache operations which did not finish within the timeout
+//
+// Matches context.DeadlineExceeded and ErrBackendUnavailable with errors.Is
+type TimeoutError struct {
+	op      string
+	timeout time.Duration
+}
+
+func NewTimeoutError(op string, timeout time.Duration) TimeoutError {
+	return TimeoutError{op: op, timeout: timeout}
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("cache %s timed out after %s", e.op, e.timeout)
+}
+
+func (e TimeoutError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, ErrBackendUnavailable}
+}
+
+// Timeout returns the timeout the operation exceeded
+func (e TimeoutError) Timeout() time.Duration {
+	return e.timeout
+}
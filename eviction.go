@@ -0,0 +1,34 @@
+package cache
+
+// EvictionReason tells why an entry was removed from the cache
+type EvictionReason int
+
+const (
+	// ReasonExpired means the entry TTL has passed
+	ReasonExpired EvictionReason = iota + 1
+	// ReasonEvicted means the entry was removed to make room for other entries
+	ReasonEvicted
+	// ReasonDeleted means the entry was deleted, invalidated or cleared
+	ReasonDeleted
+	// ReasonReplaced means the entry was overwritten with a new value
+	ReasonReplaced
+)
+
+// String returns the reason name
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionFunc receives entries removed from the cache along with the reason of removal
+type EvictionFunc[T any] func(key string, value T, reason EvictionReason)
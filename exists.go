@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"errors"
+)
+
+// Exists reports whether a live entry by key is stored in the cache
+//
+// Uses the cache Exists if it implements ExistenceChecker, otherwise reads the value with Get
+func Exists[T any](ctx context.Context, c Cacher[T], key string) (bool, error) {
+	if checker, ok := c.(ExistenceChecker); ok {
+		return checker.Exists(ctx, key)
+	}
+
+	_, err := c.Get(ctx, key)
+	var missingEntryError MissingEntryError
+	if errors.As(err, &missingEntryError) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
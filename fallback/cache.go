@@ -0,0 +1,272 @@
+// Package fallback provides a cache serving from a secondary cache while the primary one fails
+//
+// Typical use: redis as the primary, inmem as the secondary keeping a local copy for redis outages
+package fallback
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+// Cache reads from the primary cache, falling back to the secondary one on misses and errors, and writes to both
+//
+// Writes are best-effort: they succeed if either cache accepted them. Deletes fail if either cache failed, as the
+// entry left there may be served later. Safe for concurrent usage if both caches are
+type Cache[T any] struct {
+	primary   cache.TTLCacher[T]
+	secondary cache.TTLCacher[T]
+
+	onError  func(err error)
+	degraded atomic.Bool
+
+	flights singleflight.Group[T]
+}
+
+// Option configures the fallback Cache
+type Option func(*options)
+
+type options struct {
+	onError func(err error)
+}
+
+// WithErrorHandler assigns the function called with the errors of either cache not returned to the caller, e.g. to log
+// primary failures served from the secondary cache
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// NewFallback creates a Cache composed of provided caches
+func NewFallback[T any](primary, secondary cache.TTLCacher[T], opts ...Option) *Cache[T] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Cache[T]{
+		primary:   primary,
+		secondary: secondary,
+		onError:   o.onError,
+	}
+}
+
+// Degraded reports whether the last primary call failed, so reads are served from the secondary cache
+func (c *Cache[T]) Degraded() bool {
+	return c.degraded.Load()
+}
+
+// Get retrieves an item from the primary cache, falling back to the secondary one if it is missing or the read fails
+//
+// Fails if both reads failed, misses are returned as cache.MissingEntryError
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	value, err := c.primary.Get(ctx, key)
+	if c.observe(err) == nil {
+		return value, nil
+	}
+
+	secondaryValue, secondaryErr := c.secondary.Get(ctx, key)
+	switch {
+	case secondaryErr == nil:
+		if !isMiss(err) {
+			c.report(err)
+		}
+		return secondaryValue, nil
+	case isMiss(secondaryErr):
+		return *new(T), err
+	case isMiss(err):
+		c.report(secondaryErr)
+		return *new(T), err
+	default:
+		return *new(T), errors.Join(err, secondaryErr)
+	}
+}
+
+// GetOrFetch obtains the value as Get does. If multiple callers are accessing the same key, later callers wait for the
+// result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to both caches. Read errors of both caches are returned as is. Honors
+// cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.Get(ctx, key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.Get(ctx, key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		if ttl != nil {
+			return result, c.SetWithTTL(ctx, key, result, *ttl)
+		}
+
+		return result, c.Set(ctx, key, result)
+	})
+}
+
+// GetMulti returns cached values found in the primary cache, reading the rest from the secondary one. All the keys are
+// read from the secondary cache if the primary read fails.
+// Result slice follows the order of keys and may have fewer items than keys, it means that items by that key were
+// not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	found := make(map[string]T, len(keys))
+
+	items, err := c.primary.GetMulti(ctx, keys)
+	if c.observe(err) == nil {
+		for _, item := range items {
+			found[item.Key] = item.Value
+		}
+	}
+
+	missing := make([]string, 0, len(keys)-len(found))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		items, secondaryErr := c.secondary.GetMulti(ctx, missing)
+		if err != nil && secondaryErr != nil {
+			return nil, errors.Join(err, secondaryErr)
+		}
+
+		c.report(err)
+		c.report(secondaryErr)
+
+		for _, item := range items {
+			found[item.Key] = item.Value
+		}
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(found))
+	for _, key := range keys {
+		value, ok := found[key]
+		if !ok {
+			continue
+		}
+
+		res = append(res, cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		})
+	}
+
+	return res, nil
+}
+
+// Set puts the provided value by cache key to both caches
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.write(c.observe(c.primary.Set(ctx, key, value)), c.secondary.Set(ctx, key, value))
+}
+
+// SetMulti puts provided k/v pairs to both caches
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	return c.write(c.observe(c.primary.SetMulti(ctx, kvs)), c.secondary.SetMulti(ctx, kvs))
+}
+
+// SetWithTTL puts provided value by cache key to both caches using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	return c.write(
+		c.observe(c.primary.SetWithTTL(ctx, key, value, ttl)),
+		c.secondary.SetWithTTL(ctx, key, value, ttl),
+	)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to both caches using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	return c.write(
+		c.observe(c.primary.SetMultiWithTTL(ctx, kvs, ttl)),
+		c.secondary.SetMultiWithTTL(ctx, kvs, ttl),
+	)
+}
+
+// Delete removes cached value by key from both caches
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	return errors.Join(c.observe(c.primary.Delete(ctx, key)), c.secondary.Delete(ctx, key))
+}
+
+// DeleteMulti removes cached values by keys from both caches
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return errors.Join(c.observe(c.primary.DeleteMulti(ctx, keys)), c.secondary.DeleteMulti(ctx, keys))
+}
+
+// write returns the joined errors if both caches failed the write, otherwise reports the failure, if any
+func (c *Cache[T]) write(primaryErr, secondaryErr error) error {
+	if primaryErr != nil && secondaryErr != nil {
+		return errors.Join(primaryErr, secondaryErr)
+	}
+
+	c.report(primaryErr)
+	c.report(secondaryErr)
+
+	return nil
+}
+
+// observe records whether the primary call failed, misses are not failures
+func (c *Cache[T]) observe(err error) error {
+	c.degraded.Store(err != nil && !isMiss(err))
+	return err
+}
+
+// report passes an error not returned to the caller to the error handler
+func (c *Cache[T]) report(err error) {
+	if err == nil || c.onError == nil {
+		return
+	}
+
+	c.onError(err)
+}
+
+// isMiss reports whether the read failed because the entry is missing
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
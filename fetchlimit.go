@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrFetchQueueFull is matched by TooManyFetchesError, returned when a fetcher could not be queued because the
+// FetchLimiter queue is full
+var ErrFetchQueueFull = errors.New("fetch queue is full")
+
+// FetchLimiter bounds the number of fetchers running simultaneously
+//
+// Fetchers over the limit wait in a queue of bounded length. A single limiter can be shared between caches to
+// bound the load on a common origin. A nil FetchLimiter does not limit anything
+type FetchLimiter struct {
+	slots     chan struct{}
+	maxQueued int64
+	queued    atomic.Int64
+}
+
+// NewFetchLimiter creates a FetchLimiter running up to maxConcurrent fetchers and queueing up to maxQueued more
+func NewFetchLimiter(maxConcurrent, maxQueued int) *FetchLimiter {
+	return &FetchLimiter{
+		slots:     make(chan struct{}, max(maxConcurrent, 1)),
+		maxQueued: int64(max(maxQueued, 0)),
+	}
+}
+
+// Acquire waits for a free fetch slot
+//
+// Returns TooManyFetchesError when the queue is full, or the context error if it is done while waiting.
+// The returned function must be called once the fetch is finished
+func (l *FetchLimiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	if l.queued.Add(1) > l.maxQueued {
+		l.queued.Add(-1)
+		return nil, NewTooManyFetchesError(cap(l.slots), int(l.maxQueued))
+	}
+	defer l.queued.Add(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *FetchLimiter) release() {
+	<-l.slots
+}
+
+// LimitFetch returns fetch guarded by the limiter
+func LimitFetch[T any](
+	l *FetchLimiter,
+	fetch func(ctx context.Context) (T, error),
+) func(ctx context.Context) (T, error) {
+	if l == nil {
+		return fetch
+	}
+
+	return func(ctx context.Context) (T, error) {
+		release, err := l.Acquire(ctx)
+		if err != nil {
+			return *new(T), err
+		}
+		defer release()
+
+		return fetch(ctx)
+	}
+}
@@ -0,0 +1,72 @@
+package cache
+
+import "context"
+
+// RateLimiter is a token bucket, e.g. *rate.Limiter of golang.org/x/time/rate
+type RateLimiter interface {
+	Allow() bool
+	Wait(ctx context.Context) error
+}
+
+// FetchRateLimiter bounds the rate of fetcher calls with a token bucket
+//
+// Fetchers over the rate either wait for a token or are rejected with FetchRateLimitedError. A single limiter can be
+// shared between caches to bound the load on a common origin. A nil FetchRateLimiter does not limit anything
+type FetchRateLimiter struct {
+	limiter RateLimiter
+	wait    bool
+}
+
+// NewFetchRateLimiter creates a FetchRateLimiter taking a token of the limiter per fetch
+//
+// If wait is set fetchers wait for a token until their context is done, otherwise they are rejected immediately
+func NewFetchRateLimiter(limiter RateLimiter, wait bool) *FetchRateLimiter {
+	return &FetchRateLimiter{
+		limiter: limiter,
+		wait:    wait,
+	}
+}
+
+// Acquire takes a token, waiting for it if configured so
+//
+// Returns FetchRateLimitedError when no token is available or the wait would outlast the context deadline, or the
+// context error if it is done while waiting
+func (l *FetchRateLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	if !l.wait {
+		if !l.limiter.Allow() {
+			return NewFetchRateLimitedError(nil)
+		}
+		return nil
+	}
+
+	if err := l.limiter.Wait(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return NewFetchRateLimitedError(err)
+	}
+
+	return nil
+}
+
+// RateLimitFetch returns fetch guarded by the rate limiter
+func RateLimitFetch[T any](
+	l *FetchRateLimiter,
+	fetch func(ctx context.Context) (T, error),
+) func(ctx context.Context) (T, error) {
+	if l == nil {
+		return fetch
+	}
+
+	return func(ctx context.Context) (T, error) {
+		if err := l.Acquire(ctx); err != nil {
+			return *new(T), err
+		}
+
+		return fetch(ctx)
+	}
+}
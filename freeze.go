@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// Freeze creates an immutable point-in-time snapshot of the provided cache
+//
+// The cache must implement KeyLister. The snapshot serves reads independently of subsequent mutations to
+// the original cache, entries never expire. Writes and deletes return FrozenCacheError
+func Freeze[T any](ctx context.Context, c Cacher[T]) (Cacher[T], error) {
+	lister, ok := c.(KeyLister)
+	if !ok {
+		return nil, fmt.Errorf("could not freeze cache: %T does not list keys", c)
+	}
+
+	keys, err := lister.Keys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not freeze cache: failed to list keys: %w", err)
+	}
+
+	items, err := c.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not freeze cache: failed to read values: %w", err)
+	}
+
+	return &frozenCache[T]{entries: AsMap(items)}, nil
+}
+
+type frozenCache[T any] struct {
+	entries map[string]T
+}
+
+func (c *frozenCache[T]) Get(_ context.Context, key string) (T, error) {
+	value, ok := c.entries[key]
+	if !ok {
+		return *new(T), NewMissingEntryError(key)
+	}
+
+	return value, nil
+}
+
+func (c *frozenCache[T]) Exists(_ context.Context, key string) (bool, error) {
+	_, ok := c.entries[key]
+	return ok, nil
+}
+
+func (c *frozenCache[T]) Set(_ context.Context, key string, _ T) error {
+	return NewFrozenCacheError(key)
+}
+
+func (c *frozenCache[T]) GetMulti(_ context.Context, keys []string) ([]StorageItemMulti[T], error) {
+	res := make([]StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, ok := c.entries[key]
+		if !ok {
+			continue
+		}
+
+		res = append(res, StorageItemMulti[T]{Key: key, Value: value})
+	}
+
+	return res, nil
+}
+
+func (c *frozenCache[T]) SetMulti(_ context.Context, kvs []StorageItemMulti[T]) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	return NewFrozenCacheError(kvs[0].Key)
+}
+
+func (c *frozenCache[T]) Delete(_ context.Context, key string) error {
+	return NewFrozenCacheError(key)
+}
+
+func (c *frozenCache[T]) DeleteMulti(_ context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return NewFrozenCacheError(keys[0])
+}
+
+func (c *frozenCache[T]) Keys(_ context.Context) ([]string, error) {
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
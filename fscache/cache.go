@@ -0,0 +1,557 @@
+// Package fscache provides a cache storing every entry as a file, for large blobs which do not belong in memory or
+// redis, e.g. rendered documents
+//
+// Entries are kept in directories sharded by the hash of their keys. Every entry is a data file holding the value as
+// is and a sidecar metadata file holding its key and expiration time. Both are written to temporary files renamed
+// into place, so readers never see partially written files. Use cache.Typed or NewTypedCache to store other types
+package fscache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+const (
+	metaSuffix = ".meta"
+	tempPrefix = ".tmp-"
+
+	// staleTempAge is the age of temporary files after which they are considered left by crashed writers
+	staleTempAge = time.Hour
+)
+
+// meta is the content of the sidecar metadata file
+type meta struct {
+	Key       string     `json:"key"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (m meta) expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// Cache represents a cache storing entries as files in a directory
+//
+// Expired entries are removed when read, by Cleanup or in background with WithCleanupInterval. Safe for concurrent
+// usage within a process, concurrent writes of the same key by several processes leave one of the values
+type Cache struct {
+	dir        string
+	defaultTTL *time.Duration
+	sync       bool
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[[]byte]
+
+	mu          sync.Mutex
+	stopCleanup chan struct{}
+}
+
+// NewCache creates a Cache instance storing entries in dir, creating it if needed
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create cache directory: %w", err)
+	}
+
+	return &Cache{dir: dir}, nil
+}
+
+// NewTypedCache creates a Cache instance storing entries in dir, converting values with the codec
+func NewTypedCache[T any](dir string, codec cache.Codec[T]) (cache.Cacher[T], error) {
+	c, err := NewCache(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.Typed[T](c, codec), nil
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache) WithTTL(ttl time.Duration) *Cache {
+	c.defaultTTL = &ttl
+	return c
+}
+
+// WithSync makes writes flush files to the disk before renaming them into place, so entries survive power loss
+//
+// Slows writes down considerably
+func (c *Cache) WithSync() *Cache {
+	c.sync = true
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache) WithMaxConcurrentFetches(n int) *Cache {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// WithCleanupInterval starts removing expired entries and temporary files left by crashed writers in background every
+// interval, so entries which are never read again do not hold disk space forever
+//
+// Replaces the previous cleanup, if any. Call Close to stop cleaning up
+func (c *Cache) WithCleanupInterval(interval time.Duration) *Cache {
+	c.stopCleaner()
+
+	c.mu.Lock()
+	c.stopCleanup = make(chan struct{})
+	go c.cleanup(interval, c.stopCleanup)
+	c.mu.Unlock()
+
+	return c
+}
+
+// Close stops the background cleanup, if any. Stored entries are kept
+func (c *Cache) Close() {
+	c.stopCleaner()
+}
+
+// Dir returns the directory entries are stored in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	if cache.IsBypassed(ctx) {
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	return c.get(key)
+}
+
+// GetOrFetch tries to obtain cached value from the directory. If multiple callers are accessing the same key, later
+// callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) ([]byte, error),
+) ([]byte, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) ([]byte, error),
+) ([]byte, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) ([]byte, error),
+	ttl *time.Duration,
+) ([]byte, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.get(key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.get(key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			err = c.set(key, result, ttl)
+		}
+
+		return result, err
+	})
+}
+
+// Set puts the provided value by cache key to the directory
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache) Set(ctx context.Context, key string, value []byte) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(key, value, nil)
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[[]byte], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[[]byte]{}, nil
+	}
+
+	res := make([]cache.StorageItemMulti[[]byte], 0, len(keys))
+	for _, key := range keys {
+		val, err := c.get(key)
+		if isMiss(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		item := cache.StorageItemMulti[[]byte]{
+			Key:   key,
+			Value: val,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to the directory
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetMultiWithTTL
+func (c *Cache) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[[]byte]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	for _, kv := range kvs {
+		if err := c.set(kv.Key, kv.Value, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes cached value by key
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.remove(c.path(key))
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache) DeleteMulti(ctx context.Context, keys []string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := c.remove(c.path(key)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+func (c *Cache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(key, value, &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+func (c *Cache) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[[]byte], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	for _, kv := range kvs {
+		if err := c.set(kv.Key, kv.Value, &ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keys returns slice of stored keys
+//
+// The order of keys are not guaranteed. Walks the whole directory, expired entries are skipped
+func (c *Cache) Keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	now := time.Now()
+	err := c.walk(ctx, func(path string, m meta) error {
+		if !m.expired(now) {
+			keys = append(keys, m.Key)
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
+
+// Cleanup removes expired entries and temporary files left by crashed writers. Returns the number of removed entries
+func (c *Cache) Cleanup(ctx context.Context) (int, error) {
+	removed := 0
+	now := time.Now()
+	err := c.walk(ctx, func(path string, m meta) error {
+		if !m.expired(now) {
+			return nil
+		}
+
+		if err := c.remove(path); err != nil {
+			return err
+		}
+		removed++
+
+		return nil
+	})
+
+	return removed, err
+}
+
+func (c *Cache) get(key string) ([]byte, error) {
+	path := c.path(key)
+
+	m, err := readMeta(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Key != key {
+		// sha256 collisions are not expected, the file was written by something else
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	if m.expired(time.Now()) {
+		_ = c.remove(path)
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, cache.NewMissingEntryError(key) // deleted in the meantime
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read entry by key %s: %w", key, err)
+	}
+
+	return data, nil
+}
+
+func (c *Cache) set(key string, value []byte, ttl *time.Duration) error {
+	if ttl == nil {
+		ttl = c.defaultTTL
+	}
+
+	m := meta{Key: key}
+	if ttl != nil && *ttl > 0 {
+		expiresAt := time.Now().Add(*ttl)
+		m.ExpiresAt = &expiresAt
+	}
+
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not create shard directory: %w", err)
+	}
+
+	// the data goes first, so the metadata never describes a missing file
+	if err := c.write(path, value); err != nil {
+		return fmt.Errorf("could not write entry by key %s: %w", key, err)
+	}
+
+	if err := c.write(path+metaSuffix, encoded); err != nil {
+		return fmt.Errorf("could not write metadata of key %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// write replaces the file at path atomically with a temporary file renamed into place
+func (c *Cache) write(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil && c.sync {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// remove deletes the entry at path, the metadata first so the entry is not listed while removed
+func (c *Cache) remove(path string) error {
+	var errs []error
+	for _, p := range []string{path + metaSuffix, path} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// walk calls fn with the data file path and metadata of every stored entry, removing stale temporary files
+func (c *Cache) walk(ctx context.Context, fn func(path string, m meta) error) error {
+	now := time.Now()
+
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // removed in the meantime
+			}
+
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := d.Name()
+		switch {
+		case d.IsDir():
+			return nil
+		case strings.HasPrefix(name, tempPrefix):
+			if info, err := d.Info(); err == nil && now.Sub(info.ModTime()) > staleTempAge {
+				_ = os.Remove(path)
+			}
+			return nil
+		case !strings.HasSuffix(name, metaSuffix):
+			return nil
+		}
+
+		dataPath := strings.TrimSuffix(path, metaSuffix)
+		m, err := readMeta(dataPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return fn(dataPath, m)
+	})
+}
+
+// path returns the data file path of the key, sharded by the first two bytes of its hash
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+
+	return filepath.Join(c.dir, name[:2], name[2:4], name)
+}
+
+func (c *Cache) cleanup(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, _ = c.Cleanup(context.Background())
+		}
+	}
+}
+
+func (c *Cache) stopCleaner() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopCleanup != nil {
+		close(c.stopCleanup)
+		c.stopCleanup = nil
+	}
+}
+
+func readMeta(path string) (meta, error) {
+	data, err := os.ReadFile(path + metaSuffix)
+	if err != nil {
+		return meta{}, err
+	}
+
+	var m meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return meta{}, fmt.Errorf("could not decode metadata of %s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
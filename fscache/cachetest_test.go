@@ -0,0 +1,20 @@
+package fscache_test
+
+import (
+	"testing"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/cachetest"
+	"github.com/sinu5oid/cache/fscache"
+)
+
+func TestCacher(t *testing.T) {
+	cachetest.RunCacherTests(t, func() cache.TTLCacher[[]byte] {
+		c, err := fscache.NewCache(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewCache() error = %v", err)
+		}
+
+		return c
+	})
+}
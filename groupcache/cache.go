@@ -0,0 +1,181 @@
+// Package groupcache provides a typed read-through cache shared by a fleet of processes without an external server,
+// based on github.com/golang/groupcache
+//
+// Every key is owned by a single peer picked by consistent hashing. The owner loads the value with the loader once and
+// serves it to other peers over HTTP, which keep hot keys in a local replica. Entries are immutable: groupcache has no
+// writes, deletes or TTLs, entries are only evicted once the group exceeds its size. Values are converted to bytes
+// with a codec
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang/groupcache"
+
+	"github.com/sinu5oid/cache"
+)
+
+// ErrImmutable is returned by writes and deletes, groupcache entries can not be changed once loaded
+var ErrImmutable = fmt.Errorf("groupcache entries are immutable: %w", errors.ErrUnsupported)
+
+// Cache represents typed groupcache group wrapped
+//
+// Safe for concurrent usage
+type Cache[T any] struct {
+	group  *groupcache.Group
+	loader cache.Loader[T]
+	codec  cache.Codec[T]
+}
+
+// NewCache creates a Cache instance registering the groupcache group by name, keeping up to cacheBytes of values in
+// this process, and loading missing values with the loader
+//
+// Group names are process-wide, creating two caches with the same name panics. Peers have to create the group with the
+// same name to serve its keys
+func NewCache[T any](name string, cacheBytes int64, loader cache.Loader[T], codec cache.Codec[T]) *Cache[T] {
+	c := &Cache[T]{
+		loader: loader,
+		codec:  codec,
+	}
+	c.group = groupcache.NewGroup(name, cacheBytes, groupcache.GetterFunc(c.load))
+
+	return c
+}
+
+// NewBytesCache creates a Cache instance storing byte slices as is
+func NewBytesCache(name string, cacheBytes int64, loader cache.Loader[[]byte]) *Cache[[]byte] {
+	return NewCache[[]byte](name, cacheBytes, loader, bytesCodec{})
+}
+
+// NewPool creates the HTTP pool serving the groups of this process to peers and picking the owners of keys among
+// peers with consistent hashing. self and peers are base URLs, e.g. "http://10.0.0.1:8080", self has to be one of peers
+//
+// The pool is process-wide, NewPool must be called once. Serve it under groupcache's default base path
+// "/_groupcache/", and call Set on the pool when the fleet changes
+func NewPool(self string, peers ...string) *groupcache.HTTPPool {
+	pool := groupcache.NewHTTPPool(self)
+	pool.Set(peers...)
+
+	return pool
+}
+
+// Group returns the wrapped groupcache group
+func (c *Cache[T]) Group() *groupcache.Group {
+	return c.group
+}
+
+// Get retrieves an item by key, loading it on the owning peer if it was not found
+//
+// Reports values the loader returned cache.ErrNotFound for as missing. Honors cache.WithBypass context marker by
+// calling the loader directly
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return cache.LoadFetcher(c.loader, key)(ctx)
+	}
+
+	var data []byte
+	if err := c.group.Get(ctx, key, groupcache.AllocatingByteSliceSink(&data)); err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return *new(T), fmt.Errorf("%w: %w", cache.NewMissingEntryError(key), err)
+		}
+
+		return *new(T), err
+	}
+
+	return c.codec.Unmarshal(data)
+}
+
+// GetMulti returns cached values by provided keys, loading missing ones.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, err := c.Get(ctx, key)
+		if isMiss(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// Set returns ErrImmutable
+func (c *Cache[T]) Set(_ context.Context, _ string, _ T) error {
+	return ErrImmutable
+}
+
+// SetMulti returns ErrImmutable
+func (c *Cache[T]) SetMulti(_ context.Context, _ []cache.StorageItemMulti[T]) error {
+	return ErrImmutable
+}
+
+// Delete returns ErrImmutable
+func (c *Cache[T]) Delete(_ context.Context, _ string) error {
+	return ErrImmutable
+}
+
+// DeleteMulti returns ErrImmutable
+func (c *Cache[T]) DeleteMulti(_ context.Context, _ []string) error {
+	return ErrImmutable
+}
+
+// Stats returns the counters of the group, including the replicas of keys owned by other peers
+//
+// Sets counts values loaded by this process
+func (c *Cache[T]) Stats(_ context.Context) (cache.Stats, error) {
+	main := c.group.CacheStats(groupcache.MainCache)
+	hot := c.group.CacheStats(groupcache.HotCache)
+	gets := c.group.Stats.Gets.Get()
+	hits := c.group.Stats.CacheHits.Get()
+
+	return cache.Stats{
+		Hits:      uint64(hits),
+		Misses:    uint64(gets - hits),
+		Sets:      uint64(c.group.Stats.LocalLoads.Get()),
+		Evictions: uint64(main.Evictions + hot.Evictions),
+		Entries:   main.Items + hot.Items,
+	}, nil
+}
+
+// load is the groupcache getter, encoding values returned by the loader
+func (c *Cache[T]) load(ctx context.Context, key string, dest groupcache.Sink) error {
+	value, err := c.loader.Load(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not marshal value by key %s: %w", key, err)
+	}
+
+	return dest.SetBytes(data)
+}
+
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
+
+// bytesCodec stores byte slices as is
+type bytesCodec struct{}
+
+func (bytesCodec) Marshal(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (bytesCodec) Unmarshal(data []byte) ([]byte, error) {
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// minHealthSamples is the number of calls needed within an interval before the error rate is taken into account
+const minHealthSamples = 10
+
+// HealthMonitor tracks the health of a remote backend
+//
+// Every interval the backend is probed and the share of regular calls that failed with ErrBackendUnavailable is
+// compared against the threshold. The backend is unhealthy if either check fails. Listeners are notified on changes
+// only. A nil HealthMonitor ignores observations
+type HealthMonitor struct {
+	probe        func(ctx context.Context) error
+	interval     time.Duration
+	maxErrorRate float64
+
+	mu        sync.Mutex
+	healthy   bool
+	calls     int
+	failures  int
+	listeners []func(healthy bool)
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewHealthMonitor creates a HealthMonitor and starts probing the backend. The backend is assumed healthy initially
+//
+// probe may be nil, then health is derived from the error rate only. maxErrorRate is a share of failed calls in [0, 1]
+func NewHealthMonitor(
+	probe func(ctx context.Context) error,
+	interval time.Duration,
+	maxErrorRate float64,
+) *HealthMonitor {
+	m := &HealthMonitor{
+		probe:        probe,
+		interval:     interval,
+		maxErrorRate: maxErrorRate,
+		healthy:      true,
+		stop:         make(chan struct{}),
+	}
+
+	go m.run()
+
+	return m
+}
+
+// OnHealthChange registers a function called with the new state every time the backend health changes
+func (m *HealthMonitor) OnHealthChange(fn func(healthy bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.listeners = append(m.listeners, fn)
+}
+
+// Observe records the outcome of a regular backend call
+func (m *HealthMonitor) Observe(err error) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	if errors.Is(err, ErrBackendUnavailable) {
+		m.failures++
+	}
+}
+
+// Healthy reports the backend state as of the latest check
+func (m *HealthMonitor) Healthy() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.healthy
+}
+
+// Close stops probing the backend
+func (m *HealthMonitor) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
+func (m *HealthMonitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+func (m *HealthMonitor) check() {
+	healthy := true
+	if m.probe != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+		healthy = m.probe(ctx) == nil
+		cancel()
+	}
+
+	m.mu.Lock()
+	if m.calls >= minHealthSamples && float64(m.failures)/float64(m.calls) > m.maxErrorRate {
+		healthy = false
+	}
+	m.calls, m.failures = 0, 0
+
+	changed := m.healthy != healthy
+	m.healthy = healthy
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	for _, fn := range listeners {
+		fn(healthy)
+	}
+}
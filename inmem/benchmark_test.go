@@ -0,0 +1,45 @@
+package inmem_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/sinu5oid/cache/inmem"
+)
+
+// BenchmarkGetSet measures parallel access with 75% reads and 25% writes over a fixed set of keys
+func BenchmarkGetSet(b *testing.B) {
+	const keyCount = 1024
+
+	keys := make([]string, keyCount)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+
+	for _, shards := range []int{1, 16} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			ctx := context.Background()
+			c := inmem.New[int](inmem.WithShards(shards))
+			for i, key := range keys {
+				_ = c.Set(ctx, key, i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := keys[i%keyCount]
+					if i%4 == 0 {
+						_ = c.Set(ctx, key, i)
+					} else {
+						_, _ = c.Get(ctx, key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
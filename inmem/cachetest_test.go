@@ -0,0 +1,25 @@
+package inmem_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/cachetest"
+	"github.com/sinu5oid/cache/inmem"
+)
+
+func TestCacher(t *testing.T) {
+	cachetest.RunCacherTests(t, func() cache.TTLCacher[string] {
+		return inmem.New[string]()
+	})
+}
+
+func TestCacherSharded(t *testing.T) {
+	cachetest.RunCacherTests(t, func() cache.TTLCacher[string] {
+		c := inmem.New[string](inmem.WithShards(16), inmem.WithJanitor(10*time.Millisecond))
+		t.Cleanup(c.Close)
+
+		return c
+	})
+}
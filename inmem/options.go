@@ -0,0 +1,102 @@
+package inmem
+
+import (
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/keylock"
+)
+
+// Option configures the Cache created by New
+type Option func(*config)
+
+type config struct {
+	ttl        *time.Duration
+	maxEntries int
+	shards     int
+	janitor    time.Duration
+	now        func() time.Time
+
+	statsInterval time.Duration
+	statsReport   func(stats cache.Stats)
+}
+
+// WithTTL assigns the default TTL, see Cache.WithTTL
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = &ttl
+	}
+}
+
+// WithSize caps the number of stored entries, see Cache.WithMaxEntries
+func WithSize(n int) Option {
+	return func(c *config) {
+		c.maxEntries = n
+	}
+}
+
+// WithShards splits the storage into n shards, see Cache.WithShards
+func WithShards(n int) Option {
+	return func(c *config) {
+		c.shards = n
+	}
+}
+
+// WithJanitor removes expired entries in background every interval, see Cache.WithCleanupInterval
+func WithJanitor(interval time.Duration) Option {
+	return func(c *config) {
+		c.janitor = interval
+	}
+}
+
+// WithClock assigns the function returning the current time, see Cache.WithClock
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+// WithStats calls report with the cache Stats every interval, see Cache.WithStatsReporter
+func WithStats(interval time.Duration, report func(stats cache.Stats)) Option {
+	return func(c *config) {
+		c.statsInterval = interval
+		c.statsReport = report
+	}
+}
+
+// New creates a Cache configured with the options
+//
+// Options cover the common settings, the rest are assigned with the Cache methods. Call Close if WithJanitor is used
+func New[T any](opts ...Option) *Cache[T] {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	c := &Cache[T]{
+		storage:    newShardedMap[T](defaultShardCount),
+		locks:      keylock.New(),
+		updates:    keylock.New(),
+		defaultTTL: cfg.ttl,
+		maxEntries: cfg.maxEntries,
+		now:        time.Now,
+	}
+
+	if cfg.now != nil {
+		c.WithClock(cfg.now)
+	}
+
+	if cfg.shards > 0 {
+		c.WithShards(cfg.shards)
+	}
+
+	if cfg.janitor > 0 {
+		c.WithCleanupInterval(cfg.janitor)
+	}
+
+	if cfg.statsInterval > 0 && cfg.statsReport != nil {
+		c.WithStatsReporter(cfg.statsInterval, cfg.statsReport)
+	}
+
+	return c
+}
@@ -0,0 +1,76 @@
+package inmem_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/inmem"
+)
+
+// fakeClock is a clock advanced manually
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestWithClock(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := inmem.New[int](inmem.WithClock(clock.Now))
+
+	if err := c.SetWithTTL(ctx, "key", 1, time.Hour); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+
+	clock.Advance(59 * time.Minute)
+	if got, err := c.Get(ctx, "key"); err != nil || got != 1 {
+		t.Errorf("Get() before expiration = %v, %v, want 1", got, err)
+	}
+
+	clock.Advance(time.Minute)
+	var missingEntryError cache.MissingEntryError
+	if _, err := c.Get(ctx, "key"); !errors.As(err, &missingEntryError) {
+		t.Errorf("Get() after expiration error = %v, want cache.MissingEntryError", err)
+	}
+}
+
+func TestWithStats(t *testing.T) {
+	ctx := context.Background()
+	reports := make(chan cache.Stats, 1)
+	c := inmem.New[int](inmem.WithStats(time.Millisecond, func(stats cache.Stats) { reports <- stats }))
+	defer c.Close()
+
+	if err := c.Set(ctx, "key", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case stats := <-reports:
+			if stats.Sets == 1 && stats.Entries == 1 {
+				return
+			}
+		case <-deadline:
+			t.Fatal("no stats reported after the write")
+		}
+	}
+}
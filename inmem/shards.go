@@ -0,0 +1,300 @@
+package inmem
+
+import (
+	"hash/maphash"
+	"iter"
+	"maps"
+	"math/bits"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultShardCount is the number of shards the storage is split into
+const defaultShardCount = 32
+
+// shardedMap is a typed concurrent map split into shards guarded by their own locks
+//
+// Unlike sync.Map stores entries without boxing them into interfaces
+type shardedMap[T any] struct {
+	seed   maphash.Seed
+	mask   uint64
+	shards []*shard[T]
+
+	len  atomic.Int64
+	cost atomic.Int64
+}
+
+type shard[T any] struct {
+	mu    sync.RWMutex
+	items map[string]withTTL[T]
+}
+
+// newShardedMap creates a shardedMap with the shard count rounded up to a power of two
+func newShardedMap[T any](count int) *shardedMap[T] {
+	count = 1 << bits.Len(uint(max(count, 1)-1))
+
+	shards := make([]*shard[T], count)
+	for i := range shards {
+		shards[i] = &shard[T]{items: make(map[string]withTTL[T])}
+	}
+
+	return &shardedMap[T]{
+		seed:   maphash.MakeSeed(),
+		mask:   uint64(count - 1),
+		shards: shards,
+	}
+}
+
+func (m *shardedMap[T]) shard(key string) *shard[T] {
+	return m.shards[maphash.String(m.seed, key)&m.mask]
+}
+
+// Load returns the entry by key
+func (m *shardedMap[T]) Load(key string) (withTTL[T], bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.items[key]
+	return entry, ok
+}
+
+// Store puts the entry by key
+func (m *shardedMap[T]) Store(key string, entry withTTL[T]) {
+	m.Swap(key, entry)
+}
+
+// Swap puts the entry by key, returning the replaced entry if any
+func (m *shardedMap[T]) Swap(key string, entry withTTL[T]) (withTTL[T], bool) {
+	previous, replaced, _ := m.SwapIf(key, entry, nil)
+	return previous, replaced
+}
+
+// SwapIf puts the entry by key if cond approves the stored entry, or its absence. cond is called under the shard lock,
+// nil cond approves any. Returns the replaced entry if any and whether the entry was stored
+func (m *shardedMap[T]) SwapIf(
+	key string,
+	entry withTTL[T],
+	cond func(previous withTTL[T], ok bool) bool,
+) (withTTL[T], bool, bool) {
+	return m.Compute(key, func(previous withTTL[T], ok bool) (withTTL[T], bool) {
+		return entry, cond == nil || cond(previous, ok)
+	})
+}
+
+// Compute puts the entry f returns for the stored entry, or its absence, unless f reports false. f is called under
+// the shard lock. Returns the replaced entry if any and whether the entry was stored
+func (m *shardedMap[T]) Compute(
+	key string,
+	f func(previous withTTL[T], ok bool) (withTTL[T], bool),
+) (withTTL[T], bool, bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.items[key]
+	entry, store := f(previous, ok)
+	if !store {
+		return withTTL[T]{}, false, false
+	}
+
+	s.items[key] = entry
+	m.added(entry)
+	if ok {
+		m.removed(previous)
+	}
+
+	return previous, ok, true
+}
+
+// Update replaces the stored entry by key with the one f returns unless f reports false. f is called under the shard
+// lock. Reports whether the entry was replaced
+func (m *shardedMap[T]) Update(key string, f func(entry withTTL[T]) (withTTL[T], bool)) bool {
+	_, _, updated := m.Compute(key, func(previous withTTL[T], ok bool) (withTTL[T], bool) {
+		if !ok {
+			return previous, false
+		}
+
+		return f(previous)
+	})
+
+	return updated
+}
+
+// LoadAndDelete removes the entry by key, returning the removed entry if any
+func (m *shardedMap[T]) LoadAndDelete(key string) (withTTL[T], bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.items[key]
+	if ok {
+		delete(s.items, key)
+		m.removed(entry)
+	}
+
+	return entry, ok
+}
+
+// CompareAndDelete removes the entry by key if it was written at the provided time
+func (m *shardedMap[T]) CompareAndDelete(key string, updatedAt time.Time) bool {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.items[key]
+	if !ok || !entry.UpdatedAt.Equal(updatedAt) {
+		return false
+	}
+
+	delete(s.items, key)
+	m.removed(entry)
+
+	return true
+}
+
+// Delete removes the entry by key
+func (m *shardedMap[T]) Delete(key string) {
+	m.LoadAndDelete(key)
+}
+
+// Range calls f for every entry until it returns false. Shards are locked for reading one at a time,
+// f must not modify the map
+func (m *shardedMap[T]) Range(f func(key string, entry withTTL[T]) bool) {
+	for _, s := range m.shards {
+		s.mu.RLock()
+		for key, entry := range s.items {
+			if !f(key, entry) {
+				s.mu.RUnlock()
+				return
+			}
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// All iterates over the entries, copying the entries of a shard under its lock, so the map may be modified while
+// iterating. Entries stored or removed during iteration may be yielded or not
+func (m *shardedMap[T]) All() iter.Seq2[string, withTTL[T]] {
+	return func(yield func(string, withTTL[T]) bool) {
+		var entries map[string]withTTL[T]
+		for _, s := range m.shards {
+			s.mu.RLock()
+			entries = maps.Clone(s.items)
+			s.mu.RUnlock()
+
+			for key, entry := range entries {
+				if !yield(key, entry) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Clear removes all the entries
+func (m *shardedMap[T]) Clear() {
+	for _, s := range m.shards {
+		s.mu.Lock()
+		for _, entry := range s.items {
+			m.removed(entry)
+		}
+		clear(s.items)
+		s.mu.Unlock()
+	}
+}
+
+// DeleteFunc removes the entries del returns true for, calling removed for every removed one. Shards are locked one
+// at a time, removed is called with no locks held
+func (m *shardedMap[T]) DeleteFunc(
+	del func(key string, entry withTTL[T]) bool,
+	removed func(key string, entry withTTL[T]),
+) {
+	var deleted map[string]withTTL[T]
+	for _, s := range m.shards {
+		s.mu.Lock()
+		for key, entry := range s.items {
+			if !del(key, entry) {
+				continue
+			}
+
+			if deleted == nil {
+				deleted = make(map[string]withTTL[T])
+			}
+			deleted[key] = entry
+			delete(s.items, key)
+			m.removed(entry)
+		}
+		s.mu.Unlock()
+
+		for key, entry := range deleted {
+			removed(key, entry)
+		}
+		clear(deleted)
+	}
+}
+
+// Drain removes all the entries, calling f for every removed one. Shards are drained one at a time,
+// f is called with no locks held
+func (m *shardedMap[T]) Drain(f func(key string, entry withTTL[T])) {
+	for _, s := range m.shards {
+		s.mu.Lock()
+		items := s.items
+		s.items = make(map[string]withTTL[T])
+		for _, entry := range items {
+			m.removed(entry)
+		}
+		s.mu.Unlock()
+
+		for key, entry := range items {
+			f(key, entry)
+		}
+	}
+}
+
+// Sample returns up to n entries starting from a random shard. Entries of a shard come in random order
+func (m *shardedMap[T]) Sample(n int) map[string]withTTL[T] {
+	sample := make(map[string]withTTL[T], n)
+
+	start := rand.IntN(len(m.shards))
+	for i := range m.shards {
+		s := m.shards[(start+i)%len(m.shards)]
+
+		s.mu.RLock()
+		for key, entry := range s.items {
+			if len(sample) == n {
+				break
+			}
+			sample[key] = entry
+		}
+		s.mu.RUnlock()
+
+		if len(sample) == n {
+			break
+		}
+	}
+
+	return sample
+}
+
+// Len returns the number of stored entries, including expired ones not removed yet
+func (m *shardedMap[T]) Len() int {
+	return int(m.len.Load())
+}
+
+// Cost returns the total cost of stored entries, including expired ones not removed yet
+func (m *shardedMap[T]) Cost() int64 {
+	return m.cost.Load()
+}
+
+func (m *shardedMap[T]) added(entry withTTL[T]) {
+	m.len.Add(1)
+	m.cost.Add(entry.Cost)
+}
+
+func (m *shardedMap[T]) removed(entry withTTL[T]) {
+	m.len.Add(-1)
+	m.cost.Add(-entry.Cost)
+}
@@ -0,0 +1,62 @@
+package inmem
+
+import (
+	"io"
+
+	"github.com/sinu5oid/cache/internal/snapshot"
+)
+
+// SaveTo writes live entries to w as JSON lines, along with their expiration time, so a restarted process can
+// restore them with LoadFrom
+//
+// Values are encoded with encoding/json. The cache may be used while saving, entries written meanwhile may be saved
+// or not
+func (c *Cache[T]) SaveTo(w io.Writer) error {
+	return snapshot.Write(w, func(yield func(snapshot.Entry[T]) bool) {
+		now := c.now()
+		for key, entry := range c.storage.All() {
+			if entry.expired(now) {
+				continue
+			}
+
+			if !yield(toSnapshot(key, entry)) {
+				return
+			}
+		}
+	})
+}
+
+// LoadFrom restores entries written by SaveTo from r, keeping their expiration time
+//
+// Entries expired since they were saved are skipped, the ones saved without expiration get the default TTL.
+// Restored entries are not published to the invalidator
+func (c *Cache[T]) LoadFrom(r io.Reader) error {
+	return snapshot.Read(r, func(entry snapshot.Entry[T]) error {
+		if entry.ExpiresAt == nil {
+			c.set(entry.Key, entry.Value, nil)
+			return nil
+		}
+
+		ttl := entry.ExpiresAt.Sub(c.now())
+		if ttl <= 0 {
+			return nil
+		}
+
+		c.set(entry.Key, entry.Value, &ttl)
+		return nil
+	})
+}
+
+func toSnapshot[T any](key string, entry withTTL[T]) snapshot.Entry[T] {
+	res := snapshot.Entry[T]{
+		Key:   key,
+		Value: entry.Value,
+	}
+
+	if entry.TTL != nil {
+		expiresAt := entry.UpdatedAt.Add(*entry.TTL)
+		res.ExpiresAt = &expiresAt
+	}
+
+	return res
+}
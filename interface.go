@@ -6,6 +6,7 @@ package cache
 
 import (
 	"context"
+	"iter"
 	"time"
 )
 
@@ -17,17 +18,59 @@ type StorageItemMulti[T any] struct {
 	Value T
 }
 
+// NoExpiration is reported as the remaining TTL of entries that never expire
+const NoExpiration time.Duration = -1
+
+// StorageItemMultiWithTTL is a util struct describing key and value along with the remaining time-to-live
+//
+// Actively used by TTLMultiGetter.GetMultiWithTTL. TTL is NoExpiration for entries that never expire
+type StorageItemMultiWithTTL[T any] struct {
+	Key   string
+	Value T
+	TTL   time.Duration
+}
+
+// Entry describes a stored value along with its metadata
+//
+// Actively used by EntryGetter.GetEntry. TTL and Remaining are NoExpiration for entries that never expire. Hits and
+// LastAccess are only tracked by caches configured to, otherwise they are zero
+type Entry[T any] struct {
+	Key        string
+	Value      T
+	UpdatedAt  time.Time     // time the value was written
+	TTL        time.Duration // TTL the value was written with
+	Remaining  time.Duration // remaining TTL
+	LastAccess time.Time     // time of the latest read, zero if the value was never read
+	Hits       int64         // number of reads since the value was written
+}
+
 type Cacher[T any] interface {
 	Get(ctx context.Context, key string) (T, error)
 	Set(ctx context.Context, key string, value T) error
 	GetMulti(ctx context.Context, keys []string) ([]StorageItemMulti[T], error)
 	SetMulti(ctx context.Context, kvs []StorageItemMulti[T]) error
 	Delete(ctx context.Context, key string) error
+	DeleteMulti(ctx context.Context, keys []string) error
 }
 
 type FetchingCacher[T any] interface {
 	Cacher[T]
-	GetOrFetch(ctx context.Context, key string, fetch func() (T, error)) (T, error)
+	GetOrFetch(ctx context.Context, key string, fetch func(ctx context.Context) (T, error)) (T, error)
+	GetOrFetchWithTTL(
+		ctx context.Context,
+		key string,
+		ttl time.Duration,
+		fetch func(ctx context.Context) (T, error),
+	) (T, error)
+}
+
+type BatchFetchingCacher[T any] interface {
+	Cacher[T]
+	GetOrFetchMulti(
+		ctx context.Context,
+		keys []string,
+		fetch func(ctx context.Context, missing []string) ([]StorageItemMulti[T], error),
+	) ([]StorageItemMulti[T], error)
 }
 
 type TTLCacher[T any] interface {
@@ -35,3 +78,91 @@ type TTLCacher[T any] interface {
 	SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error
 	SetMultiWithTTL(ctx context.Context, kvs []StorageItemMulti[T], ttl time.Duration) error
 }
+
+// Toucher is implemented by caches able to renew the TTL of an entry without rewriting its value
+type Toucher interface {
+	Touch(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// AtomicCacher is implemented by caches supporting conditional writes
+//
+// SetNX writes the value only if no entry is stored by the key, SetXX only if one is. Both report whether the value
+// was written
+type AtomicCacher[T any] interface {
+	Cacher[T]
+	SetNX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error)
+	SetXX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error)
+}
+
+// Updater is implemented by caches able to apply read-modify-write updates atomically per key
+//
+// fn receives the stored value and whether it exists, returning the new value and whether it has to be written
+type Updater[T any] interface {
+	Update(ctx context.Context, key string, fn func(old T, exists bool) (T, bool, error)) error
+}
+
+// CounterCacher is implemented by caches able to increment integer values atomically
+//
+// Incr adds delta to the value by key, starting from zero if it is missing, and returns the result. Negative delta
+// decrements the value
+type CounterCacher interface {
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+}
+
+// Popper is implemented by caches able to return and remove an entry atomically, so only one of concurrent callers
+// receives it
+type Popper[T any] interface {
+	Pop(ctx context.Context, key string) (T, error)
+}
+
+// KeyLister is implemented by caches able to list their keys
+type KeyLister interface {
+	Keys(ctx context.Context) ([]string, error)
+}
+
+// EntryCounter is implemented by caches able to report the number of stored entries
+type EntryCounter interface {
+	Len(ctx context.Context) (int, error)
+}
+
+// Ranger is implemented by caches able to iterate over live entries
+//
+// Iteration stops once the context is done
+type Ranger[T any] interface {
+	Range(ctx context.Context) iter.Seq2[string, T]
+}
+
+// ExistenceChecker is implemented by caches able to check whether a live entry is stored without reading its value
+type ExistenceChecker interface {
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// TTLGetter is implemented by caches able to report remaining TTL of the returned entry
+type TTLGetter[T any] interface {
+	GetWithTTL(ctx context.Context, key string) (T, time.Duration, error)
+}
+
+// EntryGetter is implemented by caches able to report the metadata of stored entries
+//
+// Reading an entry with GetEntry does not count as an access
+type EntryGetter[T any] interface {
+	GetEntry(ctx context.Context, key string) (Entry[T], error)
+}
+
+// TTLMultiGetter is implemented by caches able to report remaining TTL of the returned entries
+type TTLMultiGetter[T any] interface {
+	GetMultiWithTTL(ctx context.Context, keys []string) ([]StorageItemMultiWithTTL[T], error)
+}
+
+// Degrader is implemented by caches and wrappers able to report they are operating in degraded mode,
+// e.g. when the backend is unreachable and calls fail fast or are served without caching
+type Degrader interface {
+	Degraded() bool
+}
+
+// KeyLocker is implemented by caches providing mutual exclusion per key
+//
+// Locks are independent of cache operations, they only coordinate callers of Lock
+type KeyLocker interface {
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
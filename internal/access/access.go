@@ -0,0 +1,51 @@
+// Package access counts reads of cache entries
+package access
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Tracker counts reads of a single entry. Safe for concurrent usage, a nil Tracker ignores reads
+type Tracker struct {
+	hits atomic.Int64
+	last atomic.Int64 // unix nanoseconds of the latest read, 0 if never read
+}
+
+// New creates a Tracker of an entry never read
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Record counts a read happening now
+func (t *Tracker) Record() {
+	if t == nil {
+		return
+	}
+
+	t.hits.Add(1)
+	t.last.Store(time.Now().UnixNano())
+}
+
+// Hits returns the number of reads
+func (t *Tracker) Hits() int64 {
+	if t == nil {
+		return 0
+	}
+
+	return t.hits.Load()
+}
+
+// LastAccess returns the time of the latest read, zero if the entry was never read
+func (t *Tracker) LastAccess() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	last := t.last.Load()
+	if last == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, last)
+}
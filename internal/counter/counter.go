@@ -0,0 +1,53 @@
+// Package counter provides integer arithmetic over values of generic caches
+package counter
+
+import (
+	"reflect"
+
+	"github.com/sinu5oid/cache"
+)
+
+// Add adds delta to the integer value in place, returning the result
+//
+// Fails with cache.ErrNotInteger if T is not an integer type and with cache.ErrCounterOverflow if the result does not
+// fit it, leaving the value unchanged
+func Add[T any](value *T, delta int64) (int64, error) {
+	v := reflect.ValueOf(value).Elem()
+
+	switch {
+	case v.CanInt():
+		current := v.Int()
+		n := current + delta
+		if (delta > 0 && n < current) || (delta < 0 && n > current) || v.OverflowInt(n) {
+			return 0, cache.ErrCounterOverflow
+		}
+
+		v.SetInt(n)
+		return n, nil
+	case v.CanUint():
+		current := v.Uint()
+		if delta < 0 && uint64(-delta) > current {
+			return 0, cache.ErrCounterOverflow
+		}
+
+		n := current + uint64(delta)
+		if (delta > 0 && n < current) || v.OverflowUint(n) || n > 1<<63-1 {
+			return 0, cache.ErrCounterOverflow
+		}
+
+		v.SetUint(n)
+		return int64(n), nil
+	default:
+		return 0, cache.ErrNotInteger
+	}
+}
+
+// From converts n into a value of the integer type T
+func From[T any](n int64) (T, error) {
+	var value T
+	if _, err := Add(&value, n); err != nil {
+		return *new(T), err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,123 @@
+// Package invalidation connects local caches to a cache.Invalidator
+package invalidation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/sinu5oid/cache"
+)
+
+// lastOrigin numbers the subscriptions of the process
+var lastOrigin atomic.Uint64
+
+// Subscription delivers invalidations to a local cache, skipping the ones the cache published itself
+//
+// Own events are told apart by the origin of the subscription, so invalidators not implementing
+// cache.OriginInvalidator or cache.OriginClearInvalidator deliver them back as well. A nil Subscription is valid and
+// does nothing
+type Subscription struct {
+	invalidator      cache.Invalidator
+	origin           string
+	unsubscribe      func()
+	unsubscribeClear func()
+}
+
+// Subscribe registers evict to be called for keys invalidated by other subscribers of the invalidator
+//
+// If the invalidator implements cache.ClearInvalidator and clear is not nil, clear is called for clears published by
+// other subscribers
+func Subscribe(invalidator cache.Invalidator, evict func(key string), clear func()) *Subscription {
+	s := &Subscription{
+		invalidator:      invalidator,
+		origin:           "subscription-" + strconv.FormatUint(lastOrigin.Add(1), 10),
+		unsubscribeClear: func() {},
+	}
+
+	if originInvalidator, ok := invalidator.(cache.OriginInvalidator); ok {
+		s.unsubscribe = originInvalidator.SubscribeFrom(func(origin, key string) {
+			if origin != s.origin {
+				evict(key)
+			}
+		})
+	} else {
+		s.unsubscribe = invalidator.Subscribe(evict)
+	}
+
+	if clear == nil {
+		return s
+	}
+
+	switch clearInvalidator := invalidator.(type) {
+	case cache.OriginClearInvalidator:
+		s.unsubscribeClear = clearInvalidator.SubscribeClearFrom(func(origin string) {
+			if origin != s.origin {
+				clear()
+			}
+		})
+	case cache.ClearInvalidator:
+		s.unsubscribeClear = clearInvalidator.SubscribeClear(clear)
+	}
+
+	return s
+}
+
+// Publish notifies other subscribers that the entries by keys are no longer valid
+func (s *Subscription) Publish(ctx context.Context, keys ...string) error {
+	if s == nil {
+		return nil
+	}
+
+	originInvalidator, hasOrigin := s.invalidator.(cache.OriginInvalidator)
+	for _, key := range keys {
+		var err error
+		if hasOrigin {
+			err = originInvalidator.PublishFrom(ctx, s.origin, key)
+		} else {
+			err = s.invalidator.Publish(ctx, key)
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not publish invalidation for key %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// PublishClear notifies other subscribers that all the entries are no longer valid
+//
+// Does nothing if the invalidator does not implement cache.ClearInvalidator
+func (s *Subscription) PublishClear(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	var err error
+	switch clearInvalidator := s.invalidator.(type) {
+	case cache.OriginClearInvalidator:
+		err = clearInvalidator.PublishClearFrom(ctx, s.origin)
+	case cache.ClearInvalidator:
+		err = clearInvalidator.PublishClear(ctx)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not publish clear invalidation: %w", err)
+	}
+
+	return nil
+}
+
+// Close removes the subscription
+func (s *Subscription) Close() {
+	if s == nil {
+		return
+	}
+
+	s.unsubscribe()
+	s.unsubscribeClear()
+}
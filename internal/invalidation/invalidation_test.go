@@ -0,0 +1,93 @@
+package invalidation
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+func TestSubscriptionSkipsOwnEvents(t *testing.T) {
+	ctx := context.Background()
+	invalidator := cache.NewLocalInvalidator()
+
+	var evicted, cleared int
+	s := Subscribe(invalidator, func(string) { evicted++ }, func() { cleared++ })
+	t.Cleanup(s.Close)
+
+	if err := s.Publish(ctx, "key"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if err := s.PublishClear(ctx); err != nil {
+		t.Fatalf("PublishClear() error = %v", err)
+	}
+
+	if evicted != 0 || cleared != 0 {
+		t.Errorf("own events delivered %d evictions, %d clears, want none", evicted, cleared)
+	}
+
+	if err := invalidator.Publish(ctx, "key"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if err := invalidator.PublishClear(ctx); err != nil {
+		t.Fatalf("PublishClear() error = %v", err)
+	}
+
+	if evicted != 1 || cleared != 1 {
+		t.Errorf("other events delivered %d evictions, %d clears, want 1", evicted, cleared)
+	}
+}
+
+func TestConcurrentInvalidationOfSameKey(t *testing.T) {
+	ctx := context.Background()
+	invalidator := cache.NewLocalInvalidator()
+
+	var mu sync.Mutex
+	var local []string
+	s := Subscribe(invalidator, func(key string) {
+		mu.Lock()
+		local = append(local, key)
+		mu.Unlock()
+	}, nil)
+	t.Cleanup(s.Close)
+
+	// the remote subscriber holds the local publish of the key while it publishes the same key itself
+	received := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	remote := Subscribe(invalidator, func(string) {
+		once.Do(func() {
+			close(received)
+			<-release
+		})
+	}, nil)
+	t.Cleanup(remote.Close)
+
+	published := make(chan error, 1)
+	go func() { published <- s.Publish(ctx, "key") }()
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("local invalidation was not delivered")
+	}
+
+	if err := remote.Publish(ctx, "key"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	close(release)
+	if err := <-published; err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(local) != 1 || local[0] != "key" {
+		t.Errorf("remote invalidations delivered = %v, want [key]", local)
+	}
+}
@@ -0,0 +1,60 @@
+// Package keylock provides in-process mutual exclusion per key
+package keylock
+
+import (
+	"context"
+	"sync"
+)
+
+// Locker holds a lock per key, created on demand and dropped once nobody holds or waits for it
+type Locker struct {
+	mu    sync.Mutex
+	locks map[string]*lock
+}
+
+type lock struct {
+	held chan struct{}
+	refs int
+}
+
+// New creates a Locker
+func New() *Locker {
+	return &Locker{locks: make(map[string]*lock)}
+}
+
+// Lock waits until the key is free or the context is done. The returned function releases the lock
+func (l *Locker) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	l.mu.Lock()
+	k, ok := l.locks[key]
+	if !ok {
+		k = &lock{held: make(chan struct{}, 1)}
+		l.locks[key] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	select {
+	case k.held <- struct{}{}:
+	case <-ctx.Done():
+		l.release(key, k)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-k.held
+			l.release(key, k)
+		})
+	}, nil
+}
+
+func (l *Locker) release(key string, k *lock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	k.refs--
+	if k.refs == 0 {
+		delete(l.locks, key)
+	}
+}
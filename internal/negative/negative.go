@@ -0,0 +1,103 @@
+// Package negative remembers fetches that found no value, so they are not repeated until the memory expires
+package negative
+
+import (
+	"sync"
+	"time"
+)
+
+// maxEntries bounds the number of remembered keys, so lookups of random nonexistent keys do not exhaust memory
+const maxEntries = 1 << 16
+
+// Cache remembers the errors of fetches that found no value. A nil Cache remembers nothing. Safe for concurrent usage
+type Cache struct {
+	ttl        time.Duration
+	isNotFound func(err error) bool
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	err       error
+	expiresAt time.Time
+}
+
+// New creates a Cache remembering errors isNotFound approves for ttl
+func New(ttl time.Duration, isNotFound func(err error) bool) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		isNotFound: isNotFound,
+		entries:    make(map[string]entry),
+	}
+}
+
+// Get returns the error remembered for the key, if it has not expired by now
+func (c *Cache) Get(key string, now time.Time) (error, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !e.expiresAt.After(now) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.err, true
+}
+
+// Remember stores the error of the fetch by key made at now if it reports a missing value
+func (c *Cache) Remember(key string, err error, now time.Time) {
+	if c == nil || err == nil || !c.isNotFound(err) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxEntries {
+		c.evict(now)
+	}
+
+	c.entries[key] = entry{
+		err:       err,
+		expiresAt: now.Add(c.ttl),
+	}
+}
+
+// Forget drops the error remembered for the key, e.g. once a value is stored by it
+func (c *Cache) Forget(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// evict removes expired entries. If too few have expired, arbitrary ones are removed as well, so the sweep is not
+// repeated on every following insert
+func (c *Cache) evict(now time.Time) {
+	for key, e := range c.entries {
+		if !e.expiresAt.After(now) {
+			delete(c.entries, key)
+		}
+	}
+
+	for key := range c.entries {
+		if len(c.entries) <= maxEntries-maxEntries/16 {
+			return
+		}
+		delete(c.entries, key)
+	}
+}
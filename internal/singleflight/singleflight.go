@@ -0,0 +1,224 @@
+// Package singleflight deduplicates concurrent calls made for the same key
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Group runs at most one call per key at a time, broadcasting its result to the callers joined while it runs
+//
+// The zero value is ready to use
+type Group[T any] struct {
+	mu            sync.Mutex
+	calls         map[string]*call[T]
+	isolateErrors bool
+}
+
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+	panic *PanicError
+}
+
+// ErrNotLoaded is the result of DoMulti calls for keys the batch function did not return
+var ErrNotLoaded = errors.New("value was not loaded")
+
+// PanicError is raised in every caller of a call which panicked, preserving the original value and stack
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("singleflight call panicked: %v\n\n%s", e.Value, e.Stack)
+}
+
+// IsolateErrors controls whether errors are shared. When isolated, callers joined a failed call run their own
+func (g *Group[T]) IsolateErrors(isolate bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.isolateErrors = isolate
+}
+
+// Do runs fn unless a call for the key is in flight, then waits for its result instead
+//
+// fn runs in its own goroutine with the context of the caller started the call, detached from its cancellation, so a
+// caller giving up does not fail the call for others. Every caller, including the one started the call, stops waiting
+// when its context is done. Panics of fn are propagated to all the waiting callers as *PanicError
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	for {
+		g.mu.Lock()
+		if g.calls == nil {
+			g.calls = make(map[string]*call[T])
+		}
+
+		c, joined := g.calls[key]
+		if !joined {
+			c = &call[T]{done: make(chan struct{})}
+			g.calls[key] = c
+			go g.run(context.WithoutCancel(ctx), key, c, fn)
+		}
+		isolate := g.isolateErrors
+		g.mu.Unlock()
+
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return *new(T), ctx.Err()
+		}
+
+		if joined && (errors.Is(c.err, ErrNotLoaded) || (c.err != nil && c.panic == nil && isolate)) {
+			continue // run a call of our own
+		}
+
+		return c.result()
+	}
+}
+
+// DoMulti runs fn once for all the keys not in flight and waits for the calls in flight for the rest
+//
+// fn returns the values by key, keys it did not return are resolved with ErrNotLoaded and are absent from the result.
+// Returns the first error of fn or of the joined calls other than ErrNotLoaded, errors are shared regardless of
+// IsolateErrors. fn runs in its own goroutine with the context detached from its cancellation, as Do does. Waiting
+// stops when the context is done. Panics of fn are propagated to all the waiting callers as *PanicError
+func (g *Group[T]) DoMulti(
+	ctx context.Context,
+	keys []string,
+	fn func(ctx context.Context, keys []string) (map[string]T, error),
+) (map[string]T, error) {
+	owned := make(map[string]*call[T], len(keys))
+	joined := make(map[string]*call[T], len(keys))
+	ownedKeys := make([]string, 0, len(keys))
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+
+	for _, key := range keys {
+		if _, ok := owned[key]; ok {
+			continue
+		}
+
+		if _, ok := joined[key]; ok {
+			continue
+		}
+
+		if c, ok := g.calls[key]; ok {
+			joined[key] = c
+			continue
+		}
+
+		c := &call[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		owned[key] = c
+		ownedKeys = append(ownedKeys, key)
+	}
+	g.mu.Unlock()
+
+	if len(owned) > 0 {
+		go g.runMulti(context.WithoutCancel(ctx), ownedKeys, owned, fn)
+	}
+
+	res := make(map[string]T, len(keys))
+	var firstErr error
+	collect := func(key string, c *call[T]) {
+		value, err := c.result()
+		switch {
+		case err == nil:
+			res[key] = value
+		case !errors.Is(err, ErrNotLoaded) && firstErr == nil:
+			firstErr = err
+		}
+	}
+
+	for key, c := range owned {
+		joined[key] = c
+	}
+
+	for key, c := range joined {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		collect(key, c)
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return res, nil
+}
+
+func (g *Group[T]) runMulti(
+	ctx context.Context,
+	keys []string,
+	owned map[string]*call[T],
+	fn func(ctx context.Context, keys []string) (map[string]T, error),
+) {
+	defer func() {
+		var panicErr *PanicError
+		if r := recover(); r != nil {
+			panicErr = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+
+		g.mu.Lock()
+		for key, c := range owned {
+			delete(g.calls, key)
+			if panicErr != nil {
+				c.panic = panicErr
+			}
+		}
+		g.mu.Unlock()
+
+		for _, c := range owned {
+			close(c.done)
+		}
+	}()
+
+	values, err := fn(ctx, keys)
+	for key, c := range owned {
+		value, ok := values[key]
+		switch {
+		case err != nil:
+			c.err = err
+		case ok:
+			c.value = value
+		default:
+			c.err = ErrNotLoaded
+		}
+	}
+}
+
+func (g *Group[T]) run(ctx context.Context, key string, c *call[T], fn func(ctx context.Context) (T, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.panic = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		close(c.done)
+	}()
+
+	c.value, c.err = fn(ctx)
+}
+
+func (c *call[T]) result() (T, error) {
+	if c.panic != nil {
+		panic(c.panic)
+	}
+
+	return c.value, c.err
+}
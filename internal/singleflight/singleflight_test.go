@@ -0,0 +1,286 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errFetch = errors.New("fetch failed")
+
+// waitJoined waits until the call is started, then calls join and lets the joined callers block on the call
+func waitJoined(t *testing.T, started chan struct{}, join func()) {
+	t.Helper()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("call was not started")
+	}
+
+	join()
+	time.Sleep(10 * time.Millisecond) // let the joined callers block on the call
+}
+
+func TestDoBroadcastsResult(t *testing.T) {
+	var g Group[int]
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	fn := func(context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return 1, nil
+	}
+
+	const callers = 5
+	results := make(chan int, callers)
+	var wg sync.WaitGroup
+	do := func() {
+		defer wg.Done()
+		value, err := g.Do(context.Background(), "key", fn)
+		if err != nil {
+			t.Errorf("Do() error = %v", err)
+		}
+		results <- value
+	}
+
+	wg.Add(1)
+	go do()
+	waitJoined(t, started, func() {
+		wg.Add(callers - 1)
+		for range callers - 1 {
+			go do()
+		}
+	})
+
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for value := range results {
+		if value != 1 {
+			t.Errorf("Do() = %v, want 1", value)
+		}
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn called %d times, want 1", got)
+	}
+}
+
+func TestDoPropagatesPanic(t *testing.T) {
+	var g Group[int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	fn := func(context.Context) (int, error) {
+		close(started)
+		<-release
+		panic("boom")
+	}
+
+	const callers = 3
+	panics := make(chan any, callers)
+	var wg sync.WaitGroup
+	do := func() {
+		defer wg.Done()
+		defer func() { panics <- recover() }()
+		_, _ = g.Do(context.Background(), "key", fn)
+	}
+
+	wg.Add(1)
+	go do()
+	waitJoined(t, started, func() {
+		wg.Add(callers - 1)
+		for range callers - 1 {
+			go do()
+		}
+	})
+
+	close(release)
+	wg.Wait()
+	close(panics)
+
+	count := 0
+	for r := range panics {
+		panicErr, ok := r.(*PanicError)
+		if !ok || panicErr.Value != "boom" {
+			t.Errorf("recovered %v, want *PanicError with boom", r)
+		}
+		count++
+	}
+
+	if count != callers {
+		t.Errorf("%d callers panicked, want %d", count, callers)
+	}
+
+	// the group is usable after the panic
+	if value, err := g.Do(context.Background(), "key", func(context.Context) (int, error) { return 2, nil }); value != 2 {
+		t.Errorf("Do() after panic = %v, %v, want 2", value, err)
+	}
+}
+
+func TestDoSharesErrors(t *testing.T) {
+	for _, isolate := range []bool{false, true} {
+		var g Group[int]
+		g.IsolateErrors(isolate)
+
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		first := func(context.Context) (int, error) {
+			calls.Add(1)
+			close(started)
+			<-release
+			return 0, errFetch
+		}
+		second := func(context.Context) (int, error) {
+			calls.Add(1)
+			return 2, nil
+		}
+
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := g.Do(context.Background(), "key", first)
+			firstErr <- err
+		}()
+
+		joinedResult := make(chan error, 1)
+		var joinedValue int
+		waitJoined(t, started, func() {
+			go func() {
+				var err error
+				joinedValue, err = g.Do(context.Background(), "key", second)
+				joinedResult <- err
+			}()
+		})
+		close(release)
+
+		if err := <-firstErr; !errors.Is(err, errFetch) {
+			t.Errorf("isolate %v: Do() of the first caller error = %v, want %v", isolate, err, errFetch)
+		}
+
+		err := <-joinedResult
+		switch {
+		case isolate && (err != nil || joinedValue != 2):
+			t.Errorf("isolate %v: Do() of the joined caller = %v, %v, want 2", isolate, joinedValue, err)
+		case !isolate && !errors.Is(err, errFetch):
+			t.Errorf("isolate %v: Do() of the joined caller error = %v, want %v", isolate, err, errFetch)
+		}
+
+		wantCalls := int32(1)
+		if isolate {
+			wantCalls = 2
+		}
+
+		if got := calls.Load(); got != wantCalls {
+			t.Errorf("isolate %v: fn called %d times, want %d", isolate, got, wantCalls)
+		}
+	}
+}
+
+func TestDoDetachesCallerCancellation(t *testing.T) {
+	var g Group[int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	fn := func(ctx context.Context) (int, error) {
+		close(started)
+		<-release
+		return 1, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := g.Do(ctx, "key", fn)
+		firstErr <- err
+	}()
+
+	joined := make(chan error, 1)
+	var joinedValue int
+	waitJoined(t, started, func() {
+		go func() {
+			var err error
+			joinedValue, err = g.Do(context.Background(), "key", fn)
+			joined <- err
+		}()
+	})
+
+	cancel()
+	select {
+	case err := <-firstErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Do() of the canceled caller error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("canceled caller kept waiting for the call")
+	}
+
+	close(release)
+	if err := <-joined; err != nil || joinedValue != 1 {
+		t.Errorf("Do() of the joined caller = %v, %v, want 1", joinedValue, err)
+	}
+}
+
+func TestDoMulti(t *testing.T) {
+	var g Group[int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var calls atomic.Int32
+	fn := func(_ context.Context, keys []string) (map[string]int, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+
+		res := make(map[string]int, len(keys))
+		for _, key := range keys {
+			if key != "missing" {
+				res[key] = len(key)
+			}
+		}
+		return res, nil
+	}
+
+	first := make(chan map[string]int, 1)
+	go func() {
+		res, err := g.DoMulti(context.Background(), []string{"a", "bb", "missing"}, fn)
+		if err != nil {
+			t.Errorf("DoMulti() error = %v", err)
+		}
+		first <- res
+	}()
+
+	second := make(chan map[string]int, 1)
+	waitJoined(t, started, func() {
+		go func() {
+			res, err := g.DoMulti(context.Background(), []string{"bb", "ccc"}, fn)
+			if err != nil {
+				t.Errorf("DoMulti() error = %v", err)
+			}
+			second <- res
+		}()
+	})
+	close(release)
+
+	if res := <-first; len(res) != 2 || res["a"] != 1 || res["bb"] != 2 {
+		t.Errorf("DoMulti() = %v, want a and bb", res)
+	}
+
+	if res := <-second; len(res) != 2 || res["bb"] != 2 || res["ccc"] != 3 {
+		t.Errorf("DoMulti() of the joined caller = %v, want bb and ccc", res)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("fn called %d times, want 2", got)
+	}
+}
@@ -0,0 +1,49 @@
+// Package snapshot encodes cache entries as JSON lines, so caches can be persisted and restored
+package snapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+)
+
+// Entry is a line of a snapshot. ExpiresAt is nil for entries that never expire
+type Entry[T any] struct {
+	Key       string     `json:"key"`
+	Value     T          `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Write encodes the entries to w, one per line
+func Write[T any](w io.Writer, entries iter.Seq[Entry[T]]) error {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	for entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("could not encode entry %s: %w", entry.Key, err)
+		}
+	}
+
+	return buf.Flush()
+}
+
+// Read decodes the entries written by Write from r, calling fn for every one of them until it fails
+func Read[T any](r io.Reader, fn func(entry Entry[T]) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var entry Entry[T]
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not decode entry: %w", err)
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
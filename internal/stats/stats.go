@@ -0,0 +1,103 @@
+// Package stats provides the counters backing cache.Stats
+package stats
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// Counters accumulates cache operation counts. The zero value is ready to use, safe for concurrent usage
+type Counters struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	sets      atomic.Uint64
+	deletes   atomic.Uint64
+	evictions atomic.Uint64
+	expired   atomic.Uint64
+}
+
+// Read counts the result of a single key read: a hit if err is nil, a miss if the entry is missing or stale.
+// Other errors are not counted
+func (c *Counters) Read(err error) {
+	if err == nil {
+		// checked first, as the targets of errors.As escape to the heap
+		c.hits.Add(1)
+		return
+	}
+
+	var (
+		missingEntryError cache.MissingEntryError
+		staleEntryError   cache.StaleEntryError
+	)
+
+	if errors.As(err, &missingEntryError) || errors.As(err, &staleEntryError) {
+		c.misses.Add(1)
+	}
+}
+
+// ReadMulti counts a multi-key read of requested keys which found the provided number of entries
+func (c *Counters) ReadMulti(requested, found int) {
+	c.hits.Add(uint64(found))
+	c.misses.Add(uint64(requested - found))
+}
+
+// Hit counts a read that found the entry
+func (c *Counters) Hit() {
+	c.hits.Add(1)
+}
+
+// Miss counts a read that did not find the entry
+func (c *Counters) Miss() {
+	c.misses.Add(1)
+}
+
+// Set counts written entries
+func (c *Counters) Set(n int) {
+	c.sets.Add(uint64(n))
+}
+
+// Delete counts removed entries
+func (c *Counters) Delete(n int) {
+	c.deletes.Add(uint64(n))
+}
+
+// Evict counts an entry removed because of capacity
+func (c *Counters) Evict() {
+	c.evictions.Add(1)
+}
+
+// Expire counts an entry removed because of its TTL
+func (c *Counters) Expire() {
+	c.expired.Add(1)
+}
+
+// Snapshot returns the current counter values along with the provided number of entries
+func (c *Counters) Snapshot(entries int64) cache.Stats {
+	return cache.Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Deletes:   c.deletes.Load(),
+		Evictions: c.evictions.Load(),
+		Expired:   c.expired.Load(),
+		Entries:   entries,
+	}
+}
+
+// Report calls report with the result of snapshot every interval until stop is closed
+func Report(interval time.Duration, snapshot func() cache.Stats, report func(stats cache.Stats), stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			report(snapshot())
+		}
+	}
+}
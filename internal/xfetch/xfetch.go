@@ -0,0 +1,25 @@
+// Package xfetch implements probabilistic early expiration preventing cache stampedes
+//
+// Follows "Optimal Probabilistic Cache Stampede Prevention" by A. Vattani, F. Chierichetti and K. Lowenstein
+package xfetch
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Early reports whether an entry expiring at expiresAt, whose value took delta to fetch, has to be refreshed now
+//
+// The chance grows as the expiration approaches, the longer the fetch and the larger beta are. beta of 1 is the
+// optimal default, values above 1 favor earlier refreshes
+func Early(now, expiresAt time.Time, delta time.Duration, beta float64) bool {
+	if delta <= 0 || beta <= 0 {
+		return false
+	}
+
+	// 1 - Float64 is in (0, 1], so the logarithm is finite and non-positive
+	gap := -float64(delta) * beta * math.Log(1-rand.Float64())
+
+	return !now.Add(time.Duration(gap)).Before(expiresAt)
+}
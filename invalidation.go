@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// Invalidator broadcasts key invalidations between cache instances
+//
+// Local caches subscribe to evict entries written or deleted elsewhere, so they do not serve stale values
+type Invalidator interface {
+	// Publish notifies subscribers that the entry by key is no longer valid
+	Publish(ctx context.Context, key string) error
+	// Subscribe registers handler called for every published key. The returned function removes the subscription
+	Subscribe(handler func(key string)) (unsubscribe func())
+}
+
+// ClearInvalidator is implemented by invalidators able to broadcast that whole caches are no longer valid
+type ClearInvalidator interface {
+	Invalidator
+	// PublishClear notifies subscribers that all the entries are no longer valid
+	PublishClear(ctx context.Context) error
+	// SubscribeClear registers handler called for every published clear. The returned function removes the subscription
+	SubscribeClear(handler func()) (unsubscribe func())
+}
+
+// OriginInvalidator is implemented by invalidators telling subscribers who published an event, so they can skip the
+// events they published themselves
+type OriginInvalidator interface {
+	Invalidator
+	// PublishFrom notifies subscribers that the entry by key is no longer valid on behalf of origin
+	PublishFrom(ctx context.Context, origin, key string) error
+	// SubscribeFrom registers handler called for every published key with its origin, empty for keys published by
+	// Publish or received from other processes. The returned function removes the subscription
+	SubscribeFrom(handler func(origin, key string)) (unsubscribe func())
+}
+
+// OriginClearInvalidator is implemented by invalidators telling subscribers who published a clear
+type OriginClearInvalidator interface {
+	ClearInvalidator
+	// PublishClearFrom notifies subscribers that all the entries are no longer valid on behalf of origin
+	PublishClearFrom(ctx context.Context, origin string) error
+	// SubscribeClearFrom registers handler called for every published clear with its origin, empty for clears
+	// published by PublishClear or received from other processes. The returned function removes the subscription
+	SubscribeClearFrom(handler func(origin string)) (unsubscribe func())
+}
+
+// LocalInvalidator is an in-process Invalidator
+//
+// Handlers are called synchronously by Publish. Safe for concurrent usage
+type LocalInvalidator struct {
+	mu            sync.RWMutex
+	handlers      map[uint64]func(origin, key string)
+	clearHandlers map[uint64]func(origin string)
+	nextID        uint64
+}
+
+// NewLocalInvalidator creates a LocalInvalidator without subscribers
+func NewLocalInvalidator() *LocalInvalidator {
+	return &LocalInvalidator{
+		handlers:      make(map[uint64]func(origin, key string)),
+		clearHandlers: make(map[uint64]func(origin string)),
+	}
+}
+
+// Publish calls every registered handler with the key
+func (i *LocalInvalidator) Publish(ctx context.Context, key string) error {
+	return i.PublishFrom(ctx, "", key)
+}
+
+// PublishFrom calls every registered handler with the origin and the key
+func (i *LocalInvalidator) PublishFrom(_ context.Context, origin, key string) error {
+	i.mu.RLock()
+	handlers := make([]func(origin, key string), 0, len(i.handlers))
+	for _, h := range i.handlers {
+		handlers = append(handlers, h)
+	}
+	i.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(origin, key)
+	}
+
+	return nil
+}
+
+// Subscribe registers handler called for every published key
+func (i *LocalInvalidator) Subscribe(handler func(key string)) func() {
+	return i.SubscribeFrom(func(_, key string) { handler(key) })
+}
+
+// SubscribeFrom registers handler called for every published key with its origin
+func (i *LocalInvalidator) SubscribeFrom(handler func(origin, key string)) func() {
+	i.mu.Lock()
+	id := i.nextID
+	i.nextID++
+	i.handlers[id] = handler
+	i.mu.Unlock()
+
+	return func() {
+		i.mu.Lock()
+		delete(i.handlers, id)
+		i.mu.Unlock()
+	}
+}
+
+// PublishClear calls every registered clear handler
+func (i *LocalInvalidator) PublishClear(ctx context.Context) error {
+	return i.PublishClearFrom(ctx, "")
+}
+
+// PublishClearFrom calls every registered clear handler with the origin
+func (i *LocalInvalidator) PublishClearFrom(_ context.Context, origin string) error {
+	i.mu.RLock()
+	handlers := make([]func(origin string), 0, len(i.clearHandlers))
+	for _, h := range i.clearHandlers {
+		handlers = append(handlers, h)
+	}
+	i.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(origin)
+	}
+
+	return nil
+}
+
+// SubscribeClear registers handler called for every published clear
+func (i *LocalInvalidator) SubscribeClear(handler func()) func() {
+	return i.SubscribeClearFrom(func(string) { handler() })
+}
+
+// SubscribeClearFrom registers handler called for every published clear with its origin
+func (i *LocalInvalidator) SubscribeClearFrom(handler func(origin string)) func() {
+	i.mu.Lock()
+	id := i.nextID
+	i.nextID++
+	i.clearHandlers[id] = handler
+	i.mu.Unlock()
+
+	return func() {
+		i.mu.Lock()
+		delete(i.clearHandlers, id)
+		i.mu.Unlock()
+	}
+}
@@ -0,0 +1,382 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// KeyedItem is a util struct describing typed key and value
+//
+// Actively used by KeyedCacher.GetMulti and KeyedCacher.SetMulti cache functions
+type KeyedItem[K comparable, T any] struct {
+	Key   K
+	Value T
+}
+
+// KeyedCacher is Cacher with keys of type K
+type KeyedCacher[K comparable, T any] interface {
+	Get(ctx context.Context, key K) (T, error)
+	Set(ctx context.Context, key K, value T) error
+	GetMulti(ctx context.Context, keys []K) ([]KeyedItem[K, T], error)
+	SetMulti(ctx context.Context, kvs []KeyedItem[K, T]) error
+	Delete(ctx context.Context, key K) error
+	DeleteMulti(ctx context.Context, keys []K) error
+}
+
+// KeyEncoder converts typed keys into string keys of the underlying cache
+//
+// Distinct keys must be encoded into distinct strings, otherwise their entries overwrite each other
+type KeyEncoder[K any] func(key K) (string, error)
+
+// StringKey encodes keys of string types as is
+func StringKey[K ~string](key K) (string, error) {
+	return string(key), nil
+}
+
+// JSONKey encodes keys as JSON, so struct keys are encoded field by field without ambiguity
+func JSONKey[K any](key K) (string, error) {
+	encoded, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// KeyedCache adapts a string-keyed Cacher into KeyedCacher, encoding keys with the KeyEncoder
+//
+// Safe for concurrent usage if the wrapped cache is
+type KeyedCache[K comparable, T any] struct {
+	backend Cacher[T]
+	encode  KeyEncoder[K]
+}
+
+// Keyed creates a KeyedCache over the cache using the encoder
+func Keyed[K comparable, T any](c Cacher[T], encoder KeyEncoder[K]) *KeyedCache[K, T] {
+	return &KeyedCache[K, T]{
+		backend: c,
+		encode:  encoder,
+	}
+}
+
+// Unwrap returns the wrapped cache
+func (c *KeyedCache[K, T]) Unwrap() Cacher[T] {
+	return c.backend
+}
+
+// Get retrieves an item by key
+func (c *KeyedCache[K, T]) Get(ctx context.Context, key K) (T, error) {
+	encoded, err := c.key(key)
+	if err != nil {
+		return *new(T), err
+	}
+
+	return c.backend.Get(ctx, encoded)
+}
+
+// Set puts the provided value by key
+func (c *KeyedCache[K, T]) Set(ctx context.Context, key K, value T) error {
+	encoded, err := c.key(key)
+	if err != nil {
+		return err
+	}
+
+	return c.backend.Set(ctx, encoded, value)
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *KeyedCache[K, T]) GetMulti(ctx context.Context, keys []K) ([]KeyedItem[K, T], error) {
+	encoded := make([]string, 0, len(keys))
+	decoded := make(map[string]K, len(keys))
+	for _, key := range keys {
+		k, err := c.key(key)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded = append(encoded, k)
+		decoded[k] = key
+	}
+
+	items, err := c.backend.GetMulti(ctx, encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]KeyedItem[K, T], 0, len(items))
+	for _, item := range items {
+		key, ok := decoded[item.Key]
+		if !ok {
+			continue
+		}
+
+		res = append(res, KeyedItem[K, T]{
+			Key:   key,
+			Value: item.Value,
+		})
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs
+func (c *KeyedCache[K, T]) SetMulti(ctx context.Context, kvs []KeyedItem[K, T]) error {
+	items, err := c.items(kvs)
+	if err != nil {
+		return err
+	}
+
+	return c.backend.SetMulti(ctx, items)
+}
+
+// Delete removes cached value by key
+func (c *KeyedCache[K, T]) Delete(ctx context.Context, key K) error {
+	encoded, err := c.key(key)
+	if err != nil {
+		return err
+	}
+
+	return c.backend.Delete(ctx, encoded)
+}
+
+// DeleteMulti removes cached values by keys
+func (c *KeyedCache[K, T]) DeleteMulti(ctx context.Context, keys []K) error {
+	encoded := make([]string, 0, len(keys))
+	for _, key := range keys {
+		k, err := c.key(key)
+		if err != nil {
+			return err
+		}
+
+		encoded = append(encoded, k)
+	}
+
+	return c.backend.DeleteMulti(ctx, encoded)
+}
+
+// Exists reports whether a live entry by key is stored
+//
+// Reads the value with Get if the wrapped cache does not implement ExistenceChecker
+func (c *KeyedCache[K, T]) Exists(ctx context.Context, key K) (bool, error) {
+	encoded, err := c.key(key)
+	if err != nil {
+		return false, err
+	}
+
+	return Exists(ctx, c.backend, encoded)
+}
+
+// GetOrFetch obtains the value by key, calling the fetcher and storing its result if the value was not found
+//
+// Uses the wrapped cache GetOrFetch if it implements FetchingCacher, otherwise calls Get, then the fetcher and Set
+func (c *KeyedCache[K, T]) GetOrFetch(
+	ctx context.Context,
+	key K,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetch, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache implements neither FetchingCacher nor TTLCacher
+func (c *KeyedCache[K, T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key K,
+	ttl time.Duration,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetch, &ttl)
+}
+
+// Pop retrieves an item by key and removes it atomically
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement Popper
+func (c *KeyedCache[K, T]) Pop(ctx context.Context, key K) (T, error) {
+	popper, ok := c.backend.(Popper[T])
+	if !ok {
+		return *new(T), fmt.Errorf("cache does not support popping entries: %w", errors.ErrUnsupported)
+	}
+
+	encoded, err := c.key(key)
+	if err != nil {
+		return *new(T), err
+	}
+
+	return popper.Pop(ctx, encoded)
+}
+
+// GetWithTTL retrieves an item by key along with its remaining TTL
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement TTLGetter
+func (c *KeyedCache[K, T]) GetWithTTL(ctx context.Context, key K) (T, time.Duration, error) {
+	getter, ok := c.backend.(TTLGetter[T])
+	if !ok {
+		return *new(T), 0, fmt.Errorf("cache does not report TTL: %w", errors.ErrUnsupported)
+	}
+
+	encoded, err := c.key(key)
+	if err != nil {
+		return *new(T), 0, err
+	}
+
+	return getter.GetWithTTL(ctx, encoded)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement TTLCacher
+func (c *KeyedCache[K, T]) SetWithTTL(ctx context.Context, key K, value T, ttl time.Duration) error {
+	encoded, err := c.key(key)
+	if err != nil {
+		return err
+	}
+
+	return c.setWithTTL(ctx, encoded, value, ttl)
+}
+
+// Touch renews the entry by key with provided ttl duration without rewriting its value
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement Toucher
+func (c *KeyedCache[K, T]) Touch(ctx context.Context, key K, ttl time.Duration) error {
+	toucher, ok := c.backend.(Toucher)
+	if !ok {
+		return fmt.Errorf("cache does not support touching entries: %w", errors.ErrUnsupported)
+	}
+
+	encoded, err := c.key(key)
+	if err != nil {
+		return err
+	}
+
+	return toucher.Touch(ctx, encoded, ttl)
+}
+
+// Update applies the read-modify-write fn to the entry by key atomically
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement Updater
+func (c *KeyedCache[K, T]) Update(ctx context.Context, key K, fn func(old T, exists bool) (T, bool, error)) error {
+	updater, ok := c.backend.(Updater[T])
+	if !ok {
+		return fmt.Errorf("cache does not support atomic updates: %w", errors.ErrUnsupported)
+	}
+
+	encoded, err := c.key(key)
+	if err != nil {
+		return err
+	}
+
+	return updater.Update(ctx, encoded, fn)
+}
+
+// Incr adds delta to the integer value by key atomically and returns the result
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement CounterCacher
+func (c *KeyedCache[K, T]) Incr(ctx context.Context, key K, delta int64) (int64, error) {
+	counter, ok := c.backend.(CounterCacher)
+	if !ok {
+		return 0, fmt.Errorf("cache does not support counters: %w", errors.ErrUnsupported)
+	}
+
+	encoded, err := c.key(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.Incr(ctx, encoded, delta)
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement TTLCacher
+func (c *KeyedCache[K, T]) SetMultiWithTTL(ctx context.Context, kvs []KeyedItem[K, T], ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	items, err := c.items(kvs)
+	if err != nil {
+		return err
+	}
+
+	return ttlCacher.SetMultiWithTTL(ctx, items, ttl)
+}
+
+func (c *KeyedCache[K, T]) getOrFetch(
+	ctx context.Context,
+	key K,
+	fetch func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	encoded, err := c.key(key)
+	if err != nil {
+		return *new(T), err
+	}
+
+	if fetching, ok := c.backend.(FetchingCacher[T]); ok {
+		if ttl != nil {
+			return fetching.GetOrFetchWithTTL(ctx, encoded, *ttl, fetch)
+		}
+
+		return fetching.GetOrFetch(ctx, encoded, fetch)
+	}
+
+	if !IsForceRefresh(ctx) {
+		value, err := c.backend.Get(ctx, encoded)
+		var missingEntryError MissingEntryError
+		if !errors.As(err, &missingEntryError) {
+			return value, err
+		}
+	}
+
+	value, err := fetch(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	if ttl != nil {
+		return value, c.setWithTTL(ctx, encoded, value, *ttl)
+	}
+
+	return value, c.backend.Set(ctx, encoded, value)
+}
+
+func (c *KeyedCache[K, T]) setWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return ttlCacher.SetWithTTL(ctx, key, value, ttl)
+}
+
+func (c *KeyedCache[K, T]) items(kvs []KeyedItem[K, T]) ([]StorageItemMulti[T], error) {
+	items := make([]StorageItemMulti[T], 0, len(kvs))
+	for _, kv := range kvs {
+		encoded, err := c.key(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, StorageItemMulti[T]{
+			Key:   encoded,
+			Value: kv.Value,
+		})
+	}
+
+	return items, nil
+}
+
+func (c *KeyedCache[K, T]) key(key K) (string, error) {
+	encoded, err := c.encode(key)
+	if err != nil {
+		return "", fmt.Errorf("could not encode cache key %v: %w", key, err)
+	}
+
+	return encoded, nil
+}
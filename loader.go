@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Loader loads values missing from a cache from the source of truth, making plain Get calls read through
+//
+// Load returns ErrNotFound if the source has no value by key, Get reports it as MissingEntryError then
+type Loader[T any] interface {
+	Load(ctx context.Context, key string) (T, error)
+}
+
+// LoaderFunc adapts a function to the Loader interface
+type LoaderFunc[T any] func(ctx context.Context, key string) (T, error)
+
+// Load calls f(ctx, key)
+func (f LoaderFunc[T]) Load(ctx context.Context, key string) (T, error) {
+	return f(ctx, key)
+}
+
+// BatchLoader is implemented by loaders able to load several values with a single call, used by GetMulti
+//
+// LoadMulti omits keys the source has no value by
+type BatchLoader[T any] interface {
+	Loader[T]
+	LoadMulti(ctx context.Context, keys []string) ([]StorageItemMulti[T], error)
+}
+
+// LoadFetcher adapts the loader into a GetOrFetch fetcher of the value by key, replacing ErrNotFound with
+// MissingEntryError
+func LoadFetcher[T any](loader Loader[T], key string) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		value, err := loader.Load(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			return value, fmt.Errorf("%w: %w", NewMissingEntryError(key), err)
+		}
+
+		return value, err
+	}
+}
+
+// LoadMultiFetcher adapts the loader into a GetOrFetchMulti fetcher, loading missing values one by one if it does not
+// implement BatchLoader. Values reported with ErrNotFound are omitted
+func LoadMultiFetcher[T any](
+	loader Loader[T],
+) func(ctx context.Context, missing []string) ([]StorageItemMulti[T], error) {
+	if batch, ok := loader.(BatchLoader[T]); ok {
+		return batch.LoadMulti
+	}
+
+	return func(ctx context.Context, missing []string) ([]StorageItemMulti[T], error) {
+		items := make([]StorageItemMulti[T], 0, len(missing))
+		for _, key := range missing {
+			value, err := loader.Load(ctx, key)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, StorageItemMulti[T]{
+				Key:   key,
+				Value: value,
+			})
+		}
+
+		return items, nil
+	}
+}
@@ -0,0 +1,290 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// arc is an Adaptive Replacement Cache reporting the entries it evicts
+//
+// Follows "ARC: A Self-Tuning, Low Overhead Replacement Cache" by N. Megiddo and D. Modha. arc.ARCCache is not used
+// directly, as it silently drops evicted values. Safe for concurrent usage
+type arc[V any] struct {
+	size int
+	p    int // target size of t1
+
+	t1 *simplelru.LRU[string, V]        // resident entries seen once recently
+	t2 *simplelru.LRU[string, V]        // resident entries seen at least twice recently
+	b1 *simplelru.LRU[string, struct{}] // keys recently evicted from t1
+	b2 *simplelru.LRU[string, struct{}] // keys recently evicted from t2
+
+	lock sync.Mutex
+}
+
+func newARC[V any](size int) (*arc[V], error) {
+	t1, err := simplelru.NewLRU[string, V](size, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create ARC list: %w", err)
+	}
+
+	// sizes are validated by the first list
+	t2, _ := simplelru.NewLRU[string, V](size, nil)
+	b1, _ := simplelru.NewLRU[string, struct{}](size, nil)
+	b2, _ := simplelru.NewLRU[string, struct{}](size, nil)
+
+	return &arc[V]{
+		size: size,
+		t1:   t1,
+		t2:   t2,
+		b1:   b1,
+		b2:   b2,
+	}, nil
+}
+
+// Get returns the value by key, promoting the entry to the frequently used list
+func (a *arc[V]) Get(key string) (V, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if value, ok := a.t1.Peek(key); ok {
+		a.t1.Remove(key)
+		a.t2.Add(key, value)
+		return value, true
+	}
+
+	return a.t2.Get(key)
+}
+
+// Peek returns the value by key without updating recency or frequency
+func (a *arc[V]) Peek(key string) (V, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.peek(key)
+}
+
+func (a *arc[V]) peek(key string) (V, bool) {
+	if value, ok := a.t1.Peek(key); ok {
+		return value, true
+	}
+
+	return a.t2.Peek(key)
+}
+
+// Contains reports whether the key is resident without updating recency or frequency
+func (a *arc[V]) Contains(key string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.t1.Contains(key) || a.t2.Contains(key)
+}
+
+// Add puts the value by key. Reports the value it replaced and the entry evicted to make room for it, if any
+func (a *arc[V]) Add(key string, value V) addResult[V] {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.add(key, value)
+}
+
+// AddIf puts the value by key as Add does if cond approves the resident value, or its absence. cond is called under
+// the lock. Reports whether the value was put
+func (a *arc[V]) AddIf(key string, value V, cond func(previous V, ok bool) bool) (addResult[V], bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !cond(a.peek(key)) {
+		return addResult[V]{}, false
+	}
+
+	return a.add(key, value), true
+}
+
+func (a *arc[V]) add(key string, value V) (res addResult[V]) {
+	if res.previous, res.replaced = a.t1.Peek(key); res.replaced {
+		a.t1.Remove(key)
+		a.t2.Add(key, value)
+		return res
+	}
+
+	if res.previous, res.replaced = a.t2.Peek(key); res.replaced {
+		a.t2.Add(key, value)
+		return res
+	}
+
+	if a.b1.Contains(key) {
+		a.p = min(a.size, a.p+max(a.b2.Len()/a.b1.Len(), 1))
+		res.evictedKey, res.evictedValue, res.evicted = a.replace(false)
+		a.b1.Remove(key)
+		a.t2.Add(key, value)
+		return res
+	}
+
+	if a.b2.Contains(key) {
+		a.p = max(0, a.p-max(a.b1.Len()/a.b2.Len(), 1))
+		res.evictedKey, res.evictedValue, res.evicted = a.replace(true)
+		a.b2.Remove(key)
+		a.t2.Add(key, value)
+		return res
+	}
+
+	l1 := a.t1.Len() + a.b1.Len()
+	l2 := a.t2.Len() + a.b2.Len()
+
+	switch {
+	case l1 >= a.size:
+		if a.t1.Len() < a.size {
+			a.b1.RemoveOldest()
+			res.evictedKey, res.evictedValue, res.evicted = a.replace(false)
+		} else {
+			res.evictedKey, res.evictedValue, res.evicted = a.t1.RemoveOldest()
+		}
+	case l1+l2 >= a.size:
+		if l1+l2 >= 2*a.size {
+			a.b2.RemoveOldest()
+		}
+		res.evictedKey, res.evictedValue, res.evicted = a.replace(false)
+	}
+
+	a.t1.Add(key, value)
+
+	return res
+}
+
+// Update replaces the value of the resident entry by key with the one f returns unless f reports false, promoting the
+// entry to the frequently used list. Returns the stored value and whether it was replaced
+func (a *arc[V]) Update(key string, f func(value V) (V, bool)) (V, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	value, ok := a.t1.Peek(key)
+	if !ok {
+		if value, ok = a.t2.Peek(key); !ok {
+			return value, false
+		}
+	}
+
+	updated, ok := f(value)
+	if !ok {
+		return value, false
+	}
+
+	a.t1.Remove(key)
+	a.t2.Add(key, updated)
+
+	return updated, true
+}
+
+// replace evicts the least recently used entry of t1 or t2 depending on the target size, keeping its key in ghost lists
+//
+// Nothing is evicted while the cache has room, which is possible after explicit removals
+func (a *arc[V]) replace(inB2 bool) (string, V, bool) {
+	t1Len := a.t1.Len()
+	if t1Len+a.t2.Len() < a.size {
+		return "", *new(V), false
+	}
+
+	if t1Len > 0 && (t1Len > a.p || (inB2 && t1Len == a.p)) {
+		k, v, ok := a.t1.RemoveOldest()
+		if ok {
+			a.b1.Add(k, struct{}{})
+		}
+		return k, v, ok
+	}
+
+	k, v, ok := a.t2.RemoveOldest()
+	if ok {
+		a.b2.Add(k, struct{}{})
+	}
+	return k, v, ok
+}
+
+// Remove deletes the entry by key, including its ghost records. Returns the removed value, if any
+func (a *arc[V]) Remove(key string) (V, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.b1.Remove(key)
+	a.b2.Remove(key)
+
+	for _, l := range []*simplelru.LRU[string, V]{a.t1, a.t2} {
+		if value, ok := l.Peek(key); ok {
+			l.Remove(key)
+			return value, true
+		}
+	}
+
+	return *new(V), false
+}
+
+// Resize changes the capacity, evicting entries as replace does until they fit it. Ghost lists are trimmed to the
+// capacity as well
+func (a *arc[V]) Resize(size int) map[string]V {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.size = size
+	a.p = min(a.p, size)
+
+	evicted := make(map[string]V)
+	for a.t1.Len()+a.t2.Len() > size {
+		k, v, ok := a.replace(false)
+		if !ok {
+			break
+		}
+		evicted[k] = v
+	}
+
+	for _, l := range []*simplelru.LRU[string, V]{a.t1, a.t2} {
+		l.Resize(size)
+	}
+	for _, l := range []*simplelru.LRU[string, struct{}]{a.b1, a.b2} {
+		l.Resize(size)
+	}
+
+	return evicted
+}
+
+// Keys returns resident keys, recently used ones first, each list ordered from oldest to newest
+func (a *arc[V]) Keys() []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return append(a.t1.Keys(), a.t2.Keys()...)
+}
+
+// Len returns the number of resident entries
+func (a *arc[V]) Len() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.t1.Len() + a.t2.Len()
+}
+
+// Purge removes all the entries and ghost records. Returns the removed entries if drain is set
+func (a *arc[V]) Purge(drain bool) map[string]V {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var removed map[string]V
+	if drain {
+		removed = make(map[string]V, a.t1.Len()+a.t2.Len())
+		for _, l := range []*simplelru.LRU[string, V]{a.t1, a.t2} {
+			for _, k := range l.Keys() {
+				if value, ok := l.Peek(k); ok {
+					removed[k] = value
+				}
+			}
+		}
+	}
+
+	a.t1.Purge()
+	a.t2.Purge()
+	a.b1.Purge()
+	a.b2.Purge()
+	a.p = 0
+
+	return removed
+}
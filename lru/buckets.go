@@ -0,0 +1,107 @@
+package lru
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlBuckets groups keys by their expiration time rounded up to the granularity
+//
+// Lets the sweeper find expired keys by dropping whole buckets instead of scanning the cache.
+// A nil ttlBuckets tracks nothing
+type ttlBuckets struct {
+	granularity int64
+
+	mu      sync.Mutex
+	buckets map[int64]map[string]struct{}
+	index   map[string]int64 // key -> bucket
+}
+
+func newTTLBuckets(granularity time.Duration) *ttlBuckets {
+	return &ttlBuckets{
+		granularity: int64(max(granularity, time.Millisecond)),
+		buckets:     make(map[int64]map[string]struct{}),
+		index:       make(map[string]int64),
+	}
+}
+
+// add puts the key into the bucket of its expiration time, moving it out of the previous one
+func (b *ttlBuckets) add(key string, expiresAt time.Time) {
+	if b == nil {
+		return
+	}
+
+	id := expiresAt.UnixNano()/b.granularity + 1
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.unlink(key)
+
+	bucket, ok := b.buckets[id]
+	if !ok {
+		bucket = make(map[string]struct{})
+		b.buckets[id] = bucket
+	}
+	bucket[key] = struct{}{}
+	b.index[key] = id
+}
+
+// remove stops tracking the key
+func (b *ttlBuckets) remove(key string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.unlink(key)
+}
+
+func (b *ttlBuckets) unlink(key string) {
+	id, ok := b.index[key]
+	if !ok {
+		return
+	}
+
+	delete(b.index, key)
+	delete(b.buckets[id], key)
+	if len(b.buckets[id]) == 0 {
+		delete(b.buckets, id)
+	}
+}
+
+// clear stops tracking all the keys
+func (b *ttlBuckets) clear() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buckets = make(map[int64]map[string]struct{})
+	b.index = make(map[string]int64)
+}
+
+// due drops the buckets which ended before now, returning their keys
+func (b *ttlBuckets) due(now time.Time) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var keys []string
+	for id, bucket := range b.buckets {
+		if id*b.granularity > now.UnixNano() {
+			continue
+		}
+
+		for key := range bucket {
+			keys = append(keys, key)
+			delete(b.index, key)
+		}
+		delete(b.buckets, id)
+	}
+
+	return keys
+}
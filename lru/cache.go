@@ -1,49 +1,76 @@
-// Package lru provides a ARC 2Q LRU-based cache wrapper client with expiration logic
+// Package lru provides a size-bounded cache with expiration logic and selectable eviction policies: ARC, 2Q, LRU
+// and LFU
 package lru
 
 import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
+	"hash/fnv"
+	"iter"
+	"maps"
 	"time"
+	"unsafe"
 
 	"github.com/sinu5oid/cache"
-
-	lru "github.com/hashicorp/golang-lru"
+	"github.com/sinu5oid/cache/internal/access"
+	"github.com/sinu5oid/cache/internal/counter"
+	"github.com/sinu5oid/cache/internal/invalidation"
+	"github.com/sinu5oid/cache/internal/keylock"
+	"github.com/sinu5oid/cache/internal/negative"
+	"github.com/sinu5oid/cache/internal/singleflight"
+	"github.com/sinu5oid/cache/internal/stats"
+	"github.com/sinu5oid/cache/internal/xfetch"
 )
 
-// Cache represents lru.ARCCache
+// Cache represents size-bounded cache, ARC by default
 //
 // Items are subject of both eviction and TTL expiration
 type Cache[T any] struct {
-	storage    *lru.ARCCache
-	rwQueue    *sync.Map
+	storage    store[withTTL[T]]
+	locks      *keylock.Locker
+	updates    *keylock.Locker
 	defaultTTL *time.Duration
+	ttlPolicy  cache.TTLPolicy[T]
+	costFunc   cache.CostFunc[T]
+	sliding    time.Duration
+	grace      time.Duration
+	earlyBeta  float64
+	negatives  *negative.Cache
+	hashCodec  cache.Codec[T]
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	loader           cache.Loader[T]
+	flights          singleflight.Group[T]
+	invalidation     *invalidation.Subscription
+	archiver         func(key string, value T)
+	onEvict          cache.EvictionFunc[T]
+	trackAccess      bool
+	overflow         cache.Cacher[T]
+	onOverflowError  func(err error)
+	now              func() time.Time
+	stats            stats.Counters
+
+	buckets    *ttlBuckets
+	stopSweep  chan struct{}
+	stopReport chan struct{}
 }
 
-// NewCache creates a Cache instance with internal storages initialized and no TTL
+// NewCache creates an ARC Cache instance with internal storages initialized and no TTL
 func NewCache[T any](size int) (*Cache[T], error) {
-	s, err := lru.NewARC(size)
-	if err != nil {
-		return nil, fmt.Errorf("could not create new LRU ARC cache: %w", err)
-	}
+	return New[T](WithSize(size))
+}
 
-	return &Cache[T]{
-		storage:    s,
-		rwQueue:    &sync.Map{},
-		defaultTTL: nil,
-	}, nil
+// NewCacheWithPolicy creates a Cache instance evicting entries according to the policy, with internal storages
+// initialized and no TTL
+func NewCacheWithPolicy[T any](size int, policy Policy) (*Cache[T], error) {
+	return New[T](WithSize(size), WithPolicy(policy))
 }
 
 // NewCacheWithTTL creates a Cache instance with internal storages initialized and TTL being set
 func NewCacheWithTTL[T any](size int, defaultTTL time.Duration) (*Cache[T], error) {
-	c, err := NewCache[T](size)
-	if err != nil {
-		return nil, err
-	}
-
-	return c.WithTTL(defaultTTL), nil
+	return New[T](WithSize(size), WithTTL(defaultTTL))
 }
 
 // WithTTL assigns provided ttl value
@@ -54,86 +81,611 @@ func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
 	return c
 }
 
+// WithSlidingTTL makes entries expire after ttl without reads instead of a fixed time after they were written
+//
+// Every successful read renews the TTL, writes without explicit TTL use it as well, taking precedence over the default
+// TTL and the TTL policy. Entries within the grace period, entries of the overflow cache and reads with
+// cache.NoPromote are not renewed
+func (c *Cache[T]) WithSlidingTTL(ttl time.Duration) *Cache[T] {
+	c.sliding = ttl
+	return c
+}
+
+// WithLoader assigns the loader making Get and GetMulti read through: missing values are loaded as GetOrFetch and
+// GetOrFetchMulti do and stored with the default TTL
+func (c *Cache[T]) WithLoader(loader cache.Loader[T]) *Cache[T] {
+	c.loader = loader
+	return c
+}
+
+// WithTTLPolicy assigns the policy computing TTL of entries written without explicit TTL from their values
+//
+// Takes precedence over the default TTL, which is used when the policy returns a non-positive duration
+func (c *Cache[T]) WithTTLPolicy(policy cache.TTLPolicy[T]) *Cache[T] {
+	c.ttlPolicy = policy
+	return c
+}
+
+// WithCostFunc assigns the function used to compute entry sizes reported by SizeBytes
+//
+// By default cache.EstimateCost is used. Previous items keep the size computed when they were written
+func (c *Cache[T]) WithCostFunc(costFunc cache.CostFunc[T]) *Cache[T] {
+	c.costFunc = costFunc
+	return c
+}
+
+// WithOverflow assigns the secondary cache receiving entries evicted because of capacity
+//
+// Expired entries are not written to the overflow cache, others keep their remaining TTL if the secondary cache is
+// a cache.TTLCacher. Reads missing this cache are served from the secondary one, deletes are applied to both.
+// The secondary cache is best-effort: failed reads are reported as misses, so GetOrFetch calls the fetcher, and
+// errors are passed to the handler assigned with WithOverflowErrorHandler
+func (c *Cache[T]) WithOverflow(secondary cache.Cacher[T]) *Cache[T] {
+	c.overflow = secondary
+	return c
+}
+
+// WithOverflowErrorHandler assigns the function receiving errors of the overflow cache reads and writes, e.g. to log
+// them
+func (c *Cache[T]) WithOverflowErrorHandler(fn func(err error)) *Cache[T] {
+	c.onOverflowError = fn
+	return c
+}
+
+// Resize changes the capacity of the cache, keeping the stored entries that fit it
+//
+// Entries evicted when shrinking are chosen by the eviction policy and handled as any other capacity eviction: they
+// are spilled to the overflow cache and passed to the archiver and the eviction callback
+func (c *Cache[T]) Resize(size int) error {
+	if size <= 0 {
+		return errors.New("must provide a positive size")
+	}
+
+	for key, entry := range c.storage.Resize(size) {
+		c.stats.Evict()
+		c.buckets.remove(key)
+		c.evict(key, entry)
+	}
+
+	return nil
+}
+
 // Keys returns slice of stored keys
 //
 // The order of keys are not guaranteed
 func (c *Cache[T]) Keys(_ context.Context) ([]string, error) {
-	var keys []string
+	return c.storage.Keys(), nil
+}
+
+// Len returns the number of resident entries
+//
+// Expired but not yet removed entries are counted too, entries of the overflow cache are not
+func (c *Cache[T]) Len(_ context.Context) (int, error) {
+	return c.storage.Len(), nil
+}
+
+// Range iterates over live resident entries without updating recency and frequency lists or the stats
+//
+// Walks the keys resident when iteration starts, the cache may be used while iterating. Entries written during
+// iteration are not yielded, the removed ones are skipped. Entries of the overflow cache are not yielded
+func (c *Cache[T]) Range(ctx context.Context) iter.Seq2[string, T] {
+	return func(yield func(string, T) bool) {
+		for _, key := range c.storage.Keys() {
+			if ctx.Err() != nil {
+				return
+			}
+
+			entry, ok := c.storage.Peek(key)
+			if !ok || entry.expired(c.now()) {
+				continue
+			}
+
+			if !yield(key, entry.Value) {
+				return
+			}
+		}
+	}
+}
+
+// SizeBytes returns the approximate memory used by stored entries
+//
+// Sizes are computed when entries are written. Expired but not yet removed entries are counted too.
+// Walks all the entries without affecting their recency
+func (c *Cache[T]) SizeBytes(_ context.Context) (int64, error) {
+	var size int64
 	for _, k := range c.storage.Keys() {
-		keys = append(keys, k.(string))
+		if entry, ok := c.storage.Peek(k); ok {
+			size += entry.Cost
+		}
+	}
+	return size, nil
+}
+
+// Stats returns operation counters of the cache and the number of stored entries
+//
+// Reads through GetOrFetch and GetOrFetchMulti are counted as well: calls finding the value as hits, others as misses.
+// Reads served by the overflow cache count as hits. Entries include expired ones not removed yet
+func (c *Cache[T]) Stats(_ context.Context) (cache.Stats, error) {
+	return c.stats.Snapshot(int64(c.storage.Len())), nil
+}
+
+// WithSkipUnchanged makes writes of a value equal to the stored one skip the invalidation broadcast
+//
+// Values are compared by the hash of their serialized form produced by the codec. Such writes still renew the
+// entry TTL. Useful for idempotent refresh jobs
+func (c *Cache[T]) WithSkipUnchanged(codec cache.Codec[T]) *Cache[T] {
+	c.hashCodec = codec
+	return c
+}
+
+// WithGracePeriod keeps expired entries readable for the grace period
+//
+// Within the period Get returns the expired value together with cache.StaleEntryError, letting callers decide
+// whether stale data is acceptable. GetMulti skips such entries, GetOrFetch fetches a fresh value
+func (c *Cache[T]) WithGracePeriod(grace time.Duration) *Cache[T] {
+	c.grace = grace
+	return c
+}
+
+// WithIsolatedFetchErrors stops sharing fetch errors between concurrent GetOrFetch callers of the same key
+//
+// By default callers waiting for a fetch receive its error. When isolated, they run their own fetch instead
+func (c *Cache[T]) WithIsolatedFetchErrors() *Cache[T] {
+	c.flights.IsolateErrors(true)
+	return c
+}
+
+// WithEarlyRefresh makes GetOrFetch refresh entries before they expire with a probability growing as the expiration
+// approaches, proportionally to beta and the time the value took to fetch (XFetch)
+//
+// Spreads refreshes of hot keys over time instead of all readers fetching them at once on expiration. beta of 1 is
+// the optimal default, values above 1 favor earlier refreshes. The caller drawing the refresh fetches synchronously,
+// receiving the cached value if the fetch fails. Only values stored by GetOrFetch are refreshed, ignored with sliding
+// TTL
+func (c *Cache[T]) WithEarlyRefresh(beta float64) *Cache[T] {
+	c.earlyBeta = beta
+	return c
+}
+
+// WithNegativeCache makes GetOrFetch remember fetches that found no value for ttl, returning their error without
+// calling the fetcher again
+//
+// Protects the source from repeated lookups of nonexistent keys. Errors are recognized by isNotFound, if nil - by
+// errors.Is(err, cache.ErrNotFound). Writes by the key drop the remembered error. Up to 65536 keys are remembered
+func (c *Cache[T]) WithNegativeCache(ttl time.Duration, isNotFound func(err error) bool) *Cache[T] {
+	if isNotFound == nil {
+		isNotFound = func(err error) bool {
+			return errors.Is(err, cache.ErrNotFound)
+		}
+	}
+
+	c.negatives = negative.New(ttl, isNotFound)
+	return c
+}
+
+// WithAccessTracking counts reads of every entry along with the time of the latest one, reported by GetEntry
+//
+// Adds an allocation per write and atomic updates per read. Entries written before the call are not tracked
+func (c *Cache[T]) WithAccessTracking() *Cache[T] {
+	c.trackAccess = true
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// WithArchiver assigns the function receiving entries removed because of expiration or capacity eviction, so they
+// can be archived before they are lost
+//
+// Expired entries are noticed lazily, when they are read. The archiver is called synchronously, slow archivers
+// should hand entries off to a background worker
+func (c *Cache[T]) WithArchiver(archive func(key string, value T)) *Cache[T] {
+	c.archiver = archive
+	return c
+}
+
+// OnEvict assigns the function receiving entries removed from the cache along with the reason of removal
+//
+// Expired entries are noticed lazily, when they are read or overwritten, or by the expiry sweep if enabled. Clear
+// reports every removed entry as deleted. The function is called synchronously with no locks held, after the entry is
+// removed
+func (c *Cache[T]) OnEvict(fn cache.EvictionFunc[T]) *Cache[T] {
+	c.onEvict = fn
+	return c
+}
+
+// WithInvalidator subscribes the cache to the provided invalidator
+//
+// Entries invalidated by other subscribers are removed, published clears empty the cache. Writes and deletes made
+// through this cache are published to other subscribers. Replaces the previous subscription, if any
+func (c *Cache[T]) WithInvalidator(invalidator cache.Invalidator) *Cache[T] {
+	c.invalidation.Close()
+	c.invalidation = invalidation.Subscribe(invalidator, c.delete, c.Clear)
+	return c
+}
+
+// WithClock assigns the function returning the current time, time.Now by default
+//
+// The clock drives expiration, sliding and early refresh, e.g. to test TTL handling without waiting. Entries keep
+// the time they were written at, so assign it before the cache is used. Background removal of expired entries runs
+// on wall time intervals, checking entries against the clock
+func (c *Cache[T]) WithClock(now func() time.Time) *Cache[T] {
+	c.now = now
+	return c
+}
+
+// WithStatsReporter calls report with the cache Stats every interval, e.g. to export them to a monitoring system
+//
+// Replaces the previous reporter, if any. Call Close to stop reporting
+func (c *Cache[T]) WithStatsReporter(interval time.Duration, report func(stats cache.Stats)) *Cache[T] {
+	c.stopReporter()
+
+	c.stopReport = make(chan struct{})
+	snapshot := func() cache.Stats {
+		res, _ := c.Stats(context.Background())
+		return res
+	}
+	go stats.Report(interval, snapshot, report, c.stopReport)
+
+	return c
+}
+
+// WithExpirySweep starts removing expired entries in background, so they do not hold capacity needed by live ones
+//
+// Entries are grouped into buckets by expiration time rounded up to the granularity, every granularity interval the
+// elapsed buckets are dropped at once. Entries are kept until their grace period ends, if any. Swept entries can
+// not be read with cache.AllowStale anymore.
+// Call Close to stop sweeping
+func (c *Cache[T]) WithExpirySweep(granularity time.Duration) *Cache[T] {
+	c.stopSweeper()
+
+	c.buckets = newTTLBuckets(granularity)
+	for _, key := range c.storage.Keys() {
+		if entry, ok := c.storage.Peek(key); ok && entry.TTL != nil {
+			c.buckets.add(key, entry.UpdatedAt.Add(*entry.TTL+c.grace))
+		}
 	}
-	return keys, nil
+
+	c.stopSweep = make(chan struct{})
+	go c.sweep(time.Duration(c.buckets.granularity), c.buckets, c.stopSweep)
+
+	return c
+}
+
+// Close releases resources held by the cache, e.g. the invalidator subscription and the expiry sweeper
+func (c *Cache[T]) Close() {
+	c.invalidation.Close()
+	c.stopSweeper()
+	c.stopReporter()
 }
 
 // Clear removes items from internal storages
 func (c *Cache[T]) Clear() {
-	c.storage.Purge()
+	removed := c.storage.Purge(c.onEvict != nil)
+	c.buckets.clear()
+
+	for key, entry := range removed {
+		c.onEvict(key, entry.Value, cache.ReasonDeleted)
+	}
 }
 
 // Get retrieves an item from cache by key. Does not return expired by TTL or otherwise evicted items
-func (c *Cache[T]) Get(_ context.Context, key string) (T, error) {
-	return c.get(key)
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if c.loader != nil {
+		return c.getOrFetch(ctx, key, cache.LoadFetcher(c.loader, key), nil)
+	}
+
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	value, err := c.get(ctx, key)
+	c.stats.Read(err)
+
+	return value, err
+}
+
+// GetWithOptions retrieves an item from cache by key using provided per-call options
+//
+// Supports cache.AllowStale and cache.NoPromote, the latter reads the entry without updating ARC recency and
+// frequency lists. Expired entries are removed on the first regular read, after that they can not be returned as
+// stale. cache.ReadTimeout has no effect
+func (c *Cache[T]) GetWithOptions(ctx context.Context, key string, opts ...cache.GetOption) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	value, err := c.getWithOptions(ctx, key, cache.NewGetOptions(opts...))
+	c.stats.Read(err)
+
+	return value, err
+}
+
+// Pop retrieves an item from cache by key and removes it atomically, so only one of concurrent callers receives it
+//
+// Expired entries, including ones within the grace period, are removed and reported as missing. Entries of the
+// overflow cache are popped too, atomically only if it implements cache.Popper
+func (c *Cache[T]) Pop(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	entry, removed := c.storage.Remove(key)
+	c.buckets.remove(key)
+
+	var err error
+	switch {
+	case !removed && c.overflow != nil:
+		entry.Value, err = c.popOverflow(ctx, key)
+	case !removed:
+		err = cache.NewMissingEntryError(key)
+	case entry.expired(c.now()):
+		c.expired(key, entry)
+		err = cache.NewMissingEntryError(key)
+	default:
+		c.deleted(key, entry)
+	}
+	c.stats.Read(err)
+
+	if err != nil {
+		return *new(T), err
+	}
+
+	return entry.Value, c.invalidation.Publish(ctx, key)
 }
 
-type getOrFetchResult[T any] struct {
-	res T
-	err error
+// Peek retrieves an item from cache by key without updating recency and frequency lists
+//
+// Unlike reads, does not remove expired entries, consult the overflow cache or count stats, so inspecting entries
+// does not affect the cache. Expired entries are reported as missing
+func (c *Cache[T]) Peek(_ context.Context, key string) (T, error) {
+	entry, ok := c.storage.Peek(key)
+	if !ok || entry.expired(c.now()) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return entry.Value, nil
+}
+
+// Contains reports whether a live entry by key is stored, without side effects as Peek
+func (c *Cache[T]) Contains(_ context.Context, key string) bool {
+	entry, ok := c.storage.Peek(key)
+	return ok && !entry.expired(c.now())
+}
+
+// Exists reports whether a live entry by key is stored, without returning its value
+//
+// Works as Contains, reporting bypassed reads as missing
+func (c *Cache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	if cache.IsBypassed(ctx) {
+		return false, nil
+	}
+
+	return c.Contains(ctx, key), nil
+}
+
+// Lock acquires the in-process lock of the key, waiting until other holders release it or the context is done
+//
+// Lets callers coordinate cache updates with external side effects. The lock does not block cache operations
+func (c *Cache[T]) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	return c.locks.Lock(ctx, key)
 }
 
 // GetOrFetch tries to obtain cached value from internal storage. If multiple callers are accessing the same key,
-// later callers join the wait queue until the result or error are received
-//
-// If the value was not found - calls provided fetcher function, saves received value to the cache.
-func (c *Cache[T]) GetOrFetch(_ context.Context, key string, fetcher func() (T, error)) (T, error) {
-	done := make(chan getOrFetchResult[T], 1)
-	defer close(done)
-
-	lock, loaded := c.rwQueue.LoadOrStore(key, done)
-	if loaded {
-		c, ok := lock.(chan getOrFetchResult[T])
-		if ok {
-			res, ok := <-c // wait here until other routine does the fetching
-			if ok {
-				return res.res, res.err
+// later callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+
+	var (
+		cached withTTL[T]
+		early  bool
+	)
+	if !force {
+		entry, err := c.lookup(ctx, key)
+		early = err == nil && c.expiresEarly(entry)
+		if !early && (err == nil || !isMiss(err)) {
+			c.stats.Read(err)
+			return entry.Value, err
+		}
+		cached = entry
+
+		if err, ok := c.negatives.Get(key, c.now()); ok && !early {
+			c.stats.Hit()
+			return *new(T), err
+		}
+	}
+	c.stats.Miss()
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			entry, err := c.lookup(ctx, key)
+			refreshed := !early || !entry.UpdatedAt.Equal(cached.UpdatedAt)
+			if (err == nil && refreshed) || (err != nil && !isMiss(err)) {
+				return entry.Value, err
+			}
+		}
+
+		start := c.now()
+		result, err := fetcher(ctx)
+		if err != nil {
+			if early {
+				return cached.Value, nil // the cached value is still live
 			}
+
+			c.negatives.Remember(key, err, c.now())
+			return result, err
 		}
+		c.store(key, result, ttl, 0, c.now().Sub(start), nil)
+
+		return result, nil
+	})
+}
+
+// GetOrFetchMulti returns cached values by provided keys, loading all the missing ones with a single fetch call and
+// storing the results. Result slice follows the order of keys and may have fewer items than keys, it means that
+// fetch did not return items by that key
+//
+// Concurrent loads of the same key are deduplicated: keys being loaded by other callers are waited for.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return fetch(ctx, keys)
 	}
 
-	result, err := c.get(key)
-	if err == nil {
-		return result, err
+	force := cache.IsForceRefresh(ctx)
+	found := make(map[string]T, len(keys))
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !force {
+			entry, err := c.lookup(ctx, key)
+			if err == nil {
+				found[key] = entry.Value
+				continue
+			}
+
+			if !isMiss(err) {
+				return nil, err
+			}
+		}
+
+		missing = append(missing, key)
 	}
 
-	var missingEntryError cache.MissingEntryError
-	if !errors.As(err, &missingEntryError) {
-		return result, err
+	c.stats.ReadMulti(len(keys), len(keys)-len(missing))
+
+	if len(missing) > 0 {
+		loaded, err := c.flights.DoMulti(ctx, missing, func(ctx context.Context, owned []string) (map[string]T, error) {
+			return c.fetchMulti(ctx, owned, fetch, force)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(found, loaded)
 	}
 
-	result, err = fetcher()
-	done <- getOrFetchResult[T]{result, err}
-	defer c.rwQueue.Delete(key)
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, ok := found[key]
+		if !ok {
+			continue
+		}
 
-	if err == nil {
-		c.set(key, result, nil)
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		}
+		res = append(res, item)
 	}
 
-	return result, err
+	return res, nil
 }
 
 // Set puts the provided value by cache key to internal storage
 //
 // By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
-func (c *Cache[T]) Set(_ context.Context, key string, value T) error {
-	c.set(key, value, nil)
-	return nil
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	if !c.set(key, value, nil) {
+		return nil
+	}
+
+	return c.invalidation.Publish(ctx, key)
+}
+
+// SetWithOptions puts the provided value by cache key using provided per-call options
+//
+// Supports all the options. Writes are always synchronous, which satisfies cache.Async as well
+func (c *Cache[T]) SetWithOptions(ctx context.Context, key string, value T, opts ...cache.SetOption) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	o := cache.NewSetOptions(opts...)
+	if !c.setWithCost(key, value, o.TTL, o.Cost) {
+		return nil
+	}
+
+	return c.invalidation.Publish(ctx, key)
 }
 
 // GetMulti returns cached values by provided keys.
 // Result slice may have fewer items than keys, it means that items by that key were not found
-func (c *Cache[T]) GetMulti(_ context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if c.loader != nil {
+		return c.GetOrFetchMulti(ctx, keys, cache.LoadMultiFetcher(c.loader))
+	}
+
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+
 	res := make([]cache.StorageItemMulti[T], 0, len(keys))
 	for _, key := range keys {
-		val, err := c.get(key)
+		val, err := c.get(ctx, key)
 		if err != nil {
 			continue
 		}
@@ -144,86 +696,790 @@ func (c *Cache[T]) GetMulti(_ context.Context, keys []string) ([]cache.StorageIt
 		}
 		res = append(res, item)
 	}
+	c.stats.ReadMulti(len(keys), len(res))
 
 	return res, nil
 }
 
-// SetMulti puts provided k/v pairs to cache
-func (c *Cache[T]) SetMulti(_ context.Context, kvs []cache.StorageItemMulti[T]) error {
-	for _, kv := range kvs {
-		c.set(kv.Key, kv.Value, nil)
+// GetWithTTL retrieves an item from cache by key along with its remaining TTL, cache.NoExpiration if it never expires
+//
+// Entries within the grace period are returned with cache.StaleEntryError and a negative TTL. Entries served from the
+// overflow cache report cache.NoExpiration
+func (c *Cache[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), 0, cache.NewMissingEntryError(key)
 	}
 
-	return nil
-}
+	entry, err := c.getEntry(ctx, key, cache.GetOptions{})
+	c.stats.Read(err)
 
-// Delete removes cached value from internal storage by key
-func (c *Cache[T]) Delete(_ context.Context, key string) error {
-	c.delete(key)
-	return nil
-}
+	var staleEntryError cache.StaleEntryError
+	if err != nil && !errors.As(err, &staleEntryError) {
+		return *new(T), 0, err
+	}
 
-// SetWithTTL puts provided value by cache key using provided ttl duration
-func (c *Cache[T]) SetWithTTL(_ context.Context, key string, value T, ttl time.Duration) error {
-	c.set(key, value, &ttl)
-	return nil
+	return entry.Value, entry.remaining(c.now()), err
 }
 
-// SetMultiWithTTL puts provided k/v pairs to cache using provided ttl duration
-func (c *Cache[T]) SetMultiWithTTL(_ context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
-	for _, kv := range kvs {
-		c.set(kv.Key, kv.Value, &ttl)
+// GetEntry returns the live entry by key along with its metadata, see cache.Entry
+//
+// Does not count as an access, does not extend sliding TTL and does not update recency. Hits and LastAccess are
+// reported with WithAccessTracking only
+func (c *Cache[T]) GetEntry(_ context.Context, key string) (cache.Entry[T], error) {
+	entry, ok := c.storage.Peek(key)
+	now := c.now()
+	if !ok || entry.expired(now) {
+		return cache.Entry[T]{}, cache.NewMissingEntryError(key)
 	}
 
-	return nil
-}
+	ttl := cache.NoExpiration
+	if entry.TTL != nil {
+		ttl = *entry.TTL
+	}
 
-type withTTL[T any] struct {
-	UpdatedAt time.Time
-	TTL       *time.Duration
-	Value     T
+	return cache.Entry[T]{
+		Key:        key,
+		Value:      entry.Value,
+		UpdatedAt:  entry.UpdatedAt,
+		TTL:        ttl,
+		Remaining:  entry.remaining(now),
+		LastAccess: entry.Access.LastAccess(),
+		Hits:       entry.Access.Hits(),
+	}, nil
 }
 
-func (c *Cache[T]) get(key string) (T, error) {
-	value, ok := c.storage.Get(key)
+// GetMultiWithTTL returns cached values by provided keys along with their remaining TTL.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+//
+// Entries served from the overflow cache report cache.NoExpiration
+func (c *Cache[T]) GetMultiWithTTL(ctx context.Context, keys []string) ([]cache.StorageItemMultiWithTTL[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMultiWithTTL[T]{}, nil
+	}
+
+	now := c.now()
+	res := make([]cache.StorageItemMultiWithTTL[T], 0, len(keys))
+	for _, key := range keys {
+		entry, err := c.getEntry(ctx, key, cache.GetOptions{})
+		if err != nil {
+			continue
+		}
+
+		item := cache.StorageItemMultiWithTTL[T]{
+			Key:   key,
+			Value: entry.Value,
+			TTL:   entry.remaining(now),
+		}
+		res = append(res, item)
+	}
+	c.stats.ReadMulti(len(keys), len(res))
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to cache
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		if c.set(kv.Key, kv.Value, nil) {
+			keys = append(keys, kv.Key)
+		}
+	}
+
+	return c.invalidation.Publish(ctx, keys...)
+}
+
+// Delete removes cached value from internal storage by key
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	c.delete(key)
+	return c.invalidation.Publish(ctx, key)
+}
+
+// DeleteMulti removes cached values from internal storage by keys
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		c.delete(key)
+	}
+
+	return c.invalidation.Publish(ctx, keys...)
+}
+
+// SetNX puts provided value by cache key using provided ttl duration only if no live entry is stored by the key.
+// Reports whether the value was stored
+//
+// The check and the write are atomic. Expired entries, including ones within the grace period, are overwritten.
+// Entries of the overflow cache are not considered
+func (c *Cache[T]) SetNX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error) {
+	return c.setIf(ctx, key, value, ttl, false)
+}
+
+// SetXX puts provided value by cache key using provided ttl duration only if a live entry is stored by the key.
+// Reports whether the value was stored
+//
+// The check and the write are atomic. Entries of the overflow cache are not considered
+func (c *Cache[T]) SetXX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error) {
+	return c.setIf(ctx, key, value, ttl, true)
+}
+
+// Update applies the read-modify-write fn to the entry by key atomically
+//
+// fn receives the stored value and whether a live entry exists, returning the new value and whether it has to be
+// written. The value is written as Set does, nothing is written if fn fails. Updates of the same key are serialized,
+// fn is called again if the entry is changed by other writes in the meantime. Entries of the overflow cache are not
+// considered, reads do not update recency and frequency lists
+func (c *Cache[T]) Update(ctx context.Context, key string, fn func(old T, exists bool) (T, bool, error)) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	unlock, err := c.updates.Lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for {
+		read, found := c.storage.Peek(key)
+		exists := found && !read.expired(c.now())
+
+		old := read.Value
+		if !exists {
+			old = *new(T)
+		}
+
+		value, write, err := fn(old, exists)
+		if err != nil || !write {
+			return err
+		}
+
+		changed, stored := c.store(key, value, nil, 0, 0, func(previous withTTL[T], ok bool) bool {
+			return ok == found && (!ok || previous.UpdatedAt.Equal(read.UpdatedAt))
+		})
+		if stored && !changed {
+			return nil
+		}
+
+		if stored {
+			return c.invalidation.Publish(ctx, key)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// Incr adds delta to the integer value by key atomically and returns the result, negative delta decrements it
+//
+// Missing and expired entries start from zero and are written as Set does, live ones keep their expiration time.
+// Entries of the overflow cache are not considered. Fails with cache.ErrNotInteger if T is not an integer type and
+// with cache.ErrCounterOverflow if the result does not fit it
+func (c *Cache[T]) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	for {
+		n, ok, err := c.incr(key, delta)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			value, err := counter.From[T](delta)
+			if err != nil {
+				return 0, err
+			}
+
+			_, ok = c.store(key, value, nil, 0, 0, func(previous withTTL[T], ok bool) bool {
+				return !ok || previous.expired(c.now())
+			})
+			if !ok {
+				continue // created by a concurrent call
+			}
+			n = delta
+		}
+
+		if delta == 0 {
+			return n, nil
+		}
+
+		return n, c.invalidation.Publish(ctx, key)
+	}
+}
+
+// Touch renews the entry by key, making it expire after the ttl counted from now. The value is not rewritten, the
+// access is registered as a read
+//
+// Returns cache.MissingEntryError if the entry is missing or expired, including entries within the grace period.
+// Entries of the overflow cache are not touched, other subscribers of the invalidator are not notified
+func (c *Cache[T]) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	now := c.now()
+	_, ok := c.storage.Update(key, func(entry withTTL[T]) (withTTL[T], bool) {
+		if entry.expired(now) {
+			return entry, false
+		}
+
+		entry.UpdatedAt = now
+		entry.TTL = &ttl
+		return entry, true
+	})
 	if !ok {
-		return *new(T), cache.NewMissingEntryError(key)
+		return cache.NewMissingEntryError(key)
+	}
+	c.buckets.add(key, now.Add(ttl+c.grace))
+
+	return nil
+}
+
+// SetWithTTL puts provided value by cache key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	if !c.set(key, value, &ttl) {
+		return nil
+	}
+
+	return c.invalidation.Publish(ctx, key)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to cache using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		if c.set(kv.Key, kv.Value, &ttl) {
+			keys = append(keys, kv.Key)
+		}
+	}
+
+	return c.invalidation.Publish(ctx, keys...)
+}
+
+// entryOverhead is the size of entry metadata stored along with the value
+const entryOverhead = int64(unsafe.Sizeof(withTTL[struct{}]{}))
+
+type withTTL[T any] struct {
+	UpdatedAt time.Time
+	TTL       *time.Duration
+	Cost      int64
+	Hash      uint64          // hash of the serialized value, 0 if unknown
+	Delta     time.Duration   // time the fetch producing the value took, 0 if it was written directly
+	Access    *access.Tracker // reads of the value, nil unless access is tracked
+	Value     T
+}
+
+func (e withTTL[T]) expired(now time.Time) bool {
+	return e.TTL != nil && !e.UpdatedAt.Add(*e.TTL).After(now)
+}
+
+func (e withTTL[T]) remaining(now time.Time) time.Duration {
+	if e.TTL == nil {
+		return cache.NoExpiration
+	}
+
+	return e.UpdatedAt.Add(*e.TTL).Sub(now)
+}
+
+func (c *Cache[T]) get(ctx context.Context, key string) (T, error) {
+	return c.getWithOptions(ctx, key, cache.GetOptions{})
+}
+
+func (c *Cache[T]) getWithOptions(ctx context.Context, key string, o cache.GetOptions) (T, error) {
+	entry, err := c.getEntry(ctx, key, o)
+	return entry.Value, err
+}
+
+func (c *Cache[T]) getEntry(ctx context.Context, key string, o cache.GetOptions) (withTTL[T], error) {
+	var (
+		entry withTTL[T]
+		ok    bool
+	)
+
+	switch {
+	case o.NoPromote:
+		entry, ok = c.storage.Peek(key)
+	case c.sliding > 0:
+		entry, ok = c.slide(key)
+	default:
+		entry, ok = c.storage.Get(key)
 	}
 
-	casted, ok := value.(withTTL[T])
 	if !ok {
-		c.delete(key)
+		if c.overflow != nil {
+			value, err := c.overflow.Get(ctx, key)
+			if err != nil && !isMiss(err) {
+				c.overflowFailed(fmt.Errorf("could not read key %s from overflow cache: %w", key, err))
+				return withTTL[T]{}, cache.NewMissingEntryError(key)
+			}
 
-		return *new(T), cache.NewFailedToCastEntryError(key, nil)
+			return withTTL[T]{Value: value}, err
+		}
+
+		return withTTL[T]{}, cache.NewMissingEntryError(key)
 	}
 
-	if casted.TTL == nil {
-		return casted.Value, nil
+	if entry.TTL == nil {
+		entry.Access.Record()
+		return entry, nil
 	}
 
-	now := time.Now()
-	if casted.UpdatedAt.Add(*casted.TTL).After(now) {
-		return casted.Value, nil
+	now := c.now()
+	expiresAt := entry.UpdatedAt.Add(*entry.TTL)
+	if expiresAt.After(now) || now.Sub(expiresAt) < o.StaleMaxAge {
+		entry.Access.Record()
+		return entry, nil
 	}
 
-	c.delete(key)
+	if now.Sub(expiresAt) < c.grace {
+		return entry, cache.NewStaleEntryError(key, now.Sub(expiresAt))
+	}
+
+	c.expire(key, entry)
+
+	return withTTL[T]{}, cache.NewMissingEntryError(key)
+}
+
+// incr adds delta to the live entry by key in place, keeping its expiration time. Reports whether the entry exists
+func (c *Cache[T]) incr(key string, delta int64) (int64, bool, error) {
+	var (
+		n   int64
+		err error
+	)
+
+	now := c.now()
+	_, updated := c.storage.Update(key, func(entry withTTL[T]) (withTTL[T], bool) {
+		if entry.expired(now) {
+			return entry, false
+		}
+
+		if n, err = counter.Add(&entry.Value, delta); err != nil {
+			return entry, false
+		}
+
+		if entry.TTL != nil {
+			remaining := entry.remaining(now)
+			entry.TTL = &remaining
+		}
+		entry.UpdatedAt = now
+		entry.Hash = 0
+		return entry, true
+	})
+	if updated {
+		c.stats.Set(1)
+	}
+
+	return n, updated, err
+}
+
+// popOverflow pops the entry from the overflow cache, atomically if it implements cache.Popper
+func (c *Cache[T]) popOverflow(ctx context.Context, key string) (T, error) {
+	if popper, ok := c.overflow.(cache.Popper[T]); ok {
+		return popper.Pop(ctx, key)
+	}
+
+	value, err := c.overflow.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
 
-	return *new(T), cache.NewMissingEntryError(key)
+	return value, c.overflow.Delete(ctx, key)
 }
 
-func (c *Cache[T]) set(key string, value T, ttl *time.Duration) {
+// slide reads the entry by key, renewing its TTL with the sliding one if it is not expired. The access is registered
+// only for renewed entries
+func (c *Cache[T]) slide(key string) (withTTL[T], bool) {
+	var (
+		entry   withTTL[T]
+		found   bool
+		renewed bool
+	)
+
+	now := c.now()
+	sliding := c.sliding
+	c.storage.Update(key, func(stored withTTL[T]) (withTTL[T], bool) {
+		entry, found = stored, true
+		if stored.expired(now) {
+			return stored, false
+		}
+
+		entry.UpdatedAt = now
+		entry.TTL = &sliding
+		renewed = true
+		return entry, true
+	})
+
+	if renewed {
+		c.buckets.add(key, now.Add(sliding+c.grace))
+	}
+
+	return entry, found
+}
+
+// setIf puts the value only if the presence of a live entry by key matches present, reporting whether it was stored
+func (c *Cache[T]) setIf(ctx context.Context, key string, value T, ttl time.Duration, present bool) (bool, error) {
+	if cache.IsBypassed(ctx) {
+		return false, nil
+	}
+
+	now := c.now()
+	changed, stored := c.store(key, value, &ttl, 0, 0, func(previous withTTL[T], ok bool) bool {
+		return (ok && !previous.expired(now)) == present
+	})
+	if !changed {
+		return stored, nil
+	}
+
+	return stored, c.invalidation.Publish(ctx, key)
+}
+
+// set puts the entry, reporting whether the value has changed
+func (c *Cache[T]) set(key string, value T, ttl *time.Duration) bool {
+	return c.setWithCost(key, value, ttl, 0)
+}
+
+func (c *Cache[T]) setWithCost(key string, value T, ttl *time.Duration, cost int64) bool {
+	changed, _ := c.store(key, value, ttl, cost, 0, nil)
+	return changed
+}
+
+// store puts the entry if cond approves the resident entry as store.AddIf does, reporting whether the value has
+// changed and whether it was stored. Nil cond approves any, delta is the time the fetch producing the value took
+func (c *Cache[T]) store(
+	key string,
+	value T,
+	ttl *time.Duration,
+	cost int64,
+	delta time.Duration,
+	cond func(previous withTTL[T], ok bool) bool,
+) (bool, bool) {
 	finalTTL := c.defaultTTL
 	if ttl != nil {
 		finalTTL = ttl
+	} else if c.sliding > 0 {
+		sliding := c.sliding
+		finalTTL = &sliding
+	} else if c.ttlPolicy != nil {
+		if policyTTL := c.ttlPolicy.TTL(key, value); policyTTL > 0 {
+			finalTTL = &policyTTL
+		}
 	}
 
-	c.storage.Add(key, withTTL[T]{
-		UpdatedAt: time.Now(),
+	if cost <= 0 {
+		cost = c.cost(key, value)
+	}
+
+	now := c.now()
+	hash := c.hash(value)
+	changed := hash == 0 || !c.stored(key, hash, now)
+
+	entry := withTTL[T]{
+		UpdatedAt: now,
 		TTL:       finalTTL,
+		Cost:      cost,
+		Hash:      hash,
+		Delta:     delta,
+		Access:    c.newTracker(),
 		Value:     value,
-	})
+	}
+	c.negatives.Forget(key)
+
+	res, ok := addResult[withTTL[T]]{}, true
+	if cond == nil {
+		res = c.storage.Add(key, entry)
+	} else {
+		res, ok = c.storage.AddIf(key, entry, cond)
+	}
+
+	if !ok {
+		return false, false
+	}
+	c.stats.Set(1)
+
+	if finalTTL != nil {
+		c.buckets.add(key, now.Add(*finalTTL+c.grace))
+	} else {
+		c.buckets.remove(key)
+	}
+
+	if res.replaced {
+		c.replace(key, res.previous, now)
+	}
+
+	if res.evicted {
+		c.stats.Evict()
+		c.buckets.remove(res.evictedKey)
+		c.evict(res.evictedKey, res.evictedValue)
+	}
+
+	return changed, true
+}
+
+// replace reports the entry overwritten by a write, expired ones are reported as expired
+func (c *Cache[T]) replace(key string, previous withTTL[T], now time.Time) {
+	reason := cache.ReasonReplaced
+	if previous.expired(now) {
+		reason = cache.ReasonExpired
+		c.stats.Expire()
+	}
+
+	if c.onEvict != nil {
+		c.onEvict(key, previous.Value, reason)
+	}
+}
+
+// stored reports whether the live entry by key has a value with the provided hash
+func (c *Cache[T]) stored(key string, hash uint64, now time.Time) bool {
+	entry, ok := c.storage.Peek(key)
+	return ok && entry.Hash == hash && !entry.expired(now)
+}
+
+// evict handles the entry evicted because of capacity
+func (c *Cache[T]) evict(key string, entry withTTL[T]) {
+	c.spill(key, entry)
+
+	if c.archiver != nil {
+		c.archiver(key, entry.Value)
+	}
+
+	if c.onEvict != nil {
+		c.onEvict(key, entry.Value, cache.ReasonEvicted)
+	}
+}
+
+// spill writes the entry evicted because of capacity to the overflow cache
+func (c *Cache[T]) spill(key string, entry withTTL[T]) {
+	if c.overflow == nil {
+		return
+	}
+
+	ctx := context.Background()
+	var err error
+	if entry.TTL == nil {
+		err = c.overflow.Set(ctx, key, entry.Value)
+	} else {
+		remaining := entry.UpdatedAt.Add(*entry.TTL).Sub(c.now())
+		if remaining <= 0 {
+			return
+		}
+
+		if ttlCacher, ok := c.overflow.(cache.TTLCacher[T]); ok {
+			err = ttlCacher.SetWithTTL(ctx, key, entry.Value, remaining)
+		} else {
+			err = c.overflow.Set(ctx, key, entry.Value)
+		}
+	}
+
+	if err != nil {
+		c.overflowFailed(fmt.Errorf("could not write key %s to overflow cache: %w", key, err))
+	}
+}
+
+// overflowFailed passes the error of the overflow cache to the handler, if any
+func (c *Cache[T]) overflowFailed(err error) {
+	if c.onOverflowError != nil {
+		c.onOverflowError(err)
+	}
+}
+
+// hash returns the hash of the serialized value, 0 if values are not compared
+func (c *Cache[T]) hash(value T) uint64 {
+	if c.hashCodec == nil {
+		return 0
+	}
+
+	encoded, err := c.hashCodec.Marshal(value)
+	if err != nil {
+		return 0
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(encoded)
+	return h.Sum64()
+}
+
+func (c *Cache[T]) cost(key string, value T) int64 {
+	if c.costFunc != nil {
+		return c.costFunc(key, value)
+	}
+
+	return cache.EstimateCost(key, value) + entryOverhead
+}
+
+// expire removes the expired entry, passing it to the archiver and the eviction callback
+func (c *Cache[T]) expire(key string, entry withTTL[T]) {
+	_, removed := c.storage.Remove(key)
+	c.buckets.remove(key)
+
+	if c.overflow != nil {
+		if err := c.overflow.Delete(context.Background(), key); err != nil {
+			c.overflowFailed(fmt.Errorf("could not delete key %s from overflow cache: %w", key, err))
+		}
+	}
+
+	if removed {
+		c.expired(key, entry)
+	}
+}
+
+// expired reports the entry removed because of expiration
+func (c *Cache[T]) expired(key string, entry withTTL[T]) {
+	c.stats.Expire()
+
+	if c.archiver != nil {
+		c.archiver(key, entry.Value)
+	}
+
+	if c.onEvict != nil {
+		c.onEvict(key, entry.Value, cache.ReasonExpired)
+	}
 }
 
 func (c *Cache[T]) delete(key string) {
-	c.storage.Remove(key)
+	entry, removed := c.storage.Remove(key)
+	c.buckets.remove(key)
+
+	if c.overflow != nil {
+		if err := c.overflow.Delete(context.Background(), key); err != nil {
+			c.overflowFailed(fmt.Errorf("could not delete key %s from overflow cache: %w", key, err))
+		}
+	}
+
+	if removed {
+		c.deleted(key, entry)
+	}
+}
+
+func (c *Cache[T]) deleted(key string, entry withTTL[T]) {
+	c.stats.Delete(1)
+
+	if c.onEvict != nil {
+		c.onEvict(key, entry.Value, cache.ReasonDeleted)
+	}
+}
+
+func (c *Cache[T]) sweep(interval time.Duration, buckets *ttlBuckets, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			now := c.now()
+			for _, key := range buckets.due(now) {
+				entry, ok := c.storage.Peek(key)
+				if ok && entry.TTL != nil && !entry.UpdatedAt.Add(*entry.TTL+c.grace).After(now) {
+					c.expire(key, entry)
+				}
+			}
+		}
+	}
+}
+
+func (c *Cache[T]) stopReporter() {
+	if c.stopReport != nil {
+		close(c.stopReport)
+		c.stopReport = nil
+	}
+}
+
+func (c *Cache[T]) stopSweeper() {
+	if c.stopSweep != nil {
+		close(c.stopSweep)
+		c.stopSweep = nil
+	}
+}
+
+// lookup reads the entry for GetOrFetch
+func (c *Cache[T]) lookup(ctx context.Context, key string) (withTTL[T], error) {
+	return c.getEntry(ctx, key, cache.GetOptions{})
+}
+
+// expiresEarly reports whether GetOrFetch has to refresh the live entry before it expires, see WithEarlyRefresh
+func (c *Cache[T]) expiresEarly(entry withTTL[T]) bool {
+	if c.earlyBeta <= 0 || c.sliding > 0 || entry.TTL == nil {
+		return false
+	}
+
+	return xfetch.Early(c.now(), entry.UpdatedAt.Add(*entry.TTL), entry.Delta, c.earlyBeta)
+}
+
+// newTracker returns the access tracker of a written entry, nil unless access is tracked
+func (c *Cache[T]) newTracker() *access.Tracker {
+	if !c.trackAccess {
+		return nil
+	}
+
+	return access.New()
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var (
+		missingEntryError cache.MissingEntryError
+		staleEntryError   cache.StaleEntryError
+	)
+
+	return errors.As(err, &missingEntryError) || errors.As(err, &staleEntryError)
+}
+
+// fetchMulti loads the values of keys claimed by GetOrFetchMulti with a single fetch call and stores them
+func (c *Cache[T]) fetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+	force bool,
+) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	missing := keys
+	if !force {
+		// values may have been stored by loads finished in the meantime
+		missing = make([]string, 0, len(keys))
+		for _, key := range keys {
+			entry, err := c.lookup(ctx, key)
+			if err != nil {
+				missing = append(missing, key)
+				continue
+			}
+
+			values[key] = entry.Value
+		}
+	}
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	if err := c.fetchRateLimiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	release, err := c.fetchLimiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	items, err := fetch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		c.set(item.Key, item.Value, nil)
+		values[item.Key] = item.Value
+	}
+
+	return values, nil
 }
@@ -0,0 +1,53 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/inmem"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+// failingCache fails every read
+type failingCache struct {
+	cache.Cacher[int]
+}
+
+func (failingCache) Get(context.Context, string) (int, error) {
+	return 0, errUnavailable
+}
+
+func TestOverflowReadErrorsAreMisses(t *testing.T) {
+	ctx := context.Background()
+
+	var reported []error
+	c, err := NewCache[int](1)
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	c.WithOverflow(failingCache{Cacher: inmem.NewCache[int]()}).
+		WithOverflowErrorHandler(func(err error) { reported = append(reported, err) })
+
+	var missingEntryError cache.MissingEntryError
+	if _, err := c.Get(ctx, "key"); !errors.As(err, &missingEntryError) {
+		t.Errorf("Get() error = %v, want cache.MissingEntryError", err)
+	}
+
+	got, err := c.GetOrFetch(ctx, "key", func(context.Context) (int, error) { return 1, nil })
+	if err != nil || got != 1 {
+		t.Errorf("GetOrFetch() = %v, %v, want 1", got, err)
+	}
+
+	if len(reported) < 2 {
+		t.Errorf("reported %d errors, want one per read", len(reported))
+	}
+
+	for _, err := range reported {
+		if !errors.Is(err, errUnavailable) {
+			t.Errorf("reported error = %v, want wrapping %v", err, errUnavailable)
+		}
+	}
+}
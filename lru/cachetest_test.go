@@ -0,0 +1,24 @@
+package lru_test
+
+import (
+	"testing"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/cachetest"
+	"github.com/sinu5oid/cache/lru"
+)
+
+func TestCacher(t *testing.T) {
+	for _, policy := range []lru.Policy{lru.ARC, lru.TwoQueue, lru.LRU, lru.LFU} {
+		t.Run(policy.String(), func(t *testing.T) {
+			cachetest.RunCacherTests(t, func() cache.TTLCacher[string] {
+				c, err := lru.New[string](lru.WithPolicy(policy))
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+
+				return c
+			})
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package lru
+
+import (
+	"container/list"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// lfu is a least frequently used cache reporting the entries it evicts
+//
+// Entries are kept in lists by access count, so accesses and evictions take constant time. Among equally used entries
+// the least recently used one is evicted. Safe for concurrent usage
+type lfu[V any] struct {
+	size    int
+	items   map[string]*list.Element
+	freqs   map[int]*list.List // entries by access count, most recently used first
+	minFreq int
+
+	lock sync.Mutex
+}
+
+type lfuEntry[V any] struct {
+	key   string
+	value V
+	freq  int
+}
+
+func newLFU[V any](size int) (*lfu[V], error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+
+	return &lfu[V]{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		freqs: make(map[int]*list.List),
+	}, nil
+}
+
+// Get returns the value by key, counting the access
+func (l *lfu[V]) Get(key string) (V, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return *new(V), false
+	}
+
+	l.touch(elem)
+
+	return elem.Value.(*lfuEntry[V]).value, true
+}
+
+// Peek returns the value by key without counting the access
+func (l *lfu[V]) Peek(key string) (V, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.peek(key)
+}
+
+func (l *lfu[V]) peek(key string) (V, bool) {
+	elem, ok := l.items[key]
+	if !ok {
+		return *new(V), false
+	}
+
+	return elem.Value.(*lfuEntry[V]).value, true
+}
+
+// Contains reports whether the key is resident without counting the access
+func (l *lfu[V]) Contains(key string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	_, ok := l.items[key]
+	return ok
+}
+
+// Add puts the value by key, counting the access of existing entries. Reports the value it replaced and the entry
+// evicted to make room for it, if any
+func (l *lfu[V]) Add(key string, value V) addResult[V] {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.add(key, value)
+}
+
+// AddIf puts the value by key as Add does if cond approves the resident value, or its absence. cond is called under
+// the lock. Reports whether the value was put
+func (l *lfu[V]) AddIf(key string, value V, cond func(previous V, ok bool) bool) (addResult[V], bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !cond(l.peek(key)) {
+		return addResult[V]{}, false
+	}
+
+	return l.add(key, value), true
+}
+
+func (l *lfu[V]) add(key string, value V) (res addResult[V]) {
+	if elem, ok := l.items[key]; ok {
+		entry := elem.Value.(*lfuEntry[V])
+		res.previous, res.replaced = entry.value, true
+		entry.value = value
+		l.touch(elem)
+		return res
+	}
+
+	if len(l.items) >= l.size {
+		res.evictedKey, res.evictedValue, res.evicted = l.evict()
+	}
+
+	l.items[key] = l.bucket(1).PushFront(&lfuEntry[V]{key: key, value: value, freq: 1})
+	l.minFreq = 1
+
+	return res
+}
+
+// Update replaces the value of the resident entry by key with the one f returns unless f reports false, counting the
+// access. Returns the stored value and whether it was replaced
+func (l *lfu[V]) Update(key string, f func(value V) (V, bool)) (V, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return *new(V), false
+	}
+
+	entry := elem.Value.(*lfuEntry[V])
+	updated, ok := f(entry.value)
+	if !ok {
+		return entry.value, false
+	}
+
+	entry.value = updated
+	l.touch(elem)
+
+	return updated, true
+}
+
+// Remove deletes the entry by key. Returns the removed value, if any
+func (l *lfu[V]) Remove(key string) (V, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return *new(V), false
+	}
+
+	entry := l.unlink(elem)
+	delete(l.items, key)
+
+	return entry.value, true
+}
+
+// Resize changes the capacity, evicting the least frequently used entries until they fit it
+func (l *lfu[V]) Resize(size int) map[string]V {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.size = size
+
+	evicted := make(map[string]V)
+	for len(l.items) > size {
+		k, v, ok := l.evict()
+		if !ok {
+			break
+		}
+		evicted[k] = v
+	}
+
+	return evicted
+}
+
+// Keys returns resident keys, the least frequently used first
+func (l *lfu[V]) Keys() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	keys := make([]string, 0, len(l.items))
+	for _, freq := range slices.Sorted(maps.Keys(l.freqs)) {
+		for elem := l.freqs[freq].Back(); elem != nil; elem = elem.Prev() {
+			keys = append(keys, elem.Value.(*lfuEntry[V]).key)
+		}
+	}
+
+	return keys
+}
+
+// Len returns the number of resident entries
+func (l *lfu[V]) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.items)
+}
+
+// Purge removes all the entries. Returns the removed entries if drain is set
+func (l *lfu[V]) Purge(drain bool) map[string]V {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var removed map[string]V
+	if drain {
+		removed = make(map[string]V, len(l.items))
+		for key, elem := range l.items {
+			removed[key] = elem.Value.(*lfuEntry[V]).value
+		}
+	}
+
+	clear(l.items)
+	clear(l.freqs)
+	l.minFreq = 0
+
+	return removed
+}
+
+// touch moves the entry to the list of the next access count
+func (l *lfu[V]) touch(elem *list.Element) {
+	entry := l.unlink(elem)
+	entry.freq++
+	l.items[entry.key] = l.bucket(entry.freq).PushFront(entry)
+}
+
+// unlink removes the element from its access count list, dropping the list if it becomes empty
+func (l *lfu[V]) unlink(elem *list.Element) *lfuEntry[V] {
+	entry := elem.Value.(*lfuEntry[V])
+
+	bucket := l.freqs[entry.freq]
+	bucket.Remove(elem)
+	if bucket.Len() == 0 {
+		delete(l.freqs, entry.freq)
+		if l.minFreq == entry.freq {
+			l.minFreq++
+		}
+	}
+
+	return entry
+}
+
+// evict removes the least recently used of the least frequently used entries
+func (l *lfu[V]) evict() (string, V, bool) {
+	if len(l.items) == 0 {
+		return "", *new(V), false
+	}
+
+	if _, ok := l.freqs[l.minFreq]; !ok {
+		// removals may leave minFreq pointing below the least used entries
+		l.minFreq = slices.Min(slices.Collect(maps.Keys(l.freqs)))
+	}
+
+	entry := l.unlink(l.freqs[l.minFreq].Back())
+	delete(l.items, entry.key)
+
+	return entry.key, entry.value, true
+}
+
+func (l *lfu[V]) bucket(freq int) *list.List {
+	bucket, ok := l.freqs[freq]
+	if !ok {
+		bucket = list.New()
+		l.freqs[freq] = bucket
+	}
+
+	return bucket
+}
@@ -0,0 +1,109 @@
+package lru
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/keylock"
+)
+
+// defaultSize is the capacity of caches created by New without WithSize
+const defaultSize = 1024
+
+// Option configures the Cache created by New
+type Option func(*config)
+
+type config struct {
+	size    int
+	policy  Policy
+	ttl     *time.Duration
+	janitor time.Duration
+	now     func() time.Time
+
+	statsInterval time.Duration
+	statsReport   func(stats cache.Stats)
+}
+
+// WithSize assigns the capacity, 1024 entries by default
+func WithSize(n int) Option {
+	return func(c *config) {
+		c.size = n
+	}
+}
+
+// WithPolicy assigns the eviction policy, ARC by default
+func WithPolicy(policy Policy) Option {
+	return func(c *config) {
+		c.policy = policy
+	}
+}
+
+// WithTTL assigns the default TTL, see Cache.WithTTL
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = &ttl
+	}
+}
+
+// WithJanitor removes expired entries in background with the granularity, see Cache.WithExpirySweep
+func WithJanitor(granularity time.Duration) Option {
+	return func(c *config) {
+		c.janitor = granularity
+	}
+}
+
+// WithClock assigns the function returning the current time, see Cache.WithClock
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+// WithStats calls report with the cache Stats every interval, see Cache.WithStatsReporter
+func WithStats(interval time.Duration, report func(stats cache.Stats)) Option {
+	return func(c *config) {
+		c.statsInterval = interval
+		c.statsReport = report
+	}
+}
+
+// New creates a Cache configured with the options
+//
+// Options cover the common settings, the rest are assigned with the Cache methods. Call Close if WithJanitor is used
+func New[T any](opts ...Option) (*Cache[T], error) {
+	cfg := config{
+		size:   defaultSize,
+		policy: ARC,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s, err := newStore[withTTL[T]](cfg.size, cfg.policy)
+	if err != nil {
+		return nil, fmt.Errorf("could not create new LRU %s cache: %w", cfg.policy, err)
+	}
+
+	c := &Cache[T]{
+		storage:    s,
+		locks:      keylock.New(),
+		updates:    keylock.New(),
+		defaultTTL: cfg.ttl,
+		now:        time.Now,
+	}
+
+	if cfg.now != nil {
+		c.WithClock(cfg.now)
+	}
+
+	if cfg.janitor > 0 {
+		c.WithExpirySweep(cfg.janitor)
+	}
+
+	if cfg.statsInterval > 0 && cfg.statsReport != nil {
+		c.WithStatsReporter(cfg.statsInterval, cfg.statsReport)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,89 @@
+package lru
+
+import "fmt"
+
+// Policy selects the algorithm deciding which entries are evicted when the cache is full
+type Policy int
+
+const (
+	// ARC balances recency and frequency, adapting to the workload. Keeps ghost records of up to size recently
+	// evicted keys. The default policy
+	ARC Policy = iota
+	// TwoQueue separates entries seen once from entries seen repeatedly, so scans do not flush frequently used ones.
+	// Keeps ghost records of up to half size recently evicted keys
+	TwoQueue
+	// LRU evicts the least recently used entry. Has no ghost records
+	LRU
+	// LFU evicts the least frequently used entry, the least recently used one among equally used. Has no ghost
+	// records
+	LFU
+)
+
+// String returns the policy name
+func (p Policy) String() string {
+	switch p {
+	case ARC:
+		return "ARC"
+	case TwoQueue:
+		return "2Q"
+	case LRU:
+		return "LRU"
+	case LFU:
+		return "LFU"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// store holds resident entries, evicting them according to the policy. Implementations are safe for concurrent usage
+type store[V any] interface {
+	// Get returns the value by key, registering the access
+	Get(key string) (V, bool)
+	// Peek returns the value by key without registering the access
+	Peek(key string) (V, bool)
+	// Contains reports whether the key is resident without registering the access
+	Contains(key string) bool
+	// Add puts the value by key, reporting the value it replaced and the entry evicted to make room for it
+	Add(key string, value V) addResult[V]
+	// AddIf puts the value by key as Add does if cond approves the resident value, or its absence. Reports whether the
+	// value was put
+	AddIf(key string, value V, cond func(previous V, ok bool) bool) (addResult[V], bool)
+	// Update replaces the value of the resident entry by key with the one f returns unless f reports false,
+	// registering the access. Returns the stored value and whether it was replaced
+	Update(key string, f func(value V) (V, bool)) (V, bool)
+	// Remove deletes the entry by key, returning the removed value
+	Remove(key string) (V, bool)
+	// Keys returns resident keys
+	Keys() []string
+	// Len returns the number of resident entries
+	Len() int
+	// Purge removes all the entries, returning them if drain is set
+	Purge(drain bool) map[string]V
+	// Resize changes the capacity, returning the entries evicted to fit it
+	Resize(size int) map[string]V
+}
+
+// addResult describes the entries removed by store.Add
+type addResult[V any] struct {
+	previous V // the value replaced by key
+	replaced bool
+
+	evictedKey   string // the entry evicted to make room
+	evictedValue V
+	evicted      bool
+}
+
+func newStore[V any](size int, policy Policy) (store[V], error) {
+	switch policy {
+	case ARC:
+		return newARC[V](size)
+	case TwoQueue:
+		return newTwoQueue[V](size)
+	case LRU:
+		return newRecency[V](size)
+	case LFU:
+		return newLFU[V](size)
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %s", policy)
+	}
+}
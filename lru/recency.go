@@ -0,0 +1,179 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// recency is a plain LRU cache reporting the entries it evicts. Safe for concurrent usage
+type recency[V any] struct {
+	size int
+	list *simplelru.LRU[string, V]
+
+	lock sync.Mutex
+}
+
+func newRecency[V any](size int) (*recency[V], error) {
+	list, err := simplelru.NewLRU[string, V](size, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create LRU list: %w", err)
+	}
+
+	return &recency[V]{
+		size: size,
+		list: list,
+	}, nil
+}
+
+// Get returns the value by key, marking the entry as the most recently used
+func (r *recency[V]) Get(key string) (V, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.list.Get(key)
+}
+
+// Peek returns the value by key without updating recency
+func (r *recency[V]) Peek(key string) (V, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.list.Peek(key)
+}
+
+// Contains reports whether the key is resident without updating recency
+func (r *recency[V]) Contains(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.list.Contains(key)
+}
+
+// Add puts the value by key. Reports the value it replaced and the entry evicted to make room for it, if any
+func (r *recency[V]) Add(key string, value V) addResult[V] {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.add(key, value)
+}
+
+// AddIf puts the value by key as Add does if cond approves the resident value, or its absence. cond is called under
+// the lock. Reports whether the value was put
+func (r *recency[V]) AddIf(key string, value V, cond func(previous V, ok bool) bool) (addResult[V], bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !cond(r.peek(key)) {
+		return addResult[V]{}, false
+	}
+
+	return r.add(key, value), true
+}
+
+func (r *recency[V]) peek(key string) (V, bool) {
+	return r.list.Peek(key)
+}
+
+func (r *recency[V]) add(key string, value V) (res addResult[V]) {
+	res.previous, res.replaced = r.list.Peek(key)
+	if !res.replaced && r.list.Len() >= r.size {
+		res.evictedKey, res.evictedValue, res.evicted = r.list.RemoveOldest()
+	}
+
+	r.list.Add(key, value)
+
+	return res
+}
+
+// Update replaces the value of the resident entry by key with the one f returns unless f reports false, marking the
+// entry as the most recently used. Returns the stored value and whether it was replaced
+func (r *recency[V]) Update(key string, f func(value V) (V, bool)) (V, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	value, ok := r.list.Peek(key)
+	if !ok {
+		return value, false
+	}
+
+	updated, ok := f(value)
+	if !ok {
+		return value, false
+	}
+
+	r.list.Add(key, updated)
+
+	return updated, true
+}
+
+// Remove deletes the entry by key. Returns the removed value, if any
+func (r *recency[V]) Remove(key string) (V, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	value, ok := r.list.Peek(key)
+	if ok {
+		r.list.Remove(key)
+	}
+
+	return value, ok
+}
+
+// Resize changes the capacity, evicting the least recently used entries until they fit it
+func (r *recency[V]) Resize(size int) map[string]V {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.size = size
+
+	evicted := make(map[string]V)
+	for r.list.Len() > size {
+		k, v, ok := r.list.RemoveOldest()
+		if !ok {
+			break
+		}
+		evicted[k] = v
+	}
+
+	r.list.Resize(size)
+
+	return evicted
+}
+
+// Keys returns resident keys ordered from oldest to newest
+func (r *recency[V]) Keys() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.list.Keys()
+}
+
+// Len returns the number of resident entries
+func (r *recency[V]) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.list.Len()
+}
+
+// Purge removes all the entries. Returns the removed entries if drain is set
+func (r *recency[V]) Purge(drain bool) map[string]V {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var removed map[string]V
+	if drain {
+		removed = make(map[string]V, r.list.Len())
+		for _, k := range r.list.Keys() {
+			if value, ok := r.list.Peek(k); ok {
+				removed[k] = value
+			}
+		}
+	}
+
+	r.list.Purge()
+
+	return removed
+}
@@ -0,0 +1,261 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+const (
+	// twoQueueRecentRatio is the share of the size reserved for entries seen once
+	twoQueueRecentRatio = 0.25
+	// twoQueueGhostRatio is the share of the size kept as ghost records of evicted entries seen once
+	twoQueueGhostRatio = 0.5
+)
+
+// twoQueue is a 2Q cache reporting the entries it evicts
+//
+// Follows "2Q: A Low Overhead High Performance Buffer Management Replacement Algorithm" by T. Johnson and D. Shasha,
+// with the parameters of lru.TwoQueueCache, which is not used directly as it silently drops evicted values.
+// Safe for concurrent usage
+type twoQueue[V any] struct {
+	size       int
+	recentSize int
+
+	recent      *simplelru.LRU[string, V]        // resident entries seen once
+	frequent    *simplelru.LRU[string, V]        // resident entries seen at least twice
+	recentEvict *simplelru.LRU[string, struct{}] // keys recently evicted from recent
+
+	lock sync.Mutex
+}
+
+func newTwoQueue[V any](size int) (*twoQueue[V], error) {
+	recent, err := simplelru.NewLRU[string, V](size, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create 2Q list: %w", err)
+	}
+
+	// sizes are validated by the first list
+	frequent, _ := simplelru.NewLRU[string, V](size, nil)
+	recentEvict, _ := simplelru.NewLRU[string, struct{}](max(int(float64(size)*twoQueueGhostRatio), 1), nil)
+
+	return &twoQueue[V]{
+		size:        size,
+		recentSize:  int(float64(size) * twoQueueRecentRatio),
+		recent:      recent,
+		frequent:    frequent,
+		recentEvict: recentEvict,
+	}, nil
+}
+
+// Get returns the value by key, promoting the entry to the frequently used list
+func (q *twoQueue[V]) Get(key string) (V, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if value, ok := q.frequent.Get(key); ok {
+		return value, true
+	}
+
+	if value, ok := q.recent.Peek(key); ok {
+		q.recent.Remove(key)
+		q.frequent.Add(key, value)
+		return value, true
+	}
+
+	return *new(V), false
+}
+
+// Peek returns the value by key without updating recency or frequency
+func (q *twoQueue[V]) Peek(key string) (V, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.peek(key)
+}
+
+func (q *twoQueue[V]) peek(key string) (V, bool) {
+	if value, ok := q.frequent.Peek(key); ok {
+		return value, true
+	}
+
+	return q.recent.Peek(key)
+}
+
+// Contains reports whether the key is resident without updating recency or frequency
+func (q *twoQueue[V]) Contains(key string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.frequent.Contains(key) || q.recent.Contains(key)
+}
+
+// Add puts the value by key. Reports the value it replaced and the entry evicted to make room for it, if any
+func (q *twoQueue[V]) Add(key string, value V) addResult[V] {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.add(key, value)
+}
+
+// AddIf puts the value by key as Add does if cond approves the resident value, or its absence. cond is called under
+// the lock. Reports whether the value was put
+func (q *twoQueue[V]) AddIf(key string, value V, cond func(previous V, ok bool) bool) (addResult[V], bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if !cond(q.peek(key)) {
+		return addResult[V]{}, false
+	}
+
+	return q.add(key, value), true
+}
+
+func (q *twoQueue[V]) add(key string, value V) (res addResult[V]) {
+	if res.previous, res.replaced = q.frequent.Peek(key); res.replaced {
+		q.frequent.Add(key, value)
+		return res
+	}
+
+	if res.previous, res.replaced = q.recent.Peek(key); res.replaced {
+		q.recent.Remove(key)
+		q.frequent.Add(key, value)
+		return res
+	}
+
+	if q.recentEvict.Contains(key) {
+		res.evictedKey, res.evictedValue, res.evicted = q.ensureSpace(true)
+		q.recentEvict.Remove(key)
+		q.frequent.Add(key, value)
+		return res
+	}
+
+	res.evictedKey, res.evictedValue, res.evicted = q.ensureSpace(false)
+	q.recent.Add(key, value)
+
+	return res
+}
+
+// Update replaces the value of the resident entry by key with the one f returns unless f reports false, promoting the
+// entry to the frequently used list. Returns the stored value and whether it was replaced
+func (q *twoQueue[V]) Update(key string, f func(value V) (V, bool)) (V, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	value, ok := q.recent.Peek(key)
+	if !ok {
+		if value, ok = q.frequent.Peek(key); !ok {
+			return value, false
+		}
+	}
+
+	updated, ok := f(value)
+	if !ok {
+		return value, false
+	}
+
+	q.recent.Remove(key)
+	q.frequent.Add(key, updated)
+
+	return updated, true
+}
+
+// ensureSpace evicts an entry if the cache is full, preferring entries seen once while they exceed their share
+func (q *twoQueue[V]) ensureSpace(recentEvict bool) (string, V, bool) {
+	recentLen := q.recent.Len()
+	if recentLen+q.frequent.Len() < q.size {
+		return "", *new(V), false
+	}
+
+	if recentLen > 0 && (recentLen > q.recentSize || (recentLen == q.recentSize && !recentEvict)) {
+		k, v, ok := q.recent.RemoveOldest()
+		if ok {
+			q.recentEvict.Add(k, struct{}{})
+		}
+		return k, v, ok
+	}
+
+	return q.frequent.RemoveOldest()
+}
+
+// Remove deletes the entry by key, including its ghost record. Returns the removed value, if any
+func (q *twoQueue[V]) Remove(key string) (V, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.recentEvict.Remove(key)
+
+	for _, l := range []*simplelru.LRU[string, V]{q.frequent, q.recent} {
+		if value, ok := l.Peek(key); ok {
+			l.Remove(key)
+			return value, true
+		}
+	}
+
+	return *new(V), false
+}
+
+// Resize changes the capacity and the shares derived from it, evicting entries as ensureSpace does until they fit
+func (q *twoQueue[V]) Resize(size int) map[string]V {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.size = size
+	q.recentSize = int(float64(size) * twoQueueRecentRatio)
+
+	evicted := make(map[string]V)
+	for q.recent.Len()+q.frequent.Len() > size {
+		k, v, ok := q.ensureSpace(false)
+		if !ok {
+			break
+		}
+		evicted[k] = v
+	}
+
+	q.recent.Resize(size)
+	q.frequent.Resize(size)
+	q.recentEvict.Resize(max(int(float64(size)*twoQueueGhostRatio), 1))
+
+	return evicted
+}
+
+// Keys returns resident keys, frequently used ones first, each list ordered from oldest to newest
+func (q *twoQueue[V]) Keys() []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return append(q.frequent.Keys(), q.recent.Keys()...)
+}
+
+// Len returns the number of resident entries
+func (q *twoQueue[V]) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.recent.Len() + q.frequent.Len()
+}
+
+// Purge removes all the entries and ghost records. Returns the removed entries if drain is set
+func (q *twoQueue[V]) Purge(drain bool) map[string]V {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var removed map[string]V
+	if drain {
+		removed = make(map[string]V, q.recent.Len()+q.frequent.Len())
+		for _, l := range []*simplelru.LRU[string, V]{q.recent, q.frequent} {
+			for _, k := range l.Keys() {
+				if value, ok := l.Peek(k); ok {
+					removed[k] = value
+				}
+			}
+		}
+	}
+
+	q.recent.Purge()
+	q.frequent.Purge()
+	q.recentEvict.Purge()
+
+	return removed
+}
@@ -0,0 +1,378 @@
+// Package memcached provides a typed memcached cache wrapper
+package memcached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+// maxRelativeExpiration is the longest expiration memcached treats as relative, longer ones are unix timestamps
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// Client is the subset of *memcache.Client used by Cache
+//
+// Allows substituting the client, e.g. with an instrumented one
+type Client interface {
+	Get(key string) (*memcache.Item, error)
+	GetMulti(keys []string) (map[string]*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Delete(key string) error
+}
+
+// Cache represents typed memcached client wrapped
+//
+// Keys are prefixed with the base key and must satisfy memcached restrictions: up to 250 bytes, no spaces or
+// control characters. gomemcache does not support contexts, so they are only checked before every command
+type Cache[T any] struct {
+	client     Client
+	baseKey    string
+	codec      cache.Codec[T]
+	defaultTTL *time.Duration
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[T]
+}
+
+// NewCache creates a Cache instance with JSON-encoded values and no TTL
+func NewCache[T any](client Client, baseKey string) (*Cache[T], error) {
+	if client == nil {
+		return nil, errors.New("memcached client is nil")
+	}
+
+	return &Cache[T]{
+		client:  client,
+		baseKey: baseKey,
+		codec:   cache.JSONCodec[T]{},
+	}, nil
+}
+
+// NewCacheWithTTL creates a Cache instance with JSON-encoded values and TTL being set
+func NewCacheWithTTL[T any](client Client, baseKey string, defaultTTL time.Duration) (*Cache[T], error) {
+	c, err := NewCache[T](client, baseKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.WithTTL(defaultTTL), nil
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
+	c.defaultTTL = &ttl
+	return c
+}
+
+// WithCodec assigns the codec used to convert values to bytes stored in memcached, JSON by default
+func (c *Cache[T]) WithCodec(codec cache.Codec[T]) *Cache[T] {
+	c.codec = codec
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return c.get(ctx, key)
+}
+
+// GetOrFetch tries to obtain cached value from memcached. If multiple callers are accessing the same key,
+// later callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Storing is best-effort: its errors are dropped. Honors
+// cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+// Set puts the provided value by cache key
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(ctx, key, value, nil)
+}
+
+// GetMulti returns cached values by provided keys using a single multi-get command per server.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	formatted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, c.formatKey(key))
+	}
+
+	items, err := c.client.GetMulti(formatted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get values from memcached: %w", wrapErr(err))
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for i, key := range keys {
+		item, ok := items[formatted[i]]
+		if !ok {
+			continue
+		}
+
+		value, err := c.codec.Unmarshal(item.Value)
+		if err != nil {
+			continue
+		}
+
+		res = append(res, cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		})
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to cache
+//
+// memcached has no multi-set command, pairs are written one by one
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(ctx, kv.Key, kv.Value, nil))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Delete removes cached value by key
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := c.client.Delete(c.formatKey(key))
+	if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return fmt.Errorf("failed to delete value from memcached: %w", wrapErr(err))
+	}
+
+	return nil
+}
+
+// DeleteMulti removes cached values by keys
+//
+// Memcached has no batched delete, keys are deleted one by one
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	errs := make([]error, 0, len(keys))
+	for _, key := range keys {
+		errs = append(errs, c.Delete(ctx, key))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetWithTTL puts provided value by cache key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(ctx, key, value, &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to cache using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(ctx, kv.Key, kv.Value, &ttl))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.get(ctx, key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.get(ctx, key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			_ = c.set(ctx, key, result, ttl)
+		}
+
+		return result, err
+	})
+}
+
+func (c *Cache[T]) get(ctx context.Context, key string) (T, error) {
+	if err := ctx.Err(); err != nil {
+		return *new(T), err
+	}
+
+	item, err := c.client.Get(c.formatKey(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	if err != nil {
+		return *new(T), fmt.Errorf("failed to get value from memcached: %w", wrapErr(err))
+	}
+
+	value, err := c.codec.Unmarshal(item.Value)
+	if err != nil {
+		return *new(T), cache.NewFailedToCastEntryError(key, err)
+	}
+
+	return value, nil
+}
+
+func (c *Cache[T]) set(ctx context.Context, key string, value T, ttl *time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	encoded, err := c.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not encode value for key %s: %w", key, err)
+	}
+
+	finalTTL := c.defaultTTL
+	if ttl != nil {
+		finalTTL = ttl
+	}
+
+	err = c.client.Set(&memcache.Item{
+		Key:        c.formatKey(key),
+		Value:      encoded,
+		Expiration: expiration(finalTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set value to memcached: %w", wrapErr(err))
+	}
+
+	return nil
+}
+
+func (c *Cache[T]) formatKey(key string) string {
+	return fmt.Sprintf("%s:%s", c.baseKey, key)
+}
+
+// expiration converts ttl into memcached expiration: seconds for up to 30 days, unix timestamp otherwise
+func expiration(ttl *time.Duration) int32 {
+	if ttl == nil || *ttl <= 0 {
+		return 0
+	}
+
+	if *ttl > maxRelativeExpiration {
+		return int32(min(time.Now().Add(*ttl).Unix(), math.MaxInt32))
+	}
+
+	return int32(math.Ceil(ttl.Seconds()))
+}
+
+// wrapErr marks connectivity failures with cache.ErrBackendUnavailable
+func wrapErr(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, memcache.ErrNoServers) {
+		return fmt.Errorf("%w: %w", cache.ErrBackendUnavailable, err)
+	}
+
+	return err
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
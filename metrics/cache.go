@@ -0,0 +1,388 @@
+// Package metrics provides a cache wrapper exposing Prometheus metrics
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sinu5oid/cache"
+)
+
+// Operation label values
+const (
+	opGet             = "get"
+	opSet             = "set"
+	opGetMulti        = "get_multi"
+	opSetMulti        = "set_multi"
+	opDelete          = "delete"
+	opDeleteMulti     = "delete_multi"
+	opExists          = "exists"
+	opGetOrFetch      = "get_or_fetch"
+	opSetWithTTL      = "set_with_ttl"
+	opSetMultiWithTTL = "set_multi_with_ttl"
+)
+
+// collectors are shared by all the caches registered in the same registry, labeled by the cache name
+type collectors struct {
+	hits     *prometheus.CounterVec
+	misses   *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	fetches  *prometheus.HistogramVec
+}
+
+// Cache wraps a cache.Cacher counting hits, misses and errors and observing operation and fetch durations
+//
+// Metrics are labeled with the cache name and the operation:
+//
+//	cache_hits_total{cache, operation}
+//	cache_misses_total{cache, operation}
+//	cache_errors_total{cache, operation}
+//	cache_operation_duration_seconds{cache, operation}
+//	cache_fetch_duration_seconds{cache}
+//
+// Missing entries are counted as misses, not errors. Safe for concurrent usage if the wrapped cache is
+type Cache[T any] struct {
+	backend cache.Cacher[T]
+	name    string
+
+	hits     *prometheus.CounterVec
+	misses   *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration prometheus.ObserverVec
+	fetches  prometheus.Observer
+}
+
+// Wrap creates a Cache registering its collectors in the registerer
+//
+// Multiple caches can be wrapped with the same registerer using different names, the collectors are shared
+func Wrap[T any](c cache.Cacher[T], name string, reg prometheus.Registerer) (*Cache[T], error) {
+	col, err := register(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := prometheus.Labels{"cache": name}
+
+	return &Cache[T]{
+		backend:  c,
+		name:     name,
+		hits:     col.hits.MustCurryWith(labels),
+		misses:   col.misses.MustCurryWith(labels),
+		errors:   col.errors.MustCurryWith(labels),
+		duration: col.duration.MustCurryWith(labels),
+		fetches:  col.fetches.With(labels),
+	}, nil
+}
+
+// Unwrap returns the wrapped cache
+func (c *Cache[T]) Unwrap() cache.Cacher[T] {
+	return c.backend
+}
+
+// Get retrieves an item by key, counting a hit or a miss
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	defer c.observe(opGet, time.Now())
+
+	value, err := c.backend.Get(ctx, key)
+	c.record(opGet, err)
+
+	return value, err
+}
+
+// Set puts the provided value by key
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	defer c.observe(opSet, time.Now())
+
+	err := c.backend.Set(ctx, key, value)
+	c.countError(opSet, err)
+
+	return err
+}
+
+// GetMulti returns cached values by provided keys, counting a hit per found key and a miss per missing key
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	defer c.observe(opGetMulti, time.Now())
+
+	items, err := c.backend.GetMulti(ctx, keys)
+	if err != nil {
+		c.countError(opGetMulti, err)
+		return items, err
+	}
+
+	c.hits.WithLabelValues(opGetMulti).Add(float64(len(items)))
+	c.misses.WithLabelValues(opGetMulti).Add(float64(len(keys) - len(items)))
+
+	return items, nil
+}
+
+// SetMulti puts provided k/v pairs
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	defer c.observe(opSetMulti, time.Now())
+
+	err := c.backend.SetMulti(ctx, kvs)
+	c.countError(opSetMulti, err)
+
+	return err
+}
+
+// Exists reports whether a live entry by key is stored, see cache.Exists
+func (c *Cache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	defer c.observe(opExists, time.Now())
+
+	ok, err := cache.Exists(ctx, c.backend, key)
+	c.countError(opExists, err)
+
+	return ok, err
+}
+
+// Delete removes cached value by key
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	defer c.observe(opDelete, time.Now())
+
+	err := c.backend.Delete(ctx, key)
+	c.countError(opDelete, err)
+
+	return err
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	defer c.observe(opDeleteMulti, time.Now())
+
+	err := c.backend.DeleteMulti(ctx, keys)
+	c.countError(opDeleteMulti, err)
+
+	return err
+}
+
+// GetOrFetch obtains the value by key, counting a miss when the fetcher is called and a hit otherwise
+//
+// Uses the wrapped cache GetOrFetch if it implements cache.FetchingCacher, otherwise calls Get, then the fetcher
+// and Set. Fetcher durations are observed separately from the operation duration
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache implements neither cache.FetchingCacher nor cache.TTLCacher
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	defer c.observe(opSetWithTTL, time.Now())
+
+	err := c.setWithTTL(ctx, key, value, ttl)
+	c.countError(opSetWithTTL, err)
+
+	return err
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	defer c.observe(opSetMultiWithTTL, time.Now())
+
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		err := fmt.Errorf("cache %s does not support TTL: %w", c.name, errors.ErrUnsupported)
+		c.countError(opSetMultiWithTTL, err)
+		return err
+	}
+
+	err := ttlCacher.SetMultiWithTTL(ctx, kvs, ttl)
+	c.countError(opSetMultiWithTTL, err)
+
+	return err
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	defer c.observe(opGetOrFetch, time.Now())
+
+	fetched := false
+	timed := func(ctx context.Context) (T, error) {
+		fetched = true
+		defer func(start time.Time) {
+			c.fetches.Observe(time.Since(start).Seconds())
+		}(time.Now())
+
+		return fetcher(ctx)
+	}
+
+	var (
+		value T
+		err   error
+	)
+	switch fetching, ok := c.backend.(cache.FetchingCacher[T]); {
+	case ok && ttl != nil:
+		value, err = fetching.GetOrFetchWithTTL(ctx, key, *ttl, timed)
+	case ok:
+		value, err = fetching.GetOrFetch(ctx, key, timed)
+	default:
+		value, err = c.fetchThrough(ctx, key, timed, ttl)
+	}
+
+	switch {
+	case err != nil:
+		c.errors.WithLabelValues(opGetOrFetch).Inc()
+	case fetched:
+		c.misses.WithLabelValues(opGetOrFetch).Inc()
+	default:
+		c.hits.WithLabelValues(opGetOrFetch).Inc()
+	}
+
+	return value, err
+}
+
+// fetchThrough implements GetOrFetch for caches not implementing cache.FetchingCacher
+func (c *Cache[T]) fetchThrough(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if !cache.IsForceRefresh(ctx) {
+		value, err := c.backend.Get(ctx, key)
+		if !isMiss(err) {
+			return value, err
+		}
+	}
+
+	value, err := fetcher(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	if ttl != nil {
+		return value, c.setWithTTL(ctx, key, value, *ttl)
+	}
+
+	return value, c.backend.Set(ctx, key, value)
+}
+
+func (c *Cache[T]) setWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache %s does not support TTL: %w", c.name, errors.ErrUnsupported)
+	}
+
+	return ttlCacher.SetWithTTL(ctx, key, value, ttl)
+}
+
+// record counts the result of a single key read
+func (c *Cache[T]) record(op string, err error) {
+	switch {
+	case err == nil:
+		c.hits.WithLabelValues(op).Inc()
+	case isMiss(err):
+		c.misses.WithLabelValues(op).Inc()
+	default:
+		c.errors.WithLabelValues(op).Inc()
+	}
+}
+
+func (c *Cache[T]) countError(op string, err error) {
+	if err != nil {
+		c.errors.WithLabelValues(op).Inc()
+	}
+}
+
+func (c *Cache[T]) observe(op string, start time.Time) {
+	c.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// isMiss reports whether the read failed because the entry is absent
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
+
+// register registers the collectors, reusing the ones already registered by other caches
+func register(reg prometheus.Registerer) (*collectors, error) {
+	col := &collectors{
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_hits_total",
+			Help: "Number of cache reads that found the entry.",
+		}, []string{"cache", "operation"}),
+		misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_misses_total",
+			Help: "Number of cache reads that did not find the entry.",
+		}, []string{"cache", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_errors_total",
+			Help: "Number of failed cache operations.",
+		}, []string{"cache", "operation"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cache_operation_duration_seconds",
+			Help:    "Duration of cache operations.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"cache", "operation"}),
+		fetches: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cache_fetch_duration_seconds",
+			Help:    "Duration of GetOrFetch fetcher calls.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"cache"}),
+	}
+
+	var err error
+	if col.hits, err = registerOrExisting(reg, col.hits); err != nil {
+		return nil, err
+	}
+	if col.misses, err = registerOrExisting(reg, col.misses); err != nil {
+		return nil, err
+	}
+	if col.errors, err = registerOrExisting(reg, col.errors); err != nil {
+		return nil, err
+	}
+	if col.duration, err = registerOrExisting(reg, col.duration); err != nil {
+		return nil, err
+	}
+	if col.fetches, err = registerOrExisting(reg, col.fetches); err != nil {
+		return nil, err
+	}
+
+	return col, nil
+}
+
+func registerOrExisting[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if !errors.As(err, &alreadyRegistered) {
+		return c, fmt.Errorf("could not register cache metrics: %w", err)
+	}
+
+	existing, ok := alreadyRegistered.ExistingCollector.(C)
+	if !ok {
+		return c, fmt.Errorf("could not reuse registered cache metrics: %w", err)
+	}
+
+	return existing, nil
+}
@@ -0,0 +1,336 @@
+// Package mock provides a programmable Cacher for unit tests of cache consumers
+//
+// Expectations are registered with the Expect* methods, each programming the values returned by matching calls. Calls
+// matching no expectation fail the test, expectations not called as many times as required fail it on cleanup
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// ErrUnexpectedCall is returned by calls matching no expectation
+var ErrUnexpectedCall = errors.New("unexpected cache call")
+
+// TestingT is the subset of testing.TB used by Cache
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Cleanup(f func())
+}
+
+// Invocation describes a call recorded by Cache
+type Invocation struct {
+	Method string
+	Args   []any
+}
+
+// String returns the call in the Method(args) form
+func (i Invocation) String() string {
+	args := make([]string, 0, len(i.Args))
+	for _, arg := range i.Args {
+		args = append(args, fmt.Sprintf("%v", arg))
+	}
+
+	return fmt.Sprintf("%s(%s)", i.Method, strings.Join(args, ", "))
+}
+
+// Call is an expectation of Cache. Matches calls of its method with equal arguments once unless configured otherwise
+type Call[T any] struct {
+	method  string
+	args    []any
+	anyArgs bool
+
+	value T
+	items []cache.StorageItemMulti[T]
+	err   error
+	fetch bool
+
+	times int // 0 allows any number of calls
+	calls int
+}
+
+// Return programs the value and the error returned by Get, GetOrFetch and GetOrFetchWithTTL calls
+func (c *Call[T]) Return(value T, err error) *Call[T] {
+	c.value, c.err = value, err
+	return c
+}
+
+// ReturnItems programs the items and the error returned by GetMulti calls
+func (c *Call[T]) ReturnItems(items []cache.StorageItemMulti[T], err error) *Call[T] {
+	c.items, c.err = items, err
+	return c
+}
+
+// ReturnError programs the error returned by the call
+func (c *Call[T]) ReturnError(err error) *Call[T] {
+	c.err = err
+	return c
+}
+
+// CallFetch makes GetOrFetch and GetOrFetchWithTTL calls return the result of the fetcher, as on a cache miss
+func (c *Call[T]) CallFetch() *Call[T] {
+	c.fetch = true
+	return c
+}
+
+// WithAnyArgs makes the expectation match calls of its method regardless of the arguments
+func (c *Call[T]) WithAnyArgs() *Call[T] {
+	c.anyArgs = true
+	return c
+}
+
+// Times requires the expectation to be matched exactly n times
+func (c *Call[T]) Times(n int) *Call[T] {
+	c.times = n
+	return c
+}
+
+// AnyTimes allows the expectation to be matched any number of times, including none
+func (c *Call[T]) AnyTimes() *Call[T] {
+	c.times = 0
+	return c
+}
+
+func (c *Call[T]) matches(inv Invocation) bool {
+	if c.method != inv.Method || (c.times > 0 && c.calls >= c.times) {
+		return false
+	}
+
+	return c.anyArgs || reflect.DeepEqual(c.args, inv.Args)
+}
+
+func (c *Call[T]) String() string {
+	if c.anyArgs {
+		return c.method + "(*)"
+	}
+
+	return Invocation{Method: c.method, Args: c.args}.String()
+}
+
+// Cache is a mock implementing cache.FetchingCacher and cache.TTLCacher
+//
+// Safe for concurrent usage
+type Cache[T any] struct {
+	t TestingT
+
+	expectations []*Call[T]
+	calls        []Invocation
+
+	lock sync.Mutex
+}
+
+// New creates a Cache reporting failures to t. Expectations are asserted on t cleanup
+func New[T any](t TestingT) *Cache[T] {
+	c := &Cache[T]{t: t}
+	t.Cleanup(c.AssertExpectations)
+
+	return c
+}
+
+// ExpectGet registers an expectation of a Get call by key
+func (c *Cache[T]) ExpectGet(key string) *Call[T] {
+	return c.expect("Get", key)
+}
+
+// ExpectSet registers an expectation of a Set call by key with the value
+func (c *Cache[T]) ExpectSet(key string, value T) *Call[T] {
+	return c.expect("Set", key, value)
+}
+
+// ExpectGetMulti registers an expectation of a GetMulti call by keys
+func (c *Cache[T]) ExpectGetMulti(keys []string) *Call[T] {
+	return c.expect("GetMulti", keys)
+}
+
+// ExpectSetMulti registers an expectation of a SetMulti call with the k/v pairs
+func (c *Cache[T]) ExpectSetMulti(kvs []cache.StorageItemMulti[T]) *Call[T] {
+	return c.expect("SetMulti", kvs)
+}
+
+// ExpectDelete registers an expectation of a Delete call by key
+func (c *Cache[T]) ExpectDelete(key string) *Call[T] {
+	return c.expect("Delete", key)
+}
+
+// ExpectDeleteMulti registers an expectation of a DeleteMulti call by keys
+func (c *Cache[T]) ExpectDeleteMulti(keys []string) *Call[T] {
+	return c.expect("DeleteMulti", keys)
+}
+
+// ExpectSetWithTTL registers an expectation of a SetWithTTL call by key with the value and ttl
+func (c *Cache[T]) ExpectSetWithTTL(key string, value T, ttl time.Duration) *Call[T] {
+	return c.expect("SetWithTTL", key, value, ttl)
+}
+
+// ExpectSetMultiWithTTL registers an expectation of a SetMultiWithTTL call with the k/v pairs and ttl
+func (c *Cache[T]) ExpectSetMultiWithTTL(kvs []cache.StorageItemMulti[T], ttl time.Duration) *Call[T] {
+	return c.expect("SetMultiWithTTL", kvs, ttl)
+}
+
+// ExpectGetOrFetch registers an expectation of a GetOrFetch call by key
+func (c *Cache[T]) ExpectGetOrFetch(key string) *Call[T] {
+	return c.expect("GetOrFetch", key)
+}
+
+// ExpectGetOrFetchWithTTL registers an expectation of a GetOrFetchWithTTL call by key with ttl
+func (c *Cache[T]) ExpectGetOrFetchWithTTL(key string, ttl time.Duration) *Call[T] {
+	return c.expect("GetOrFetchWithTTL", key, ttl)
+}
+
+// Calls returns the calls recorded so far, including unexpected ones, in order
+func (c *Cache[T]) Calls() []Invocation {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]Invocation(nil), c.calls...)
+}
+
+// AssertExpectations fails the test for every expectation not matched as many times as required
+func (c *Cache[T]) AssertExpectations() {
+	c.t.Helper()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, e := range c.expectations {
+		if e.times > 0 && e.calls != e.times {
+			c.t.Errorf("mock: expected %s to be called %d time(s), called %d", e, e.times, e.calls)
+		}
+	}
+}
+
+// Get retrieves an item by key
+func (c *Cache[T]) Get(_ context.Context, key string) (T, error) {
+	call, err := c.call("Get", key)
+	if err != nil {
+		return *new(T), err
+	}
+
+	return call.value, call.err
+}
+
+// Set puts the provided value by key
+func (c *Cache[T]) Set(_ context.Context, key string, value T) error {
+	return c.callErr("Set", key, value)
+}
+
+// GetMulti returns cached values by provided keys
+func (c *Cache[T]) GetMulti(_ context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	call, err := c.call("GetMulti", keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return call.items, call.err
+}
+
+// SetMulti puts provided k/v pairs
+func (c *Cache[T]) SetMulti(_ context.Context, kvs []cache.StorageItemMulti[T]) error {
+	return c.callErr("SetMulti", kvs)
+}
+
+// Delete removes cached value by key
+func (c *Cache[T]) Delete(_ context.Context, key string) error {
+	return c.callErr("Delete", key)
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache[T]) DeleteMulti(_ context.Context, keys []string) error {
+	return c.callErr("DeleteMulti", keys)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(_ context.Context, key string, value T, ttl time.Duration) error {
+	return c.callErr("SetWithTTL", key, value, ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(_ context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	return c.callErr("SetMultiWithTTL", kvs, ttl)
+}
+
+// GetOrFetch returns the programmed value, or the fetcher result if the expectation is set up with CallFetch
+func (c *Cache[T]) GetOrFetch(ctx context.Context, key string, fetch func(ctx context.Context) (T, error)) (T, error) {
+	return c.getOrFetch(ctx, fetch, "GetOrFetch", key)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, fetch, "GetOrFetchWithTTL", key, ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	fetch func(ctx context.Context) (T, error),
+	method string,
+	args ...any,
+) (T, error) {
+	call, err := c.call(method, args...)
+	if err != nil {
+		return *new(T), err
+	}
+
+	if call.fetch {
+		return fetch(ctx)
+	}
+
+	return call.value, call.err
+}
+
+func (c *Cache[T]) expect(method string, args ...any) *Call[T] {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	call := &Call[T]{
+		method: method,
+		args:   args,
+		times:  1,
+	}
+	c.expectations = append(c.expectations, call)
+
+	return call
+}
+
+func (c *Cache[T]) callErr(method string, args ...any) error {
+	call, err := c.call(method, args...)
+	if err != nil {
+		return err
+	}
+
+	return call.err
+}
+
+// call records the invocation and returns a copy of the first expectation it matches
+func (c *Cache[T]) call(method string, args ...any) (Call[T], error) {
+	c.t.Helper()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	inv := Invocation{Method: method, Args: args}
+	c.calls = append(c.calls, inv)
+
+	for _, e := range c.expectations {
+		if e.matches(inv) {
+			e.calls++
+			return *e, nil
+		}
+	}
+
+	c.t.Errorf("mock: unexpected call %s", inv)
+
+	return Call[T]{}, fmt.Errorf("%w: %s", ErrUnexpectedCall, inv)
+}
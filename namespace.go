@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WithNamespace wraps the cache prefixing every key with the prefix, e.g. "users:", so multiple logical caches can
+// share one backend without their keys colliding
+//
+// Keys are reported without the prefix, Keys lists only the keys of the namespace. Optional capabilities are passed
+// through as KeyedCache does, returning errors.ErrUnsupported if the wrapped cache lacks them
+func WithNamespace[T any](c Cacher[T], prefix string) Cacher[T] {
+	return &namespacedCache[T]{
+		backend: c,
+		prefix:  prefix,
+		keyed: Keyed(c, func(key string) (string, error) {
+			return prefix + key, nil
+		}),
+	}
+}
+
+type namespacedCache[T any] struct {
+	backend Cacher[T]
+	prefix  string
+	keyed   *KeyedCache[string, T]
+}
+
+func (c *namespacedCache[T]) Unwrap() Cacher[T] {
+	return c.backend
+}
+
+func (c *namespacedCache[T]) Get(ctx context.Context, key string) (T, error) {
+	return c.keyed.Get(ctx, key)
+}
+
+func (c *namespacedCache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.keyed.Set(ctx, key, value)
+}
+
+func (c *namespacedCache[T]) GetMulti(ctx context.Context, keys []string) ([]StorageItemMulti[T], error) {
+	items, err := c.keyed.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]StorageItemMulti[T], 0, len(items))
+	for _, item := range items {
+		res = append(res, StorageItemMulti[T]{
+			Key:   item.Key,
+			Value: item.Value,
+		})
+	}
+
+	return res, nil
+}
+
+func (c *namespacedCache[T]) SetMulti(ctx context.Context, kvs []StorageItemMulti[T]) error {
+	return c.backend.SetMulti(ctx, c.items(kvs))
+}
+
+func (c *namespacedCache[T]) Delete(ctx context.Context, key string) error {
+	return c.keyed.Delete(ctx, key)
+}
+
+func (c *namespacedCache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.keyed.DeleteMulti(ctx, keys)
+}
+
+func (c *namespacedCache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	return c.keyed.Exists(ctx, key)
+}
+
+func (c *namespacedCache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.keyed.GetOrFetch(ctx, key, fetch)
+}
+
+func (c *namespacedCache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.keyed.GetOrFetchWithTTL(ctx, key, ttl, fetch)
+}
+
+func (c *namespacedCache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	return c.keyed.SetWithTTL(ctx, key, value, ttl)
+}
+
+func (c *namespacedCache[T]) SetMultiWithTTL(ctx context.Context, kvs []StorageItemMulti[T], ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return ttlCacher.SetMultiWithTTL(ctx, c.items(kvs), ttl)
+}
+
+func (c *namespacedCache[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, error) {
+	return c.keyed.GetWithTTL(ctx, key)
+}
+
+func (c *namespacedCache[T]) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	return c.keyed.Touch(ctx, key, ttl)
+}
+
+func (c *namespacedCache[T]) Pop(ctx context.Context, key string) (T, error) {
+	return c.keyed.Pop(ctx, key)
+}
+
+func (c *namespacedCache[T]) Update(
+	ctx context.Context,
+	key string,
+	fn func(old T, exists bool) (T, bool, error),
+) error {
+	return c.keyed.Update(ctx, key, fn)
+}
+
+func (c *namespacedCache[T]) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.keyed.Incr(ctx, key, delta)
+}
+
+func (c *namespacedCache[T]) Keys(ctx context.Context) ([]string, error) {
+	lister, ok := c.backend.(KeyLister)
+	if !ok {
+		return nil, fmt.Errorf("cache does not list keys: %w", errors.ErrUnsupported)
+	}
+
+	keys, err := lister.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if trimmed, ok := strings.CutPrefix(key, c.prefix); ok {
+			res = append(res, trimmed)
+		}
+	}
+
+	return res, nil
+}
+
+func (c *namespacedCache[T]) items(kvs []StorageItemMulti[T]) []StorageItemMulti[T] {
+	items := make([]StorageItemMulti[T], 0, len(kvs))
+	for _, kv := range kvs {
+		items = append(items, StorageItemMulti[T]{
+			Key:   c.prefix + kv.Key,
+			Value: kv.Value,
+		})
+	}
+
+	return items
+}
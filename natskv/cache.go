@@ -0,0 +1,372 @@
+// Package natskv provides a typed cache over NATS JetStream key-value buckets
+//
+// Values are converted to bytes with a codec and stored along with their expiration time, as JetStream applies TTL to
+// whole buckets only. Keys have to be valid bucket keys: letters, digits and "-/_=." characters
+package natskv
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+// headerSize is the size of the expiration timestamp stored before every value
+const headerSize = 8
+
+// Cache represents typed JetStream key-value bucket wrapped
+//
+// Expired entries are reported as missing and kept in the bucket until overwritten, deleted or removed by the bucket
+// TTL. Safe for concurrent usage
+type Cache[T any] struct {
+	kv         jetstream.KeyValue
+	codec      cache.Codec[T]
+	defaultTTL *time.Duration
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[T]
+}
+
+// NewCache creates a Cache instance over the bucket using the codec, creating the bucket with WithCreateBucket
+func NewCache[T any](
+	ctx context.Context,
+	js jetstream.JetStream,
+	bucket string,
+	codec cache.Codec[T],
+	opts ...Option,
+) (*Cache[T], error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	kv, err := js.KeyValue(ctx, bucket)
+	if errors.Is(err, jetstream.ErrBucketNotFound) && cfg.create {
+		cfg.bucket.Bucket = bucket
+		kv, err = js.CreateKeyValue(ctx, cfg.bucket)
+		if errors.Is(err, jetstream.ErrBucketExists) {
+			kv, err = js.KeyValue(ctx, bucket) // created concurrently
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not bind to bucket %s: %w", bucket, err)
+	}
+
+	return NewCacheFromBucket[T](kv, codec).withTTL(cfg.ttl), nil
+}
+
+// NewCacheFromBucket creates a Cache instance over the already bound bucket using the codec
+func NewCacheFromBucket[T any](kv jetstream.KeyValue, codec cache.Codec[T]) *Cache[T] {
+	return &Cache[T]{
+		kv:    kv,
+		codec: codec,
+	}
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
+	return c.withTTL(&ttl)
+}
+
+func (c *Cache[T]) withTTL(ttl *time.Duration) *Cache[T] {
+	c.defaultTTL = ttl
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// Bucket returns the wrapped key-value bucket
+func (c *Cache[T]) Bucket() jetstream.KeyValue {
+	return c.kv
+}
+
+// Keys returns slice of stored keys
+//
+// The order of keys are not guaranteed. Expired but not yet removed entries are included
+func (c *Cache[T]) Keys(ctx context.Context) ([]string, error) {
+	lister, err := c.kv.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = lister.Stop() }()
+
+	var keys []string
+	for key := range lister.Keys() {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return c.get(ctx, key)
+}
+
+// GetOrFetch tries to obtain cached value from the bucket. If multiple callers are accessing the same key, later
+// callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.get(ctx, key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.get(ctx, key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			err = c.set(ctx, key, result, ttl)
+		}
+
+		return result, err
+	})
+}
+
+// Set puts the provided value by cache key to the bucket
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(ctx, key, value, nil)
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+//
+// Reads the keys one by one, the bucket has no multi-key reads
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		val, err := c.get(ctx, key)
+		if isMiss(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: val,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to the bucket
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetMultiWithTTL
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	for _, kv := range kvs {
+		if err := c.set(ctx, kv.Key, kv.Value, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes cached value by key
+//
+// Leaves a delete marker, watchers of the bucket are notified
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.kv.Delete(ctx, key)
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := c.kv.Delete(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("could not delete key %s: %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(ctx, key, value, &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	for _, kv := range kvs {
+		if err := c.set(ctx, kv.Key, kv.Value, &ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache[T]) get(ctx context.Context, key string) (T, error) {
+	entry, err := c.kv.Get(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	if err != nil {
+		return *new(T), err
+	}
+
+	payload, ok := live(entry.Value(), time.Now())
+	if !ok {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return c.codec.Unmarshal(payload)
+}
+
+func (c *Cache[T]) set(ctx context.Context, key string, value T, ttl *time.Duration) error {
+	if ttl == nil {
+		ttl = c.defaultTTL
+	}
+
+	encoded, err := c.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not marshal value by key %s: %w", key, err)
+	}
+
+	var expiresAt int64
+	if ttl != nil && *ttl > 0 {
+		expiresAt = time.Now().Add(*ttl).UnixNano()
+	}
+
+	data := make([]byte, headerSize+len(encoded))
+	binary.BigEndian.PutUint64(data, uint64(expiresAt))
+	copy(data[headerSize:], encoded)
+
+	_, err = c.kv.Put(ctx, key, data)
+	return err
+}
+
+// live returns the payload of the stored entry unless it has expired
+func live(data []byte, now time.Time) ([]byte, bool) {
+	if len(data) < headerSize {
+		return nil, false
+	}
+
+	expiresAt := int64(binary.BigEndian.Uint64(data))
+	if expiresAt != 0 && expiresAt <= now.UnixNano() {
+		return nil, false
+	}
+
+	return data[headerSize:], true
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
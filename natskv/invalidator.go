@@ -0,0 +1,89 @@
+package natskv
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sinu5oid/cache"
+)
+
+// Invalidator is a cache.OriginInvalidator notifying subscribers of keys written or deleted in a bucket by any process
+//
+// Connects local caches in front of a natskv Cache, e.g. the L1 of tiered.Cache, to the bucket watch: entries written
+// to the bucket elsewhere are evicted locally. Writes of this process are watched as well, so they evict its local
+// copies once their events arrive. Events of the bucket are delivered asynchronously, Publish notifies subscribers of
+// this Invalidator only, as writes reaching the bucket notify other processes themselves. Safe for concurrent usage
+type Invalidator struct {
+	watcher jetstream.KeyWatcher
+	local   *cache.LocalInvalidator
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewInvalidator starts watching updates of the bucket and creates an Invalidator delivering them
+//
+// Updates made before the call are not delivered
+func NewInvalidator(ctx context.Context, kv jetstream.KeyValue) (*Invalidator, error) {
+	watcher, err := kv.WatchAll(ctx, jetstream.UpdatesOnly(), jetstream.MetaOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Invalidator{
+		watcher: watcher,
+		local:   cache.NewLocalInvalidator(),
+		done:    make(chan struct{}),
+	}
+
+	go i.listen()
+
+	return i, nil
+}
+
+// Publish notifies local subscribers that the entry by key is no longer valid
+func (i *Invalidator) Publish(ctx context.Context, key string) error {
+	return i.local.Publish(ctx, key)
+}
+
+// PublishFrom notifies local subscribers on behalf of origin that the entry by key is no longer valid
+func (i *Invalidator) PublishFrom(ctx context.Context, origin, key string) error {
+	return i.local.PublishFrom(ctx, origin, key)
+}
+
+// Subscribe registers handler called for every key published locally or changed in the bucket
+func (i *Invalidator) Subscribe(handler func(key string)) func() {
+	return i.local.Subscribe(handler)
+}
+
+// SubscribeFrom registers handler called for every key published locally or changed in the bucket with its origin,
+// empty for changes of the bucket
+func (i *Invalidator) SubscribeFrom(handler func(origin, key string)) func() {
+	return i.local.SubscribeFrom(handler)
+}
+
+// Close stops watching the bucket. Changes are no longer received
+func (i *Invalidator) Close() error {
+	var err error
+	i.closeOnce.Do(func() {
+		err = i.watcher.Stop()
+		<-i.done
+	})
+
+	return err
+}
+
+func (i *Invalidator) listen() {
+	defer close(i.done)
+
+	ctx := context.Background()
+	for entry := range i.watcher.Updates() {
+		if entry == nil {
+			continue // the end of initial values, none with UpdatesOnly
+		}
+
+		_ = i.local.Publish(ctx, entry.Key())
+	}
+}
@@ -0,0 +1,63 @@
+package natskv
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Option configures the Cache created by NewCache
+type Option func(*config)
+
+type config struct {
+	create bool
+	bucket jetstream.KeyValueConfig
+	ttl    *time.Duration
+}
+
+// WithTTL assigns the default TTL, see Cache.WithTTL
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = &ttl
+	}
+}
+
+// WithCreateBucket creates the bucket configured with the bucket options if it does not exist yet
+//
+// Without it NewCache fails with jetstream.ErrBucketNotFound for missing buckets. Existing buckets are not updated
+func WithCreateBucket() Option {
+	return func(c *config) {
+		c.create = true
+	}
+}
+
+// WithBucketTTL makes the created bucket remove entries ttl after they were written, whatever their own TTL is
+//
+// Bounds the lifetime of entries written without TTL and removes expired entries, which are otherwise kept until
+// overwritten or deleted
+func WithBucketTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.bucket.TTL = ttl
+	}
+}
+
+// WithMaxBytes caps the size of the created bucket, the oldest entries are removed to stay within it
+func WithMaxBytes(n int64) Option {
+	return func(c *config) {
+		c.bucket.MaxBytes = n
+	}
+}
+
+// WithReplicas assigns the number of replicas of the created bucket in a JetStream cluster
+func WithReplicas(n int) Option {
+	return func(c *config) {
+		c.bucket.Replicas = n
+	}
+}
+
+// WithMemoryStorage keeps the created bucket in memory instead of files, entries are lost when the servers restart
+func WithMemoryStorage() Option {
+	return func(c *config) {
+		c.bucket.Storage = jetstream.MemoryStorage
+	}
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// NoopCache is a cache storing nothing, so caching can be disabled by configuration without nil checks
+//
+// Get always misses, writes and deletes are discarded and GetOrFetch always calls the fetcher. Safe for concurrent
+// usage
+type NoopCache[T any] struct{}
+
+// NewNoop creates a NoopCache
+func NewNoop[T any]() *NoopCache[T] {
+	return &NoopCache[T]{}
+}
+
+// Get always fails with MissingEntryError
+func (c *NoopCache[T]) Get(_ context.Context, key string) (T, error) {
+	return *new(T), NewMissingEntryError(key)
+}
+
+// Set discards the value
+func (c *NoopCache[T]) Set(context.Context, string, T) error {
+	return nil
+}
+
+// GetMulti always returns no items
+func (c *NoopCache[T]) GetMulti(context.Context, []string) ([]StorageItemMulti[T], error) {
+	return []StorageItemMulti[T]{}, nil
+}
+
+// SetMulti discards the k/v pairs
+func (c *NoopCache[T]) SetMulti(context.Context, []StorageItemMulti[T]) error {
+	return nil
+}
+
+// Delete does nothing
+func (c *NoopCache[T]) Delete(context.Context, string) error {
+	return nil
+}
+
+// DeleteMulti does nothing
+func (c *NoopCache[T]) DeleteMulti(context.Context, []string) error {
+	return nil
+}
+
+// SetWithTTL discards the value
+func (c *NoopCache[T]) SetWithTTL(context.Context, string, T, time.Duration) error {
+	return nil
+}
+
+// SetMultiWithTTL discards the k/v pairs
+func (c *NoopCache[T]) SetMultiWithTTL(context.Context, []StorageItemMulti[T], time.Duration) error {
+	return nil
+}
+
+// GetOrFetch calls the fetcher and returns its result
+func (c *NoopCache[T]) GetOrFetch(
+	ctx context.Context,
+	_ string,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return fetch(ctx)
+}
+
+// GetOrFetchWithTTL calls the fetcher and returns its result
+func (c *NoopCache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	_ string,
+	_ time.Duration,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return fetch(ctx)
+}
+
+// GetOrFetchMulti calls the fetcher with all the keys and returns its result
+func (c *NoopCache[T]) GetOrFetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]StorageItemMulti[T], error),
+) ([]StorageItemMulti[T], error) {
+	if len(keys) == 0 {
+		return []StorageItemMulti[T]{}, nil
+	}
+
+	return fetch(ctx, keys)
+}
+
+// GetWithTTL always fails with MissingEntryError
+func (c *NoopCache[T]) GetWithTTL(_ context.Context, key string) (T, time.Duration, error) {
+	return *new(T), 0, NewMissingEntryError(key)
+}
+
+// GetMultiWithTTL always returns no items
+func (c *NoopCache[T]) GetMultiWithTTL(context.Context, []string) ([]StorageItemMultiWithTTL[T], error) {
+	return []StorageItemMultiWithTTL[T]{}, nil
+}
+
+// Touch always fails with MissingEntryError
+func (c *NoopCache[T]) Touch(_ context.Context, key string, _ time.Duration) error {
+	return NewMissingEntryError(key)
+}
+
+// SetNX discards the value, reporting it as written since no entry is ever stored
+func (c *NoopCache[T]) SetNX(context.Context, string, T, time.Duration) (bool, error) {
+	return true, nil
+}
+
+// SetXX always reports the value as not written since no entry is ever stored
+func (c *NoopCache[T]) SetXX(context.Context, string, T, time.Duration) (bool, error) {
+	return false, nil
+}
+
+// Update calls fn as for a missing entry and discards its result
+func (c *NoopCache[T]) Update(_ context.Context, _ string, fn func(old T, exists bool) (T, bool, error)) error {
+	_, _, err := fn(*new(T), false)
+	return err
+}
+
+// Incr returns delta, as every counter starts from zero
+func (c *NoopCache[T]) Incr(_ context.Context, _ string, delta int64) (int64, error) {
+	return delta, nil
+}
+
+// Pop always fails with MissingEntryError
+func (c *NoopCache[T]) Pop(_ context.Context, key string) (T, error) {
+	return *new(T), NewMissingEntryError(key)
+}
+
+// Exists always reports false
+func (c *NoopCache[T]) Exists(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+// Keys always returns no keys
+func (c *NoopCache[T]) Keys(context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+// Len always returns zero
+func (c *NoopCache[T]) Len(context.Context) (int, error) {
+	return 0, nil
+}
+
+// Range yields nothing
+func (c *NoopCache[T]) Range(context.Context) iter.Seq2[string, T] {
+	return func(func(string, T) bool) {}
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// GetOptions describes per-call read behavior
+//
+// Backends ignore options they can not support, e.g. stale reads are not possible once redis expired the key
+type GetOptions struct {
+	// StaleMaxAge allows returning entries that expired no longer than StaleMaxAge ago. Zero disables stale reads
+	StaleMaxAge time.Duration
+	// NoPromote skips recency/frequency bookkeeping of the read, so the entry's eviction order is not affected
+	NoPromote bool
+	// Timeout bounds the read duration. Zero means no additional deadline
+	Timeout time.Duration
+}
+
+// GetOption modifies GetOptions
+type GetOption func(*GetOptions)
+
+// AllowStale allows returning entries expired no longer than maxAge ago
+func AllowStale(maxAge time.Duration) GetOption {
+	return func(o *GetOptions) {
+		o.StaleMaxAge = maxAge
+	}
+}
+
+// NoPromote disables recency/frequency updates for the read
+func NoPromote() GetOption {
+	return func(o *GetOptions) {
+		o.NoPromote = true
+	}
+}
+
+// ReadTimeout bounds the read with the provided duration
+func ReadTimeout(d time.Duration) GetOption {
+	return func(o *GetOptions) {
+		o.Timeout = d
+	}
+}
+
+// NewGetOptions applies provided options to the zero GetOptions value
+func NewGetOptions(opts ...GetOption) GetOptions {
+	var o GetOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// OptionsGetter is implemented by caches supporting per-call read options
+type OptionsGetter[T any] interface {
+	GetWithOptions(ctx context.Context, key string, opts ...GetOption) (T, error)
+}
+
+// SetOptions describes per-call write behavior
+//
+// Backends fail with errors.ErrUnsupported when an option can not be applied, instead of ignoring it
+type SetOptions struct {
+	// TTL overrides the backend default TTL when set
+	TTL *time.Duration
+	// Cost is the entry weight for size-aware backends. Zero lets the backend estimate it
+	Cost int64
+	// Async acknowledges the write before it reaches the backend. Write errors are not reported
+	Async bool
+}
+
+// SetOption modifies SetOptions
+type SetOption func(*SetOptions)
+
+// TTL sets the expiration for the written entry
+func TTL(ttl time.Duration) SetOption {
+	return func(o *SetOptions) {
+		o.TTL = &ttl
+	}
+}
+
+// Cost sets the weight of the written entry
+func Cost(cost int64) SetOption {
+	return func(o *SetOptions) {
+		o.Cost = cost
+	}
+}
+
+// Async makes the write asynchronous
+func Async() SetOption {
+	return func(o *SetOptions) {
+		o.Async = true
+	}
+}
+
+// NewSetOptions applies provided options to the zero SetOptions value
+func NewSetOptions(opts ...SetOption) SetOptions {
+	var o SetOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// OptionsSetter is implemented by caches supporting per-call write options
+type OptionsSetter[T any] interface {
+	SetWithOptions(ctx context.Context, key string, value T, opts ...SetOption) error
+}
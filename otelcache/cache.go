@@ -0,0 +1,350 @@
+// Package otelcache provides a cache wrapper tracing operations with OpenTelemetry
+package otelcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sinu5oid/cache"
+)
+
+// instrumentationName identifies the tracer of the package
+const instrumentationName = "github.com/sinu5oid/cache/otelcache"
+
+// Span attribute keys
+const (
+	attrName      = attribute.Key("cache.name")
+	attrKey       = attribute.Key("cache.key")
+	attrKeyCount  = attribute.Key("cache.key_count")
+	attrHit       = attribute.Key("cache.hit")
+	attrHitCount  = attribute.Key("cache.hit_count")
+	attrMissCount = attribute.Key("cache.miss_count")
+	attrFetched   = attribute.Key("cache.fetched")
+	attrTTL       = attribute.Key("cache.ttl")
+)
+
+// Cache wraps a cache.Cacher starting a span per operation as a child of the span in the caller context
+//
+// The span context is passed to the wrapped cache, so backends instrumented on their own, e.g. redis clients, attach
+// their spans to it. Reads record the cache.hit attribute, multi-key reads record cache.hit_count and
+// cache.miss_count. Missing entries are not errors. Safe for concurrent usage if the wrapped cache is
+type Cache[T any] struct {
+	backend  cache.Cacher[T]
+	tracer   trace.Tracer
+	redactor cache.KeyRedactor
+	attrs    []attribute.KeyValue
+}
+
+// Option configures the tracing Cache
+type Option func(*options)
+
+type options struct {
+	provider trace.TracerProvider
+	redactor cache.KeyRedactor
+	name     string
+}
+
+// WithTracerProvider sets the provider the tracer is obtained from, the global one by default
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.provider = provider
+	}
+}
+
+// WithKeyRedactor transforms keys before they are recorded in span attributes and errors, e.g. cache.RedactHash.
+// Keys are recorded as is by default
+func WithKeyRedactor(redactor cache.KeyRedactor) Option {
+	return func(o *options) {
+		o.redactor = redactor
+	}
+}
+
+// WithName records the cache name on every span, so caches sharing a service can be told apart
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// Wrap creates a tracing Cache
+func Wrap[T any](c cache.Cacher[T], opts ...Option) *Cache[T] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.provider == nil {
+		o.provider = otel.GetTracerProvider()
+	}
+
+	var attrs []attribute.KeyValue
+	if o.name != "" {
+		attrs = append(attrs, attrName.String(o.name))
+	}
+
+	return &Cache[T]{
+		backend:  c,
+		tracer:   o.provider.Tracer(instrumentationName),
+		redactor: o.redactor,
+		attrs:    attrs,
+	}
+}
+
+// Unwrap returns the wrapped cache
+func (c *Cache[T]) Unwrap() cache.Cacher[T] {
+	return c.backend
+}
+
+// Get retrieves an item by key within the cache.Get span
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	ctx, span := c.start(ctx, "cache.Get", c.key(key))
+	defer span.End()
+
+	value, err := c.backend.Get(ctx, key)
+	if err == nil || isMiss(err) {
+		span.SetAttributes(attrHit.Bool(err == nil))
+	}
+	c.fail(span, err, key)
+
+	return value, err
+}
+
+// Set puts the provided value by key within the cache.Set span
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	ctx, span := c.start(ctx, "cache.Set", c.key(key))
+	defer span.End()
+
+	err := c.backend.Set(ctx, key, value)
+	c.fail(span, err, key)
+
+	return err
+}
+
+// GetMulti returns cached values by provided keys within the cache.GetMulti span
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	ctx, span := c.start(ctx, "cache.GetMulti", attrKeyCount.Int(len(keys)))
+	defer span.End()
+
+	items, err := c.backend.GetMulti(ctx, keys)
+	if err == nil {
+		span.SetAttributes(attrHitCount.Int(len(items)), attrMissCount.Int(len(keys)-len(items)))
+	}
+	c.fail(span, err, keys...)
+
+	return items, err
+}
+
+// SetMulti puts provided k/v pairs within the cache.SetMulti span
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	ctx, span := c.start(ctx, "cache.SetMulti", attrKeyCount.Int(len(kvs)))
+	defer span.End()
+
+	err := c.backend.SetMulti(ctx, kvs)
+	c.fail(span, err)
+
+	return err
+}
+
+// Exists reports whether a live entry by key is stored within the cache.Exists span, see cache.Exists
+func (c *Cache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	ctx, span := c.start(ctx, "cache.Exists", c.key(key))
+	defer span.End()
+
+	ok, err := cache.Exists(ctx, c.backend, key)
+	c.fail(span, err, key)
+
+	return ok, err
+}
+
+// Delete removes cached value by key within the cache.Delete span
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	ctx, span := c.start(ctx, "cache.Delete", c.key(key))
+	defer span.End()
+
+	err := c.backend.Delete(ctx, key)
+	c.fail(span, err, key)
+
+	return err
+}
+
+// DeleteMulti removes cached values by keys within the cache.DeleteMulti span
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	ctx, span := c.start(ctx, "cache.DeleteMulti", attrKeyCount.Int(len(keys)))
+	defer span.End()
+
+	err := c.backend.DeleteMulti(ctx, keys)
+	c.fail(span, err, keys...)
+
+	return err
+}
+
+// GetOrFetch obtains the value by key within the cache.GetOrFetch span, the fetcher runs within the child
+// cache.Fetch span
+//
+// Uses the wrapped cache GetOrFetch if it implements cache.FetchingCacher, otherwise calls Get, then the fetcher
+// and Set
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache implements neither cache.FetchingCacher nor cache.TTLCacher
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration within the cache.SetWithTTL span
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ctx, span := c.start(ctx, "cache.SetWithTTL", c.key(key), attrTTL.String(ttl.String()))
+	defer span.End()
+
+	err := c.setWithTTL(ctx, key, value, ttl)
+	c.fail(span, err, key)
+
+	return err
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration within the cache.SetMultiWithTTL span
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	ctx, span := c.start(ctx, "cache.SetMultiWithTTL", attrKeyCount.Int(len(kvs)), attrTTL.String(ttl.String()))
+	defer span.End()
+
+	var err error
+	if ttlCacher, ok := c.backend.(cache.TTLCacher[T]); ok {
+		err = ttlCacher.SetMultiWithTTL(ctx, kvs, ttl)
+	} else {
+		err = fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+	c.fail(span, err)
+
+	return err
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	ctx, span := c.start(ctx, "cache.GetOrFetch", c.key(key))
+	defer span.End()
+
+	fetched := false
+	traced := func(ctx context.Context) (T, error) {
+		fetched = true
+
+		ctx, span := c.tracer.Start(ctx, "cache.Fetch", trace.WithAttributes(c.attrs...))
+		defer span.End()
+
+		value, err := fetcher(ctx)
+		c.fail(span, err, key)
+
+		return value, err
+	}
+
+	var (
+		value T
+		err   error
+	)
+	switch fetching, ok := c.backend.(cache.FetchingCacher[T]); {
+	case ok && ttl != nil:
+		value, err = fetching.GetOrFetchWithTTL(ctx, key, *ttl, traced)
+	case ok:
+		value, err = fetching.GetOrFetch(ctx, key, traced)
+	default:
+		value, err = c.fetchThrough(ctx, key, traced, ttl)
+	}
+
+	span.SetAttributes(attrFetched.Bool(fetched))
+	if err == nil {
+		span.SetAttributes(attrHit.Bool(!fetched))
+	}
+	c.fail(span, err, key)
+
+	return value, err
+}
+
+// fetchThrough implements GetOrFetch for caches not implementing cache.FetchingCacher
+func (c *Cache[T]) fetchThrough(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if !cache.IsForceRefresh(ctx) {
+		value, err := c.backend.Get(ctx, key)
+		if !isMiss(err) {
+			return value, err
+		}
+	}
+
+	value, err := fetcher(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	if ttl != nil {
+		return value, c.setWithTTL(ctx, key, value, *ttl)
+	}
+
+	return value, c.backend.Set(ctx, key, value)
+}
+
+func (c *Cache[T]) setWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return ttlCacher.SetWithTTL(ctx, key, value, ttl)
+}
+
+func (c *Cache[T]) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(c.attrs...),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func (c *Cache[T]) key(key string) attribute.KeyValue {
+	return attrKey.String(c.redactor.Redact(key))
+}
+
+// fail marks the span failed unless err is nil or reports a missing entry. Keys in the error message are redacted
+func (c *Cache[T]) fail(span trace.Span, err error, keys ...string) {
+	if err == nil || isMiss(err) {
+		return
+	}
+
+	err = c.redactor.RedactError(err, keys...)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// isMiss reports whether the read failed because the entry is absent
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// KeyRedactor transforms cache keys before they are exposed to logs, traces or debug output
+//
+// Used by otelcache for span attributes and errors and by cachectl for printed keys and errors, so keys embedding
+// emails or tokens do not leak. Dump and the export of cachectl keep keys as is, as Restore needs them. A nil
+// KeyRedactor keeps keys as is
+type KeyRedactor func(key string) string
+
+// Redact applies the redactor to the key
+func (r KeyRedactor) Redact(key string) string {
+	if r == nil {
+		return key
+	}
+
+	return r(key)
+}
+
+// RedactError returns err with keys redacted in its message
+//
+// Redacts the keys of the cache errors err wraps, e.g. MissingEntryError, and the keys passed by the caller, e.g. the
+// keys of the failed operation wrapped into errors of backends. The returned error unwraps to err, so errors.Is and
+// errors.As still match it. Returns err as is if the redactor is nil or there are no keys to redact
+func (r KeyRedactor) RedactError(err error, keys ...string) error {
+	if r == nil || err == nil {
+		return err
+	}
+
+	keys = slices.DeleteFunc(append(errorKeys(err), keys...), func(key string) bool { return key == "" })
+	if len(keys) == 0 {
+		return err
+	}
+
+	// longer keys first, so keys prefixed by other keys are replaced whole
+	slices.SortFunc(keys, func(a, b string) int { return len(b) - len(a) })
+
+	replacements := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		replacements = append(replacements, "key "+key, "key "+r(key))
+	}
+
+	return redactedError{msg: strings.NewReplacer(replacements...).Replace(err.Error()), err: err}
+}
+
+// RedactHash replaces the key with a short stable hash, so equal keys can still be correlated
+func RedactHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// RedactAll replaces every key with a fixed placeholder
+func RedactAll(string) string {
+	return "[redacted]"
+}
+
+// keyedError is implemented by errors embedding a cache key in their message
+type keyedError interface {
+	error
+	Key() string
+}
+
+// errorKeys returns the keys of the keyed errors in the tree of err
+func errorKeys(err error) []string {
+	var keys []string
+	if keyed, ok := err.(keyedError); ok && keyed.Key() != "" {
+		keys = append(keys, keyed.Key())
+	}
+
+	switch wrapper := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := wrapper.Unwrap(); inner != nil {
+			keys = append(keys, errorKeys(inner)...)
+		}
+	case interface{ Unwrap() []error }:
+		for _, inner := range wrapper.Unwrap() {
+			keys = append(keys, errorKeys(inner)...)
+		}
+	}
+
+	return keys
+}
+
+// redactedError replaces the message of err with the redacted one
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e redactedError) Error() string {
+	return e.msg
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
@@ -3,19 +3,106 @@ package redis
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+	"github.com/sinu5oid/cache/internal/stats"
 
 	rc "github.com/go-redis/cache/v9"
+	"github.com/redis/go-redis/v9"
 )
 
+// defaultTTL is the TTL go-redis/cache applies to items written without one
+const defaultTTL = time.Hour
+
+// setIfChanged writes the value unless the stored one is equal, then only the TTL is renewed. Returns 1 if written
+var setIfChanged = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	end
+	return 0
+end
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// incrBy increments the counter, assigning the TTL to counters it creates. Returns the result
+var incrBy = redis.NewScript(`
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if created and ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return n
+`)
+
+const (
+	// defaultLockTTL is the time a lock is held for unless released or configured with WithLockTTL
+	defaultLockTTL = 30 * time.Second
+	// lockRetryInterval is the delay between attempts to take a busy lock
+	lockRetryInterval = 50 * time.Millisecond
+	// lockNamespace prefixes the keys of locks taken with Lock under the base key. Keys under it are not listed,
+	// counted or removed by SCAN based operations
+	lockNamespace = "__lock:"
+)
+
+// unlock removes the lock only if it is still held by the provided token
+var unlock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// globEscaper escapes the characters special to SCAN patterns
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// ErrNoClient is returned by operations requiring direct redis commands when no client was assigned with WithClient
+var ErrNoClient = errors.New("redis client is not configured")
+
 // Cache represents typed go-redis/cache wrapped
 type Cache[T any] struct {
-	storage *rc.Cache
-	baseKey string
+	storage   *rc.Cache
+	client    redis.UniversalClient
+	local     rc.LocalCache
+	baseKey   string
+	codec     cache.Codec[T]
+	opTimeout time.Duration
+	sliding   time.Duration
+	ttlPolicy cache.TTLPolicy[T]
+	unchanged bool
+	lockTTL   time.Duration
+	fetchLock bool
+	batchSize int
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	loader           cache.Loader[T]
+	flights          singleflight.Group[T]
+	degraded         atomic.Bool
+	stats            stats.Counters
+
+	health          *cache.HealthMonitor
+	healthListeners []func(healthy bool)
 }
 
 // NewCache creates a Cache instance with internal storages initialized and no TTL
@@ -26,39 +113,539 @@ func NewCache[T any](cache *rc.Cache, baseKey string) (*Cache[T], error) {
 	}, nil
 }
 
+// NewCacheFromClient creates a Cache instance over a go-redis/cache instance built on the client, which is assigned
+// with WithClient as well
+//
+// Accepts *redis.Client, *redis.Ring and *redis.ClusterClient. Keys of the latter two are sharded across the nodes:
+// multi-key commands are replaced with pipelines of single-key ones and SCAN visits every node
+func NewCacheFromClient[T any](client redis.UniversalClient, baseKey string) (*Cache[T], error) {
+	if client == nil {
+		return nil, ErrNoClient
+	}
+
+	c, err := NewCache[T](rc.New(&rc.Options{Redis: client}), baseKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.WithClient(client), nil
+}
+
+// WithClient assigns the redis client used for operations go-redis/cache does not provide, e.g. GetMultiWithTTL
+//
+// Should point to the same redis as the go-redis/cache instance. *redis.Ring and *redis.ClusterClient are supported
+// as described in NewCacheFromClient
+func (c *Cache[T]) WithClient(client redis.UniversalClient) *Cache[T] {
+	c.client = client
+	c.useLocalCache()
+	return c
+}
+
+// WithLocalCache keeps up to size recently read entries in process memory for ttl, using the go-redis/cache TinyLFU
+// local cache, so repeated reads of hot keys skip redis
+//
+// Entries changed by other processes are served stale until ttl passes, use a short one or an Invalidator. Replaces the
+// go-redis/cache instance passed to NewCache with one over the client, so requires WithClient or NewCacheFromClient,
+// ignored otherwise
+func (c *Cache[T]) WithLocalCache(size int, ttl time.Duration) *Cache[T] {
+	c.local = rc.NewTinyLFU(size, ttl)
+	c.useLocalCache()
+	return c
+}
+
+// useLocalCache rebuilds the go-redis/cache instance with the local cache once both the client and the local cache
+// are assigned
+func (c *Cache[T]) useLocalCache() {
+	if c.client == nil || c.local == nil {
+		return
+	}
+
+	c.storage = rc.New(&rc.Options{
+		Redis:      c.client,
+		LocalCache: c.local,
+	})
+}
+
+// WithCodec assigns the codec used to convert values to bytes before they are passed to go-redis/cache
+//
+// By default values are encoded by go-redis/cache itself (msgpack, compressed when large), which other consumers of
+// the keys may not read. Use cache.JSONCodec, cache.GobCodec, codec.Msgpack or codec.Proto to store values in a plain
+// format, cache.RegistryCodec for interface-typed values
+func (c *Cache[T]) WithCodec(codec cache.Codec[T]) *Cache[T] {
+	c.codec = codec
+	return c
+}
+
+// WithOperationTimeout assigns the default deadline for every redis command issued on a context without a deadline
+//
+// Prevents callers using context.Background() from hanging on an unresponsive backend. GetOrFetch calls are not
+// bounded, as the deadline would have to include the fetcher execution
+func (c *Cache[T]) WithOperationTimeout(timeout time.Duration) *Cache[T] {
+	c.opTimeout = timeout
+	return c
+}
+
+// WithSlidingTTL makes entries expire after ttl without reads instead of a fixed time after they were written
+//
+// Every read renews the TTL with GETEX, writes without explicit TTL use it as well. Requires WithClient, ignored
+// otherwise
+func (c *Cache[T]) WithSlidingTTL(ttl time.Duration) *Cache[T] {
+	c.sliding = ttl
+	return c
+}
+
+// WithLoader assigns the loader making Get and GetMulti read through: missing values are loaded as GetOrFetch and
+// GetOrFetchMulti do and stored with the default TTL
+func (c *Cache[T]) WithLoader(loader cache.Loader[T]) *Cache[T] {
+	c.loader = loader
+	return c
+}
+
+// WithTTLPolicy assigns the policy computing TTL of entries written without explicit TTL from their values
+//
+// Entries get the go-redis/cache default TTL when the policy returns a non-positive duration. Ignored with sliding TTL
+func (c *Cache[T]) WithTTLPolicy(policy cache.TTLPolicy[T]) *Cache[T] {
+	c.ttlPolicy = policy
+	return c
+}
+
+// WithSkipUnchanged makes writes compare the serialized value with the stored one on the redis side and skip
+// rewriting it when they are equal, only renewing the TTL
+//
+// Reduces write amplification, e.g. replication traffic, for idempotent refresh jobs. Multi-key writes are issued
+// key by key then. Requires WithClient, ignored otherwise
+func (c *Cache[T]) WithSkipUnchanged() *Cache[T] {
+	c.unchanged = true
+	return c
+}
+
+// WithBatchSize limits the number of pairs SetMulti and SetMultiWithTTL write in a single round trip, splitting
+// larger writes into batches. Zero means no limit
+//
+// Keeps large writes from blocking redis for long. Requires WithClient, ignored otherwise
+func (c *Cache[T]) WithBatchSize(size int) *Cache[T] {
+	c.batchSize = size
+	return c
+}
+
+// WithLockTTL assigns the time after which locks taken with Lock are released automatically, 30 seconds by default
+//
+// Protects from locks left by crashed holders, should exceed the longest expected critical section
+func (c *Cache[T]) WithLockTTL(ttl time.Duration) *Cache[T] {
+	c.lockTTL = ttl
+	return c
+}
+
+// WithFetchLock makes GetOrFetch take the Lock of the key before calling the fetcher on a miss, so a single process
+// using the same redis fetches the value while others wait for it and read the stored result
+//
+// go-redis/cache deduplicates fetches within the process only. Locks are released automatically after the lock TTL,
+// which should exceed the fetch duration. Requires WithClient, ignored otherwise
+func (c *Cache[T]) WithFetchLock() *Cache[T] {
+	c.fetchLock = true
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// WithHealthCheck starts monitoring redis health: the server is pinged every interval, and it is considered
+// unhealthy when the ping fails or the share of commands failed with cache.ErrBackendUnavailable exceeds maxErrorRate
+//
+// Pinging requires WithClient, otherwise only the error rate is checked. Call Close to stop monitoring
+func (c *Cache[T]) WithHealthCheck(interval time.Duration, maxErrorRate float64) *Cache[T] {
+	var probe func(ctx context.Context) error
+	if c.client != nil {
+		probe = func(ctx context.Context) error {
+			return c.client.Ping(ctx).Err()
+		}
+	}
+
+	if c.health != nil {
+		c.health.Close()
+	}
+
+	c.health = cache.NewHealthMonitor(probe, interval, maxErrorRate)
+	for _, fn := range c.healthListeners {
+		c.health.OnHealthChange(fn)
+	}
+
+	return c
+}
+
+// OnHealthChange registers a function called every time redis health changes, requires WithHealthCheck
+func (c *Cache[T]) OnHealthChange(fn func(healthy bool)) *Cache[T] {
+	c.healthListeners = append(c.healthListeners, fn)
+	if c.health != nil {
+		c.health.OnHealthChange(fn)
+	}
+
+	return c
+}
+
+// Close stops background health monitoring, if any
+func (c *Cache[T]) Close() {
+	if c.health != nil {
+		c.health.Close()
+	}
+}
+
+// Stats returns operation counters of the cache
+//
+// Counters are local to the process, evictions and expirations are done by redis and not counted. With WithClient
+// the entries under the base key are counted by SCAN, taking O(N) of the keyspace size, otherwise Entries is -1
+func (c *Cache[T]) Stats(ctx context.Context) (cache.Stats, error) {
+	if c.client == nil {
+		return c.stats.Snapshot(-1), nil
+	}
+
+	entries, err := c.count(ctx)
+	if err != nil {
+		return cache.Stats{}, err
+	}
+
+	return c.stats.Snapshot(int64(entries)), nil
+}
+
+// Len returns the number of entries under the base key, counted by SCAN
+//
+// Takes O(N) of the keyspace size. Keys written or removed during the scan may be counted or not, so the result is
+// approximate under concurrent writes. Requires WithClient
+func (c *Cache[T]) Len(ctx context.Context) (int, error) {
+	if c.client == nil {
+		return 0, ErrNoClient
+	}
+
+	return c.count(ctx)
+}
+
+// Keys returns slice of stored keys under the base key, listed by SCAN
+//
+// The order of keys are not guaranteed. SCAN does not block redis, but keys written or removed during the scan may
+// be listed or not. Keys of locks held by Lock are not listed. Requires WithClient
+func (c *Cache[T]) Keys(ctx context.Context) ([]string, error) {
+	if c.client == nil {
+		return nil, ErrNoClient
+	}
+
+	prefix := c.formatKey("")
+
+	var keys []string
+	err := c.scan(ctx, c.pattern(), func(page []string) error {
+		for _, key := range page {
+			keys = append(keys, strings.TrimPrefix(key, prefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redis keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// count counts the keys under the base key by SCAN
+func (c *Cache[T]) count(ctx context.Context) (int, error) {
+	var entries int
+	err := c.scan(ctx, c.pattern(), func(page []string) error {
+		entries += len(page)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count redis keys: %w", err)
+	}
+
+	return entries, nil
+}
+
+// scan lists the keys matching the pattern by SCAN on every node, calling fn for every page of keys. Calls of fn are
+// serialized, though nodes are scanned concurrently. Keys of locks are skipped
+func (c *Cache[T]) scan(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	locks := c.formatKey(lockNamespace)
+
+	var lock sync.Mutex
+	err := c.forEachNode(ctx, func(ctx context.Context, node redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, pattern, 0).Result()
+			if err != nil {
+				return err
+			}
+
+			keys = slices.DeleteFunc(keys, func(key string) bool {
+				return strings.HasPrefix(key, locks)
+			})
+
+			if len(keys) > 0 {
+				lock.Lock()
+				err = fn(keys)
+				lock.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	})
+
+	return c.track(err)
+}
+
+// forEachNode calls fn concurrently for every node owning a share of the keyspace: the masters of a cluster, the
+// shards of a ring, or the client itself
+func (c *Cache[T]) forEachNode(ctx context.Context, fn func(ctx context.Context, node redis.Cmdable) error) error {
+	call := func(ctx context.Context, node *redis.Client) error {
+		return fn(ctx, node)
+	}
+
+	switch client := c.client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, call)
+	case *redis.Ring:
+		return client.ForEachShard(ctx, call)
+	default:
+		return fn(ctx, c.client)
+	}
+}
+
+// sharded reports whether keys are spread across nodes, so multi-key commands may not be used
+func (c *Cache[T]) sharded() bool {
+	switch c.client.(type) {
+	case *redis.ClusterClient, *redis.Ring:
+		return true
+	default:
+		return false
+	}
+}
+
+// Degraded reports whether the latest redis command failed with cache.ErrBackendUnavailable
+func (c *Cache[T]) Degraded() bool {
+	return c.degraded.Load()
+}
+
+// Lock acquires the lock of the key shared by all the processes using the same redis, waiting until other holders
+// release it or the context is done
+//
+// Taken with SET NX under a separate namespace of the base key, released by the returned function only if still held
+// by the caller. Lock keys are not listed by Keys and not removed by DeleteByPrefix. The lock does not block cache
+// operations. Requires WithClient
+func (c *Cache[T]) Lock(ctx context.Context, key string) (func(), error) {
+	if c.client == nil {
+		return nil, ErrNoClient
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("could not generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	ttl := c.lockTTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+
+	lockKey := c.formatKey(lockNamespace + key)
+	for {
+		acquired, err := c.client.SetNX(ctx, lockKey, token, ttl).Result()
+		if err = c.track(err); err != nil {
+			return nil, fmt.Errorf("could not acquire lock for key %s: %w", key, err)
+		}
+
+		if acquired {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	return func() {
+		ctx, cancel := cache.ContextWithDefaultTimeout(context.Background(), c.opTimeout)
+		defer cancel()
+
+		_ = unlock.Run(ctx, c.client, []string{lockKey}, token).Err()
+	}, nil
+}
+
 // Get retrieves an item from cache by key. Does not return expired by TTL items
 func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
-	return c.get(ctx, key, nil)
+	if c.loader != nil {
+		return c.getOrFetch(ctx, key, cache.LoadFetcher(c.loader, key), nil)
+	}
+
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	value, err := c.get(ctx, key, nil, nil)
+	c.stats.Read(err)
+
+	return value, err
+}
+
+// Exists reports whether an entry by key is stored, without transferring and decoding its value
+//
+// Uses the EXISTS command with WithClient, skipping the go-redis/cache local cache. Otherwise reads the raw value
+// through go-redis/cache, reporting read errors as missing entries
+func (c *Cache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	if cache.IsBypassed(ctx) {
+		return false, nil
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	if c.client == nil {
+		return c.storage.Exists(ctx, c.formatKey(key)), nil
+	}
+
+	n, err := c.client.Exists(ctx, c.formatKey(key)).Result()
+	if err = c.track(err); err != nil {
+		return false, fmt.Errorf("failed to check value existence in redis: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// Pop retrieves an item from cache by key and removes it atomically with GETDEL, so only one of concurrent callers
+// receives it
+//
+// The entry is removed from the go-redis/cache local cache as well. Requires WithClient
+func (c *Cache[T]) Pop(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	if c.client == nil {
+		return *new(T), ErrNoClient
+	}
+
+	value, err := c.pop(ctx, key)
+	c.stats.Read(err)
+
+	return value, err
+}
+
+// GetWithOptions retrieves an item from cache by key using provided per-call options
+//
+// Supports cache.ReadTimeout only: redis removes expired keys by itself and has no recency bookkeeping to skip
+func (c *Cache[T]) GetWithOptions(ctx context.Context, key string, opts ...cache.GetOption) (T, error) {
+	o := cache.NewGetOptions(opts...)
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	return c.Get(ctx, key)
 }
 
 // GetOrFetch tries to obtain cached value from internal storage. If multiple callers are accessing the same key,
 // later callers join the wait queue until the result or error are received
 //
-// If the value was not found - calls provided fetcher function, saves received value to the cache.
-func (c *Cache[T]) GetOrFetch(ctx context.Context, key string, f func() (T, error)) (T, error) {
-	return c.get(ctx, key, f)
+// If the value was not found - calls provided fetcher function with the caller context, saves received value to the
+// cache.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(ctx context.Context, key string, f func(ctx context.Context) (T, error)) (T, error) {
+	return c.getOrFetch(ctx, key, f, nil)
 }
 
-// Set puts the provided value by cache key
-//
-// By default uses no TTL
-func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
-	return c.set(ctx, key, value, nil)
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	f func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, f, &ttl)
 }
 
-// GetMulti returns cached values by provided keys.
-// Result slice may have fewer items than keys, it means that items by that key were not found
-func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
-	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+// GetOrFetchMulti returns cached values by provided keys, loading all the missing ones with a single fetch call and
+// storing the results. Result slice follows the order of keys and may have fewer items than keys, it means that
+// fetch did not return items by that key
+//
+// Concurrent loads of the same key are deduplicated: keys being loaded by other callers are waited for.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return fetch(ctx, keys)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	found := make(map[string]T, len(keys))
+	missing := make([]string, 0, len(keys))
 	for _, key := range keys {
-		val, err := c.get(ctx, key, nil)
+		if !force {
+			value, err := c.lookup(ctx, key)
+			if err == nil {
+				found[key] = value
+				continue
+			}
+
+			if !isMiss(err) {
+				return nil, err
+			}
+		}
+
+		missing = append(missing, key)
+	}
+
+	c.stats.ReadMulti(len(keys), len(keys)-len(missing))
+
+	if len(missing) > 0 {
+		loaded, err := c.flights.DoMulti(ctx, missing, func(ctx context.Context, owned []string) (map[string]T, error) {
+			return c.fetchMulti(ctx, owned, fetch, force)
+		})
 		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(found, loaded)
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, ok := found[key]
+		if !ok {
 			continue
 		}
 
 		item := cache.StorageItemMulti[T]{
 			Key:   key,
-			Value: val,
+			Value: value,
 		}
 		res = append(res, item)
 	}
@@ -66,81 +653,1222 @@ func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.Storage
 	return res, nil
 }
 
-// SetMulti puts provided k/v pairs to cache
-func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
-	errs := make([]error, 0, len(kvs))
-	for _, kv := range kvs {
-		errs = append(errs, c.set(ctx, kv.Key, kv.Value, nil))
+// Set puts the provided value by cache key
+//
+// By default uses no TTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
 	}
 
-	return errors.Join(errs...)
+	return c.set(ctx, key, value, nil)
 }
 
-// Delete removes cached value by key
-func (c *Cache[T]) Delete(ctx context.Context, key string) error {
-	return c.delete(ctx, key)
-}
+// SetWithOptions puts the provided value by cache key using provided per-call options
+//
+// Supports cache.TTL and cache.Async. Asynchronous writes outlive the caller's context cancellation. Fails with
+// errors.ErrUnsupported if cache.Cost is provided, as redis does not weigh entries
+func (c *Cache[T]) SetWithOptions(ctx context.Context, key string, value T, opts ...cache.SetOption) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
 
-// SetWithTTL puts provided value by cache key using provided ttl duration
-func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
-	return c.set(ctx, key, value, &ttl)
-}
+	o := cache.NewSetOptions(opts...)
+	if o.Cost != 0 {
+		return fmt.Errorf("could not set cost of key %s: %w", key, errors.ErrUnsupported)
+	}
+	if o.Async {
+		go func() {
+			_ = c.set(context.WithoutCancel(ctx), key, value, o.TTL)
+		}()
 
-// SetMultiWithTTL puts provided k/v pairs to cache using provided ttl duration
-func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
-	errs := make([]error, 0, len(kvs))
-	for _, kv := range kvs {
-		errs = append(errs, c.set(ctx, kv.Key, kv.Value, &ttl))
+		return nil
 	}
 
-	return errors.Join(errs...)
+	return c.set(ctx, key, value, o.TTL)
 }
 
-func (c *Cache[T]) get(ctx context.Context, key string, do func() (T, error)) (T, error) {
-	out := new(T)
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+//
+// With WithClient reads all the values in a single round trip, skipping the go-redis/cache local cache. Values failing
+// to decode are read again one by one. Without a client reads the values one by one
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if c.loader != nil {
+		return c.GetOrFetchMulti(ctx, keys, cache.LoadMultiFetcher(c.loader))
+	}
 
-	item := rc.Item{
-		Ctx:   ctx,
-		Key:   c.formatKey(key),
-		Value: out,
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
 	}
 
-	if do != nil {
-		item.Do = func(_ *rc.Item) (interface{}, error) {
-			return do()
+	if c.client == nil || len(keys) == 0 {
+		res := make([]cache.StorageItemMulti[T], 0, len(keys))
+		for _, key := range keys {
+			val, err := c.get(ctx, key, nil, nil)
+			if err != nil {
+				continue
+			}
+
+			item := cache.StorageItemMulti[T]{
+				Key:   key,
+				Value: val,
+			}
+			res = append(res, item)
 		}
+		c.stats.ReadMulti(len(keys), len(res))
+
+		return res, nil
 	}
 
-	err := c.storage.Once(&item)
+	raws, err := c.mget(ctx, keys)
 	if err != nil {
-		if errors.Is(err, rc.ErrCacheMiss) {
-			return *out, cache.NewMissingEntryError(key)
+		return nil, err
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for i, key := range keys {
+		if raws[i] == nil {
+			continue
 		}
 
-		return *new(T), fmt.Errorf("failed to get value from redis cache: %w", err)
+		val, err := c.decode(key, raws[i])
+		if err != nil {
+			// read it again through go-redis/cache, which may serve it from the local cache
+			if val, err = c.get(ctx, key, nil, nil); err != nil {
+				continue
+			}
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: val,
+		}
+		res = append(res, item)
 	}
+	c.stats.ReadMulti(len(keys), len(res))
 
-	return *out, nil
+	return res, nil
 }
 
-func (c *Cache[T]) set(ctx context.Context, key string, value T, ttl *time.Duration) error {
-	item := &rc.Item{
+// mget reads raw values by keys in a single round trip, nil for missing ones
+//
+// Issues MGET, or pipelined GET (GETEX with sliding TTL) with sliding TTL or sharded clients
+func (c *Cache[T]) mget(ctx context.Context, keys []string) ([][]byte, error) {
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	formatted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, c.formatKey(key))
+	}
+
+	raws := make([][]byte, len(keys))
+	if c.slides() || c.sharded() {
+		pipe := c.client.Pipeline()
+		gets := make([]*redis.StringCmd, 0, len(keys))
+		for _, key := range formatted {
+			if c.slides() {
+				gets = append(gets, pipe.GetEx(ctx, key, c.sliding))
+			} else {
+				gets = append(gets, pipe.Get(ctx, key))
+			}
+		}
+
+		_, err := pipe.Exec(ctx)
+		if errors.Is(err, redis.Nil) {
+			err = nil
+		}
+
+		if err = c.track(err); err != nil {
+			return nil, fmt.Errorf("failed to get values from redis: %w", err)
+		}
+
+		for i, get := range gets {
+			if raw, err := get.Bytes(); err == nil {
+				raws[i] = raw
+			}
+		}
+
+		return raws, nil
+	}
+
+	values, err := c.client.MGet(ctx, formatted...).Result()
+	if err = c.track(err); err != nil {
+		return nil, fmt.Errorf("failed to get values from redis: %w", err)
+	}
+
+	for i, value := range values {
+		if raw, ok := value.(string); ok {
+			raws[i] = []byte(raw)
+		}
+	}
+
+	return raws, nil
+}
+
+// GetWithTTL retrieves an item from cache by key along with its remaining TTL, cache.NoExpiration if it never expires
+//
+// Issues pipelined GET (GETEX with sliding TTL) and PTTL commands, requires WithClient
+func (c *Cache[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), 0, cache.NewMissingEntryError(key)
+	}
+
+	if c.client == nil {
+		return *new(T), 0, ErrNoClient
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	var get *redis.StringCmd
+	pipe := c.client.Pipeline()
+	if c.slides() {
+		get = pipe.GetEx(ctx, c.formatKey(key), c.sliding)
+	} else {
+		get = pipe.Get(ctx, c.formatKey(key))
+	}
+	ttl := pipe.PTTL(ctx, c.formatKey(key))
+
+	value, err := c.execGetWithTTL(ctx, pipe, key, get, ttl)
+	c.stats.Read(err)
+	if err != nil {
+		return *new(T), 0, err
+	}
+
+	return value, ttl.Val(), nil
+}
+
+// GetMultiWithTTL returns cached values by provided keys along with their remaining TTL.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+//
+// Issues pipelined GET (GETEX with sliding TTL) and PTTL commands, requires WithClient
+func (c *Cache[T]) GetMultiWithTTL(ctx context.Context, keys []string) ([]cache.StorageItemMultiWithTTL[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMultiWithTTL[T]{}, nil
+	}
+
+	if c.client == nil {
+		return nil, ErrNoClient
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	pipe := c.client.Pipeline()
+	gets := make([]*redis.StringCmd, 0, len(keys))
+	ttls := make([]*redis.DurationCmd, 0, len(keys))
+	for _, key := range keys {
+		if c.slides() {
+			gets = append(gets, pipe.GetEx(ctx, c.formatKey(key), c.sliding))
+		} else {
+			gets = append(gets, pipe.Get(ctx, c.formatKey(key)))
+		}
+		ttls = append(ttls, pipe.PTTL(ctx, c.formatKey(key)))
+	}
+
+	_, err := pipe.Exec(ctx)
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+
+	if err = c.track(err); err != nil {
+		return nil, fmt.Errorf("failed to get values from redis: %w", err)
+	}
+
+	res := make([]cache.StorageItemMultiWithTTL[T], 0, len(keys))
+	for i, key := range keys {
+		raw, err := gets[i].Bytes()
+		if err != nil {
+			continue
+		}
+
+		ttl := ttls[i].Val()
+		if ttl == -2 {
+			continue // expired between the commands
+		}
+
+		value, err := c.decode(key, raw)
+		if err != nil {
+			continue
+		}
+
+		item := cache.StorageItemMultiWithTTL[T]{
+			Key:   key,
+			Value: value,
+			TTL:   ttl,
+		}
+		res = append(res, item)
+	}
+	c.stats.ReadMulti(len(keys), len(res))
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to cache
+//
+// Pairs receive the TTL Set would use. With WithClient they are written by pipelined SET commands, otherwise every
+// pair is written separately. Returns the joined errors naming the keys failed to be written
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	if c.client != nil && !c.unchanged {
+		return c.setBatches(ctx, kvs, func(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+			return c.pipelineSet(ctx, kvs, nil)
+		})
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(ctx, kv.Key, kv.Value, nil))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Delete removes cached value by key
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	return c.delete(ctx, key)
+}
+
+// DeleteMulti removes cached values by keys
+//
+// With WithClient all the keys are removed by a single UNLINK command (pipelined ones with sharded clients), freeing
+// memory in background. Otherwise every key is deleted separately
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if c.client == nil {
+		errs := make([]error, 0, len(keys))
+		for _, key := range keys {
+			errs = append(errs, c.delete(ctx, key))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	formatted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, c.formatKey(key))
+	}
+
+	return c.unlink(ctx, formatted)
+}
+
+// DeleteByPrefix removes cached values by keys starting with the prefix, listed by SCAN
+//
+// Every page of keys is removed by a single UNLINK command. Keys written during the scan may be left, keys of locks
+// held by Lock are kept. Requires WithClient
+func (c *Cache[T]) DeleteByPrefix(ctx context.Context, prefix string) error {
+	if c.client == nil {
+		return ErrNoClient
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	pattern := globEscaper.Replace(c.formatKey(prefix)) + "*"
+
+	var unlinkErr error
+	err := c.scan(ctx, pattern, func(keys []string) error {
+		unlinkErr = c.unlink(ctx, keys)
+		return unlinkErr
+	})
+	if unlinkErr != nil {
+		return unlinkErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to list redis keys: %w", err)
+	}
+
+	return nil
+}
+
+// SetNX puts provided value by cache key using provided ttl duration with SET NX, only if no entry is stored by the
+// key. Reports whether the value was stored
+//
+// The entry is removed from the go-redis/cache local cache. Requires WithClient
+func (c *Cache[T]) SetNX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error) {
+	return c.setIf(ctx, key, value, ttl, false)
+}
+
+// SetXX puts provided value by cache key using provided ttl duration with SET XX, only if an entry is stored by the
+// key. Reports whether the value was stored
+//
+// The entry is removed from the go-redis/cache local cache. Requires WithClient
+func (c *Cache[T]) SetXX(ctx context.Context, key string, value T, ttl time.Duration) (bool, error) {
+	return c.setIf(ctx, key, value, ttl, true)
+}
+
+// Update applies the read-modify-write fn to the entry by key atomically, using optimistic WATCH/MULTI transactions
+//
+// fn receives the stored value and whether it exists, returning the new value and whether it has to be written. The
+// value is written with the TTL Set would use, nothing is written if fn fails. fn is called again if the entry is
+// changed by other clients in the meantime. The entry is removed from the go-redis/cache local cache. Requires
+// WithClient
+func (c *Cache[T]) Update(ctx context.Context, key string, fn func(old T, exists bool) (T, bool, error)) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	if c.client == nil {
+		return ErrNoClient
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	for {
+		var fnErr error
+		err := c.client.Watch(ctx, func(tx *redis.Tx) error {
+			written, err := c.update(ctx, tx, key, func(old T, exists bool) (T, bool, error) {
+				value, write, err := fn(old, exists)
+				fnErr = err
+				return value, write, err
+			})
+			if written {
+				c.stats.Set(1)
+			}
+			return err
+		}, c.formatKey(key))
+
+		if fnErr != nil {
+			return fnErr
+		}
+
+		if !errors.Is(err, redis.TxFailedErr) {
+			if err = c.track(err); err != nil {
+				return fmt.Errorf("failed to update value in redis: %w", err)
+			}
+
+			return nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// Incr adds delta to the counter by key with INCRBY and returns the result, negative delta decrements it
+//
+// Missing counters start from zero and get the TTL Set would use, existing ones keep their TTL. Counters are stored
+// as plain integers, not encoded by go-redis/cache or the codec, so they can only be read by Incr with zero delta.
+// Fails with cache.ErrNotInteger if the stored value is not an integer and with cache.ErrCounterOverflow if the
+// result does not fit int64. Requires WithClient
+func (c *Cache[T]) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if c.client == nil {
+		return 0, ErrNoClient
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	ttl := max(c.expiration(key, *new(T), nil), 0)
+	n, err := incrBy.Run(ctx, c.client, []string{c.formatKey(key)}, delta, ttl.Milliseconds()).Int64()
+	c.evictLocal(key)
+	if err = c.track(err); err != nil {
+		switch msg := err.Error(); {
+		case strings.Contains(msg, "not an integer"):
+			err = fmt.Errorf("%w: %w", cache.ErrNotInteger, err)
+		case strings.Contains(msg, "overflow"):
+			err = fmt.Errorf("%w: %w", cache.ErrCounterOverflow, err)
+		}
+
+		return 0, fmt.Errorf("failed to increment counter in redis: %w", err)
+	}
+	c.stats.Set(1)
+
+	return n, nil
+}
+
+// Touch renews the entry by key with the PEXPIRE command, making it expire after the ttl counted from now.
+// Non-positive ttl removes the entry
+//
+// Returns cache.MissingEntryError if the entry is missing. The go-redis/cache local cache keeps the entry unless it is
+// removed. Requires WithClient
+func (c *Cache[T]) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	if c.client == nil {
+		return ErrNoClient
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	ok, err := c.client.PExpire(ctx, c.formatKey(key), ttl).Result()
+	if ttl <= 0 {
+		c.evictLocal(key)
+	}
+	if err = c.track(err); err != nil {
+		return fmt.Errorf("failed to renew value ttl in redis: %w", err)
+	}
+
+	if !ok {
+		return cache.NewMissingEntryError(key)
+	}
+
+	return nil
+}
+
+// SetWithTTL puts provided value by cache key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(ctx, key, value, &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to cache using provided ttl duration
+//
+// With WithClient the pairs are written by pipelined SET commands, or by a single MSET command if ttl is
+// cache.NoExpiration and the client is not sharded. Returns the joined errors naming the keys failed to be written
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	if c.client != nil && ttl == cache.NoExpiration && !c.unchanged && !c.sharded() {
+		return c.setBatches(ctx, kvs, c.mset)
+	}
+
+	if c.client != nil && ttl > 0 && !c.unchanged {
+		return c.setBatches(ctx, kvs, func(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+			return c.pipelineSet(ctx, kvs, &ttl)
+		})
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(ctx, kv.Key, kv.Value, &ttl))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	f func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	f = cache.LimitFetch(c.fetchLimiter, f)
+	f = cache.RateLimitFetch(c.fetchRateLimiter, f)
+
+	if cache.IsBypassed(ctx) {
+		return f(ctx)
+	}
+
+	if cache.IsForceRefresh(ctx) {
+		c.stats.Miss()
+		return c.refresh(ctx, key, f, ttl)
+	}
+
+	if c.fetchLock && c.client != nil {
+		f = c.lockFetch(key, f)
+	}
+
+	fetched := false
+	value, err := c.get(ctx, key, func(ctx context.Context) (T, error) {
+		fetched = true
+		return f(ctx)
+	}, ttl)
+
+	switch {
+	case fetched:
+		c.stats.Miss()
+		if err == nil {
+			c.stats.Set(1)
+		}
+	case err == nil:
+		c.stats.Hit()
+	}
+
+	return value, err
+}
+
+// lockFetch wraps the fetcher so it is called under the Lock of the key, unless the value was stored by another
+// process while the lock was awaited
+func (c *Cache[T]) lockFetch(key string, f func(ctx context.Context) (T, error)) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		release, err := c.Lock(ctx, key)
+		if err != nil {
+			return *new(T), err
+		}
+		defer release()
+
+		if value, err := c.get(ctx, key, nil, nil); err == nil {
+			return value, nil
+		}
+
+		return f(ctx)
+	}
+}
+
+// get reads the value by key. On a miss do is called if provided and its result is stored using ttl
+func (c *Cache[T]) get(
+	ctx context.Context,
+	key string,
+	do func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if do == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+		defer cancel()
+	}
+
+	if c.codec != nil {
+		return c.getEncoded(ctx, key, do, ttl)
+	}
+
+	out := new(T)
+
+	item := rc.Item{
+		Ctx:   ctx,
+		Key:   c.formatKey(key),
+		Value: out,
+	}
+
+	var fetchErr error
+	if do != nil {
+		item.Do = func(item *rc.Item) (interface{}, error) {
+			value, err := do(item.Context())
+			fetchErr = err
+			item.TTL = c.expiration(key, value, ttl)
+			return value, err
+		}
+	}
+
+	err := c.load(&item)
+	if err != nil {
+		if errors.Is(err, rc.ErrCacheMiss) {
+			return *out, cache.NewMissingEntryError(key)
+		}
+
+		if fetchErr != nil {
+			return *new(T), fetchErr
+		}
+
+		return *new(T), fmt.Errorf("failed to get value from redis cache: %w", c.track(err))
+	}
+
+	c.track(nil)
+
+	return *out, nil
+}
+
+func (c *Cache[T]) getEncoded(
+	ctx context.Context,
+	key string,
+	do func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	var raw []byte
+
+	item := rc.Item{
+		Ctx:   ctx,
+		Key:   c.formatKey(key),
+		Value: &raw,
+	}
+
+	var fetchErr error
+	if do != nil {
+		item.Do = func(item *rc.Item) (interface{}, error) {
+			value, err := do(item.Context())
+			if err != nil {
+				fetchErr = err
+				return nil, err
+			}
+
+			item.TTL = c.expiration(key, value, ttl)
+			return c.codec.Marshal(value)
+		}
+	}
+
+	err := c.load(&item)
+	if err != nil {
+		if errors.Is(err, rc.ErrCacheMiss) {
+			return *new(T), cache.NewMissingEntryError(key)
+		}
+
+		if fetchErr != nil {
+			return *new(T), fetchErr
+		}
+
+		return *new(T), fmt.Errorf("failed to get value from redis cache: %w", c.track(err))
+	}
+
+	c.track(nil)
+
+	return c.decode(key, raw)
+}
+
+// load reads the item, calling its fetcher on a miss if provided
+//
+// Plain reads do not go through Once, as it writes the empty value back on a miss and hides redis errors
+func (c *Cache[T]) load(item *rc.Item) error {
+	if c.slides() {
+		err := c.getEx(item)
+		if item.Do == nil || !errors.Is(err, rc.ErrCacheMiss) {
+			return err
+		}
+
+		return c.storage.Once(item)
+	}
+
+	if item.Do == nil {
+		return c.storage.Get(item.Ctx, item.Key, item.Value)
+	}
+
+	return c.storage.Once(item)
+}
+
+// getEx reads the item renewing its TTL
+func (c *Cache[T]) getEx(item *rc.Item) error {
+	raw, err := c.client.GetEx(item.Ctx, item.Key, c.sliding).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return rc.ErrCacheMiss
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.storage.Unmarshal(raw, item.Value)
+}
+
+// execGetWithTTL executes the pipeline of GetWithTTL, decoding the value read by get
+func (c *Cache[T]) execGetWithTTL(
+	ctx context.Context,
+	pipe redis.Pipeliner,
+	key string,
+	get *redis.StringCmd,
+	ttl *redis.DurationCmd,
+) (T, error) {
+	_, err := pipe.Exec(ctx)
+	if errors.Is(err, redis.Nil) || (err == nil && ttl.Val() == -2) {
+		c.track(nil)
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	if err = c.track(err); err != nil {
+		return *new(T), fmt.Errorf("failed to get value from redis: %w", err)
+	}
+
+	raw, err := get.Bytes()
+	if err != nil {
+		return *new(T), err
+	}
+
+	return c.decode(key, raw)
+}
+
+// decode converts raw stored bytes into the value, using the codec if assigned
+func (c *Cache[T]) decode(key string, raw []byte) (T, error) {
+	if c.codec != nil {
+		out, err := c.codec.Unmarshal(raw)
+		if err != nil {
+			return *new(T), cache.NewFailedToCastEntryError(key, err)
+		}
+
+		return out, nil
+	}
+
+	out := new(T)
+	if err := c.storage.Unmarshal(raw, out); err != nil {
+		return *new(T), cache.NewFailedToCastEntryError(key, err)
+	}
+
+	return *out, nil
+}
+
+func (c *Cache[T]) refresh(
+	ctx context.Context,
+	key string,
+	f func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	value, err := f(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	return value, c.set(ctx, key, value, ttl)
+}
+
+func (c *Cache[T]) set(ctx context.Context, key string, value T, ttl *time.Duration) error {
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	if c.unchanged && c.client != nil {
+		return c.setChanged(ctx, key, value, ttl)
+	}
+
+	item := &rc.Item{
 		Ctx:   ctx,
 		Key:   c.formatKey(key),
 		Value: value,
 	}
 
-	if ttl != nil {
-		item.TTL = *ttl
+	if c.codec != nil {
+		encoded, err := c.codec.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("could not encode value for key %s: %w", key, err)
+		}
+
+		item.Value = encoded
+	}
+
+	item.TTL = c.expiration(key, value, ttl)
+
+	if err := c.track(c.storage.Set(item)); err != nil {
+		return err
+	}
+	c.stats.Set(1)
+
+	return nil
+}
+
+// setIf writes the value with SET XX if present is set, otherwise with SET NX, reporting whether it was written
+func (c *Cache[T]) setIf(ctx context.Context, key string, value T, ttl time.Duration, present bool) (bool, error) {
+	if cache.IsBypassed(ctx) {
+		return false, nil
+	}
+
+	if c.client == nil {
+		return false, ErrNoClient
+	}
+
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	expiration := c.expiration(key, value, &ttl)
+	if expiration < 0 {
+		return false, nil // go-redis/cache does not write such items to redis either
+	}
+
+	encoded, err := c.encode(key, value)
+	if err != nil {
+		return false, err
+	}
+
+	var cmd *redis.BoolCmd
+	if present {
+		cmd = c.client.SetXX(ctx, c.formatKey(key), encoded, expiration)
+	} else {
+		cmd = c.client.SetNX(ctx, c.formatKey(key), encoded, expiration)
+	}
+
+	stored, err := cmd.Result()
+	c.evictLocal(key)
+	if err = c.track(err); err != nil {
+		return false, fmt.Errorf("failed to set value to redis: %w", err)
+	}
+
+	if stored {
+		c.stats.Set(1)
+	}
+
+	return stored, nil
+}
+
+func (c *Cache[T]) pop(ctx context.Context, key string) (T, error) {
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	raw, err := c.client.GetDel(ctx, c.formatKey(key)).Bytes()
+	c.storage.DeleteFromLocalCache(c.formatKey(key))
+	if errors.Is(err, redis.Nil) {
+		c.track(nil)
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	if err = c.track(err); err != nil {
+		return *new(T), fmt.Errorf("failed to pop value from redis: %w", err)
+	}
+	c.stats.Delete(1)
+
+	return c.decode(key, raw)
+}
+
+// update reads the value watched by the transaction and writes the one returned by fn, reporting whether it was written
+func (c *Cache[T]) update(
+	ctx context.Context,
+	tx *redis.Tx,
+	key string,
+	fn func(old T, exists bool) (T, bool, error),
+) (bool, error) {
+	raw, err := tx.Get(ctx, c.formatKey(key)).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	exists := err == nil
+	old := *new(T)
+	if exists {
+		if old, err = c.decode(key, raw); err != nil {
+			return false, err
+		}
+	}
+
+	value, write, err := fn(old, exists)
+	if err != nil || !write {
+		return false, err
+	}
+
+	expiration := c.expiration(key, value, nil)
+	if expiration < 0 {
+		return false, nil // go-redis/cache does not write such items to redis either
+	}
+
+	encoded, err := c.encode(key, value)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, c.formatKey(key), encoded, expiration)
+		return nil
+	})
+	c.evictLocal(key)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setChanged writes the value by the script skipping unchanged values
+func (c *Cache[T]) setChanged(ctx context.Context, key string, value T, ttl *time.Duration) error {
+	expiration := c.expiration(key, value, ttl)
+	if expiration < 0 {
+		return nil // go-redis/cache does not write such items to redis either
+	}
+	expiration = max(expiration, time.Millisecond)
+
+	encoded, err := c.encode(key, value)
+	if err != nil {
+		return err
+	}
+
+	err = setIfChanged.Run(ctx, c.client, []string{c.formatKey(key)}, encoded, expiration.Milliseconds()).Err()
+	c.evictLocal(key)
+	if err = c.track(err); err != nil {
+		return err
+	}
+	c.stats.Set(1)
+
+	return nil
+}
+
+// setBatches writes the pairs with write in batches limited by WithBatchSize, joining their errors
+func (c *Cache[T]) setBatches(
+	ctx context.Context,
+	kvs []cache.StorageItemMulti[T],
+	write func(ctx context.Context, kvs []cache.StorageItemMulti[T]) error,
+) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	size := c.batchSize
+	if size <= 0 {
+		size = len(kvs)
+	}
+
+	var errs []error
+	for batch := range slices.Chunk(kvs, size) {
+		errs = append(errs, write(ctx, batch))
+	}
+
+	return errors.Join(errs...)
+}
+
+// mset writes the pairs without expiration by a single MSET command. Pairs failed to be encoded are skipped
+func (c *Cache[T]) mset(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	var errs []error
+	keys := make([]string, 0, len(kvs))
+	pairs := make([]any, 0, 2*len(kvs))
+	for _, kv := range kvs {
+		encoded, err := c.encode(kv.Key, kv.Value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		keys = append(keys, kv.Key)
+		pairs = append(pairs, c.formatKey(kv.Key), encoded)
+	}
+
+	if len(keys) == 0 {
+		return errors.Join(errs...)
+	}
+
+	err := c.track(c.client.MSet(ctx, pairs...).Err())
+	c.evictLocal(keys...)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to set values for keys %s: %w", strings.Join(keys, ", "), err))
+		return errors.Join(errs...)
+	}
+	c.stats.Set(len(keys))
+
+	return errors.Join(errs...)
+}
+
+// pipelineSet writes the pairs by pipelined SET commands with expiration. Pairs failed to be encoded are skipped
+func (c *Cache[T]) pipelineSet(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl *time.Duration) error {
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	var errs []error
+	keys := make([]string, 0, len(kvs))
+	pipe := c.client.Pipeline()
+	for _, kv := range kvs {
+		encoded, err := c.encode(kv.Key, kv.Value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		keys = append(keys, kv.Key)
+		pipe.Set(ctx, c.formatKey(kv.Key), encoded, c.expiration(kv.Key, kv.Value, ttl))
+	}
+
+	if len(keys) == 0 {
+		return errors.Join(errs...)
+	}
+
+	cmds, err := pipe.Exec(ctx)
+	c.evictLocal(keys...)
+	tracked := c.track(err)
+
+	// connectivity failures may leave the commands without errors
+	if err != nil && !slices.ContainsFunc(cmds, func(cmd redis.Cmder) bool { return cmd.Err() != nil }) {
+		errs = append(errs, fmt.Errorf("failed to set values for keys %s: %w", strings.Join(keys, ", "), tracked))
+		return errors.Join(errs...)
+	}
+
+	written := 0
+	for i, cmd := range cmds {
+		cmdErr := cmd.Err()
+		if cmdErr == nil {
+			written++
+			continue
+		}
+
+		if cmdErr == err {
+			cmdErr = tracked // the first failure, marked if redis is unavailable
+		}
+		errs = append(errs, fmt.Errorf("failed to set value for key %s: %w", keys[i], cmdErr))
+	}
+	c.stats.Set(written)
+
+	return errors.Join(errs...)
+}
+
+// encode converts the value into bytes stored in redis, using the codec if assigned
+func (c *Cache[T]) encode(key string, value T) ([]byte, error) {
+	var (
+		encoded []byte
+		err     error
+	)
+
+	if c.codec != nil {
+		encoded, err = c.codec.Marshal(value)
+	} else {
+		encoded, err = c.storage.Marshal(value)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not encode value for key %s: %w", key, err)
+	}
+
+	return encoded, nil
+}
+
+// evictLocal removes the entries by keys from the go-redis/cache local cache, so reads do not serve values replaced by
+// direct redis commands
+func (c *Cache[T]) evictLocal(keys ...string) {
+	for _, key := range keys {
+		c.storage.DeleteFromLocalCache(c.formatKey(key))
+	}
+}
+
+// unlink removes the formatted keys by a single UNLINK command, or pipelined ones with sharded clients, freeing
+// memory in background
+func (c *Cache[T]) unlink(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		c.storage.DeleteFromLocalCache(key)
+	}
+
+	var removed int64
+	if c.sharded() {
+		pipe := c.client.Pipeline()
+		unlinks := make([]*redis.IntCmd, 0, len(keys))
+		for _, key := range keys {
+			unlinks = append(unlinks, pipe.Unlink(ctx, key))
+		}
+
+		_, err := pipe.Exec(ctx)
+		if err = c.track(err); err != nil {
+			return fmt.Errorf("failed to delete values from redis: %w", err)
+		}
+
+		for _, unlink := range unlinks {
+			removed += unlink.Val()
+		}
+	} else {
+		var err error
+		removed, err = c.client.Unlink(ctx, keys...).Result()
+		if err = c.track(err); err != nil {
+			return fmt.Errorf("failed to delete values from redis: %w", err)
+		}
 	}
+	c.stats.Delete(int(removed))
 
-	return c.storage.Set(item)
+	return nil
 }
 
 func (c *Cache[T]) delete(ctx context.Context, key string) error {
-	return c.storage.Delete(ctx, key)
+	ctx, cancel := cache.ContextWithDefaultTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	if err := c.track(c.storage.Delete(ctx, c.formatKey(key))); err != nil {
+		return err
+	}
+	c.stats.Delete(1)
+
+	return nil
+}
+
+// track updates the degraded state after a redis command, marking connectivity failures
+// with cache.ErrBackendUnavailable
+func (c *Cache[T]) track(err error) error {
+	var netErr net.Error
+	unavailable := errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, redis.ErrClosed)
+
+	if unavailable {
+		err = fmt.Errorf("%w: %w", cache.ErrBackendUnavailable, err)
+	}
+
+	c.degraded.Store(unavailable)
+	c.health.Observe(err)
+
+	return err
+}
+
+// expiration returns the TTL the value is written with, mirroring go-redis/cache: zero means the default TTL,
+// negative means the value is not written to redis
+func (c *Cache[T]) expiration(key string, value T, ttl *time.Duration) time.Duration {
+	switch {
+	case ttl != nil && *ttl != 0:
+		return *ttl
+	case ttl != nil:
+		return defaultTTL
+	case c.slides():
+		return c.sliding
+	}
+
+	if c.ttlPolicy != nil {
+		if policyTTL := c.ttlPolicy.TTL(key, value); policyTTL > 0 {
+			return policyTTL
+		}
+	}
+
+	return defaultTTL
+}
+
+func (c *Cache[T]) slides() bool {
+	return c.sliding > 0 && c.client != nil
 }
 
 func (c *Cache[T]) formatKey(key string) string {
 	return fmt.Sprintf("%s:%s", c.baseKey, key)
 }
+
+// pattern returns the SCAN pattern matching all the keys under the base key
+func (c *Cache[T]) pattern() string {
+	return globEscaper.Replace(c.baseKey) + ":*"
+}
+
+// fetchMulti loads the values of keys claimed by GetOrFetchMulti with a single fetch call and stores them
+func (c *Cache[T]) fetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+	force bool,
+) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	missing := keys
+	if !force {
+		// values may have been stored by loads finished in the meantime
+		missing = make([]string, 0, len(keys))
+		for _, key := range keys {
+			value, err := c.lookup(ctx, key)
+			if err != nil {
+				missing = append(missing, key)
+				continue
+			}
+
+			values[key] = value
+		}
+	}
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	if err := c.fetchRateLimiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	release, err := c.fetchLimiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	items, err := fetch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.SetMulti(ctx, items) // storing is best-effort, as for GetOrFetch
+
+	for _, item := range items {
+		values[item.Key] = item.Value
+	}
+
+	return values, nil
+}
+
+// lookup reads the entry for GetOrFetchMulti
+func (c *Cache[T]) lookup(ctx context.Context, key string) (T, error) {
+	return c.get(ctx, key, nil, nil)
+}
+
+// isMiss reports whether GetOrFetchMulti has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
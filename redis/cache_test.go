@@ -0,0 +1,297 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/sinu5oid/cache"
+)
+
+func newLocalCache(t *testing.T) *Cache[int64] {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	c, err := NewCacheFromClient[int64](client, "test")
+	if err != nil {
+		t.Fatalf("NewCacheFromClient() error = %v", err)
+	}
+
+	return c.WithCodec(cache.JSONCodec[int64]{}).WithLocalCache(100, time.Minute)
+}
+
+func TestLocalCacheEvictedByDirectWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(ctx context.Context, c *Cache[int64]) error
+		want  int64
+	}{
+		{
+			name: "SetMulti",
+			write: func(ctx context.Context, c *Cache[int64]) error {
+				return c.SetMulti(ctx, []cache.StorageItemMulti[int64]{{Key: "key", Value: 2}})
+			},
+			want: 2,
+		},
+		{
+			name: "SetMultiWithTTL",
+			write: func(ctx context.Context, c *Cache[int64]) error {
+				return c.SetMultiWithTTL(ctx, []cache.StorageItemMulti[int64]{{Key: "key", Value: 2}}, time.Minute)
+			},
+			want: 2,
+		},
+		{
+			name: "Set with skip unchanged",
+			write: func(ctx context.Context, c *Cache[int64]) error {
+				return c.WithSkipUnchanged().Set(ctx, "key", 2)
+			},
+			want: 2,
+		},
+		{
+			name: "SetXX",
+			write: func(ctx context.Context, c *Cache[int64]) error {
+				_, err := c.SetXX(ctx, "key", 2, time.Minute)
+				return err
+			},
+			want: 2,
+		},
+		{
+			name: "Update",
+			write: func(ctx context.Context, c *Cache[int64]) error {
+				return c.Update(ctx, "key", func(old int64, _ bool) (int64, bool, error) {
+					return old + 1, true, nil
+				})
+			},
+			want: 2,
+		},
+		{
+			name: "Incr",
+			write: func(ctx context.Context, c *Cache[int64]) error {
+				_, err := c.Incr(ctx, "key", 4)
+				return err
+			},
+			want: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := newLocalCache(t)
+
+			if err := c.Set(ctx, "key", 1); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			if got, err := c.Get(ctx, "key"); err != nil || got != 1 {
+				t.Fatalf("Get() = %v, %v, want 1", got, err)
+			}
+
+			if err := tt.write(ctx, c); err != nil {
+				t.Fatalf("write error = %v", err)
+			}
+
+			if got, err := c.Get(ctx, "key"); err != nil || got != tt.want {
+				t.Errorf("Get() after write = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalCacheEvictedBySetNX(t *testing.T) {
+	ctx := context.Background()
+	c := newLocalCache(t)
+
+	if err := c.Set(ctx, "key", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if _, err := c.Get(ctx, "key"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if err := c.Touch(ctx, "key", 0); err != nil {
+		t.Fatalf("Touch() error = %v", err)
+	}
+
+	if stored, err := c.SetNX(ctx, "key", 2, time.Minute); err != nil || !stored {
+		t.Fatalf("SetNX() = %v, %v, want true", stored, err)
+	}
+
+	if got, err := c.Get(ctx, "key"); err != nil || got != 2 {
+		t.Errorf("Get() after SetNX = %v, %v, want 2", got, err)
+	}
+}
+
+func TestSetMultiExpires(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	c, err := NewCacheFromClient[int64](client, "test")
+	if err != nil {
+		t.Fatalf("NewCacheFromClient() error = %v", err)
+	}
+
+	if err := c.SetMulti(ctx, []cache.StorageItemMulti[int64]{{Key: "key", Value: 1}}); err != nil {
+		t.Fatalf("SetMulti() error = %v", err)
+	}
+
+	if got := server.TTL("test:key"); got != defaultTTL {
+		t.Errorf("TTL after SetMulti = %v, want %v", got, defaultTTL)
+	}
+}
+
+func TestScanSkipsLocks(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	c, err := NewCacheFromClient[int64](client, "test")
+	if err != nil {
+		t.Fatalf("NewCacheFromClient() error = %v", err)
+	}
+
+	if err := c.Set(ctx, "key", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	release, err := c.Lock(ctx, "key")
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	defer release()
+
+	keys, err := c.Keys(ctx)
+	if err != nil || !slices.Equal(keys, []string{"key"}) {
+		t.Errorf("Keys() = %v, %v, want [key]", keys, err)
+	}
+
+	if err := c.DeleteByPrefix(ctx, ""); err != nil {
+		t.Fatalf("DeleteByPrefix() error = %v", err)
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Lock(lockCtx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lock() of the held lock after DeleteByPrefix() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// commandRecorder records the names of commands sent by the client
+type commandRecorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *commandRecorder) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (r *commandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		r.record(cmd)
+		return next(ctx, cmd)
+	}
+}
+
+func (r *commandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		r.record(cmds...)
+		return next(ctx, cmds)
+	}
+}
+
+func (r *commandRecorder) record(cmds ...redis.Cmder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, cmd := range cmds {
+		r.names = append(r.names, cmd.Name())
+	}
+}
+
+func TestSetMultiWithTTLCommands(t *testing.T) {
+	kvs := []cache.StorageItemMulti[int64]{{Key: "a", Value: 1}, {Key: "b", Value: 2}}
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		want    []string
+		wantTTL time.Duration
+	}{
+		{
+			name:    "no expiration",
+			ttl:     cache.NoExpiration,
+			want:    []string{"mset"},
+			wantTTL: 0,
+		},
+		{
+			name:    "with expiration",
+			ttl:     time.Minute,
+			want:    []string{"set", "set"},
+			wantTTL: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { _ = client.Close() })
+
+			c, err := NewCacheFromClient[int64](client, "test")
+			if err != nil {
+				t.Fatalf("NewCacheFromClient() error = %v", err)
+			}
+
+			recorder := &commandRecorder{}
+			client.AddHook(recorder)
+
+			if err := c.SetMultiWithTTL(ctx, kvs, tt.ttl); err != nil {
+				t.Fatalf("SetMultiWithTTL() error = %v", err)
+			}
+
+			if !slices.Equal(recorder.names, tt.want) {
+				t.Errorf("commands = %v, want %v", recorder.names, tt.want)
+			}
+
+			for _, kv := range kvs {
+				if got := server.TTL("test:" + kv.Key); got != tt.wantTTL {
+					t.Errorf("TTL of %s = %v, want %v", kv.Key, got, tt.wantTTL)
+				}
+
+				if got, err := c.Get(ctx, kv.Key); err != nil || got != kv.Value {
+					t.Errorf("Get(%s) = %v, %v, want %v", kv.Key, got, err, kv.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestSetWithOptionsRejectsCost(t *testing.T) {
+	ctx := context.Background()
+	c := newLocalCache(t)
+
+	if err := c.SetWithOptions(ctx, "key", 1, cache.Cost(10)); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("SetWithOptions() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+
+	var missingEntryError cache.MissingEntryError
+	if _, err := c.Get(ctx, "key"); !errors.As(err, &missingEntryError) {
+		t.Errorf("Get() error = %v, want cache.MissingEntryError", err)
+	}
+}
@@ -0,0 +1,28 @@
+package redis_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/cachetest"
+	cacheredis "github.com/sinu5oid/cache/redis"
+)
+
+func TestCacher(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cachetest.RunCacherTests(t, func() cache.TTLCacher[string] {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { _ = client.Close() })
+
+		c, err := cacheredis.NewCacheFromClient[string](client, "test")
+		if err != nil {
+			t.Fatalf("NewCacheFromClient() error = %v", err)
+		}
+
+		return c
+	}, cachetest.TTL[string](0)) // miniredis does not expire keys in real time
+}
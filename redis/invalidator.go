@@ -0,0 +1,148 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/sinu5oid/cache"
+)
+
+const (
+	// originSize is the length of the hex-encoded id identifying the publishing Invalidator
+	originSize = 16
+
+	keyEvent   = "k"
+	clearEvent = "c"
+)
+
+// Invalidator is a cache.OriginClearInvalidator broadcasting invalidations between processes over redis pub/sub
+//
+// Subscribers of the same Invalidator are notified synchronously, like with cache.LocalInvalidator, other processes
+// receive events asynchronously. Pub/sub delivery is at most once: events published while a process is disconnected
+// are lost, so local caches should still use a TTL. Safe for concurrent usage
+type Invalidator struct {
+	client  redis.UniversalClient
+	channel string
+	origin  string
+	local   *cache.LocalInvalidator
+
+	pubsub    *redis.PubSub
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewInvalidator subscribes to the channel and creates an Invalidator publishing to it
+//
+// Returns once the subscription is confirmed by redis, so events published afterwards are not missed
+func NewInvalidator(ctx context.Context, client redis.UniversalClient, channel string) (*Invalidator, error) {
+	if client == nil {
+		return nil, ErrNoClient
+	}
+
+	origin := make([]byte, originSize/2)
+	if _, err := rand.Read(origin); err != nil {
+		return nil, err
+	}
+
+	pubsub := client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return nil, errors.Join(err, pubsub.Close())
+	}
+
+	i := &Invalidator{
+		client:  client,
+		channel: channel,
+		origin:  hex.EncodeToString(origin),
+		local:   cache.NewLocalInvalidator(),
+		pubsub:  pubsub,
+		done:    make(chan struct{}),
+	}
+
+	go i.listen()
+
+	return i, nil
+}
+
+// Publish notifies local subscribers and other processes that the entry by key is no longer valid
+func (i *Invalidator) Publish(ctx context.Context, key string) error {
+	_ = i.local.Publish(ctx, key)
+	return i.client.Publish(ctx, i.channel, i.origin+keyEvent+key).Err()
+}
+
+// PublishFrom notifies local subscribers on behalf of origin and other processes that the entry by key is no longer
+// valid
+func (i *Invalidator) PublishFrom(ctx context.Context, origin, key string) error {
+	_ = i.local.PublishFrom(ctx, origin, key)
+	return i.client.Publish(ctx, i.channel, i.origin+keyEvent+key).Err()
+}
+
+// Subscribe registers handler called for every key published locally or by other processes
+func (i *Invalidator) Subscribe(handler func(key string)) func() {
+	return i.local.Subscribe(handler)
+}
+
+// SubscribeFrom registers handler called for every key published locally or by other processes with its origin,
+// empty for keys of other processes
+func (i *Invalidator) SubscribeFrom(handler func(origin, key string)) func() {
+	return i.local.SubscribeFrom(handler)
+}
+
+// PublishClear notifies local subscribers and other processes that all the entries are no longer valid
+func (i *Invalidator) PublishClear(ctx context.Context) error {
+	_ = i.local.PublishClear(ctx)
+	return i.client.Publish(ctx, i.channel, i.origin+clearEvent).Err()
+}
+
+// PublishClearFrom notifies local subscribers on behalf of origin and other processes that all the entries are no
+// longer valid
+func (i *Invalidator) PublishClearFrom(ctx context.Context, origin string) error {
+	_ = i.local.PublishClearFrom(ctx, origin)
+	return i.client.Publish(ctx, i.channel, i.origin+clearEvent).Err()
+}
+
+// SubscribeClear registers handler called for every clear published locally or by other processes
+func (i *Invalidator) SubscribeClear(handler func()) func() {
+	return i.local.SubscribeClear(handler)
+}
+
+// SubscribeClearFrom registers handler called for every clear published locally or by other processes with its
+// origin, empty for clears of other processes
+func (i *Invalidator) SubscribeClearFrom(handler func(origin string)) func() {
+	return i.local.SubscribeClearFrom(handler)
+}
+
+// Close unsubscribes from the channel. Published events are no longer received
+func (i *Invalidator) Close() error {
+	var err error
+	i.closeOnce.Do(func() {
+		err = i.pubsub.Close()
+		<-i.done
+	})
+
+	return err
+}
+
+func (i *Invalidator) listen() {
+	defer close(i.done)
+
+	ctx := context.Background()
+	for msg := range i.pubsub.Channel() {
+		payload := msg.Payload
+		if len(payload) <= originSize || strings.HasPrefix(payload, i.origin) {
+			continue // malformed or published by this Invalidator, local subscribers are already notified
+		}
+
+		switch event := payload[originSize:]; {
+		case strings.HasPrefix(event, keyEvent):
+			_ = i.local.Publish(ctx, event[len(keyEvent):])
+		case event == clearEvent:
+			_ = i.local.PublishClear(ctx)
+		}
+	}
+}
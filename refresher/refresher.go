@@ -0,0 +1,299 @@
+// Package refresher keeps registered cache keys warm, fetching their values in background before they expire
+package refresher
+
+import (
+	"container/heap"
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// defaultWorkers is the number of refreshes running simultaneously unless configured with WithWorkers
+const defaultWorkers = 4
+
+// Refresher periodically fetches the values of registered keys and writes them to the cache, so readers never find
+// them missing
+//
+// Every key is refreshed right after it is registered or the Refresher is started, then every interval shortened by
+// the jitter. Refreshes run on a pool of workers. Failed fetches are reported to the error handler and retried after
+// the next interval, the cached value is kept meanwhile. Safe for concurrent usage
+type Refresher[T any] struct {
+	cache   cache.Cacher[T]
+	workers int
+	jitter  float64
+	ttl     *time.Duration
+	onError func(key string, err error)
+
+	mu      sync.Mutex
+	keys    map[string]*registration[T]
+	queue   queue[T]
+	wake    chan struct{}
+	stop    chan struct{}
+	running sync.WaitGroup
+}
+
+type registration[T any] struct {
+	key      string
+	interval time.Duration
+	fetch    func(ctx context.Context) (T, error)
+	next     time.Time
+	index    int // position in the queue, -1 while the key is being refreshed
+}
+
+// New creates a Refresher writing values to the cache. Call Start to begin refreshing
+func New[T any](c cache.Cacher[T]) *Refresher[T] {
+	return &Refresher[T]{
+		cache:   c,
+		workers: defaultWorkers,
+		keys:    make(map[string]*registration[T]),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// WithWorkers assigns the number of refreshes running simultaneously, 4 by default
+//
+// Takes effect on the next Start
+func (r *Refresher[T]) WithWorkers(n int) *Refresher[T] {
+	r.workers = max(n, 1)
+	return r
+}
+
+// WithJitter shortens every refresh interval by a random share of it up to fraction, in [0, 1)
+//
+// Spreads refreshes of keys registered at the same time, so they do not hit the source at once
+func (r *Refresher[T]) WithJitter(fraction float64) *Refresher[T] {
+	r.jitter = fraction
+	return r
+}
+
+// WithTTL writes refreshed values with the ttl, should exceed refresh intervals. The cache must implement
+// cache.TTLCacher, otherwise values are written with Set
+func (r *Refresher[T]) WithTTL(ttl time.Duration) *Refresher[T] {
+	r.ttl = &ttl
+	return r
+}
+
+// OnError assigns the function receiving errors of failed refreshes
+func (r *Refresher[T]) OnError(fn func(key string, err error)) *Refresher[T] {
+	r.onError = fn
+	return r
+}
+
+// Register makes the key refreshed every interval with the fetch function, replacing its previous registration.
+// The key is refreshed right away if the Refresher is running
+func (r *Refresher[T]) Register(key string, interval time.Duration, fetch func(ctx context.Context) (T, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(key)
+
+	reg := &registration[T]{
+		key:      key,
+		interval: interval,
+		fetch:    fetch,
+	}
+	r.keys[key] = reg
+	heap.Push(&r.queue, reg)
+
+	r.notify()
+}
+
+// Unregister stops refreshing the key. A refresh already running is completed, the cached value is kept
+func (r *Refresher[T]) Unregister(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(key)
+}
+
+// Start begins refreshing registered keys in background until Stop is called or ctx is done. Fetches receive ctx
+func (r *Refresher[T]) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+
+	jobs := make(chan *registration[T])
+	r.running.Add(1 + r.workers)
+	go r.schedule(ctx, r.stop, jobs)
+	for range r.workers {
+		go r.work(ctx, jobs)
+	}
+}
+
+// Stop stops refreshing and waits for running refreshes to complete. Registrations are kept for the next Start
+func (r *Refresher[T]) Stop() {
+	r.mu.Lock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	r.mu.Unlock()
+
+	r.running.Wait()
+}
+
+// schedule passes keys to the workers once they are due
+func (r *Refresher[T]) schedule(ctx context.Context, stop <-chan struct{}, jobs chan<- *registration[T]) {
+	defer r.running.Done()
+	defer close(jobs)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		var (
+			due  *registration[T]
+			wait <-chan time.Time
+		)
+
+		r.mu.Lock()
+		if len(r.queue) > 0 {
+			if delay := time.Until(r.queue[0].next); delay > 0 {
+				timer.Reset(delay)
+				wait = timer.C
+			} else {
+				due = heap.Pop(&r.queue).(*registration[T])
+			}
+		}
+		r.mu.Unlock()
+
+		if due != nil {
+			select {
+			case jobs <- due:
+				continue
+			case <-stop:
+			case <-ctx.Done():
+			}
+
+			r.requeue(due)
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-r.wake:
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Refresher[T]) work(ctx context.Context, jobs <-chan *registration[T]) {
+	defer r.running.Done()
+
+	for reg := range jobs {
+		r.refresh(ctx, reg)
+	}
+}
+
+// refresh fetches and writes the value of the key, then schedules its next refresh unless it was unregistered
+func (r *Refresher[T]) refresh(ctx context.Context, reg *registration[T]) {
+	if err := r.write(ctx, reg); err != nil && r.onError != nil {
+		r.onError(reg.key, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys[reg.key] != reg {
+		return
+	}
+
+	reg.next = time.Now().Add(r.jittered(reg.interval))
+	heap.Push(&r.queue, reg)
+
+	r.notify()
+}
+
+// requeue puts back the registration taken by the scheduler but not refreshed, unless it was unregistered
+func (r *Refresher[T]) requeue(reg *registration[T]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys[reg.key] == reg {
+		heap.Push(&r.queue, reg)
+	}
+}
+
+func (r *Refresher[T]) write(ctx context.Context, reg *registration[T]) error {
+	value, err := reg.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ttlCacher, ok := r.cache.(cache.TTLCacher[T]); ok && r.ttl != nil {
+		return ttlCacher.SetWithTTL(ctx, reg.key, value, *r.ttl)
+	}
+
+	return r.cache.Set(ctx, reg.key, value)
+}
+
+func (r *Refresher[T]) jittered(interval time.Duration) time.Duration {
+	if r.jitter <= 0 {
+		return interval
+	}
+
+	return interval - time.Duration(rand.Float64()*r.jitter*float64(interval))
+}
+
+// remove drops the registration of the key, the caller must hold the lock
+func (r *Refresher[T]) remove(key string) {
+	reg, ok := r.keys[key]
+	if !ok {
+		return
+	}
+
+	delete(r.keys, key)
+	if reg.index >= 0 {
+		heap.Remove(&r.queue, reg.index)
+	}
+}
+
+// notify wakes the scheduler up to reconsider the earliest refresh
+func (r *Refresher[T]) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// queue orders registrations by their next refresh time, implementing heap.Interface
+type queue[T any] []*registration[T]
+
+func (q queue[T]) Len() int {
+	return len(q)
+}
+
+func (q queue[T]) Less(i, j int) bool {
+	return q[i].next.Before(q[j].next)
+}
+
+func (q queue[T]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue[T]) Push(x any) {
+	reg := x.(*registration[T])
+	reg.index = len(*q)
+	*q = append(*q, reg)
+}
+
+func (q *queue[T]) Pop() any {
+	old := *q
+	reg := old[len(old)-1]
+	old[len(old)-1] = nil
+	reg.index = -1
+	*q = old[:len(old)-1]
+	return reg
+}
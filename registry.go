@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// TypeRegistry maps concrete types to stable names
+//
+// Used by RegistryCodec to restore the concrete type of interface-typed values. Safe for concurrent usage
+type TypeRegistry struct {
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+// NewTypeRegistry creates an empty TypeRegistry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		byName: make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+// Register associates name with the concrete type of the provided prototype value
+//
+// Pointer and non-pointer types are distinct: registering Foo{} does not cover *Foo
+func (r *TypeRegistry) Register(name string, prototype any) error {
+	if prototype == nil {
+		return fmt.Errorf("could not register type %s: prototype must not be nil", name)
+	}
+
+	t := reflect.TypeOf(prototype)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.byName[name]; ok && existing != t {
+		return fmt.Errorf("could not register type %s: name is already used by %s", name, existing)
+	}
+
+	if existing, ok := r.byType[t]; ok && existing != name {
+		return fmt.Errorf("could not register type %s: %s is already registered as %s", name, t, existing)
+	}
+
+	r.byName[name] = t
+	r.byType[t] = name
+
+	return nil
+}
+
+// RegisterType associates name with the type V
+func RegisterType[V any](r *TypeRegistry, name string) error {
+	return r.Register(name, *new(V))
+}
+
+func (r *TypeRegistry) nameOf(t reflect.Type) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.byType[t]
+	return name, ok
+}
+
+func (r *TypeRegistry) typeOf(name string) (reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.byName[name]
+	return t, ok
+}
+
+type registryEnvelope struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// RegistryCodec is a JSON Codec that embeds the registered type name into the stored envelope
+//
+// Allows interface-typed values (e.g. Cacher[SomeInterface]) to round-trip through byte backends
+// with their concrete type restored on read
+type RegistryCodec[T any] struct {
+	registry *TypeRegistry
+}
+
+// NewRegistryCodec creates a RegistryCodec resolving types through the provided registry
+func NewRegistryCodec[T any](registry *TypeRegistry) RegistryCodec[T] {
+	return RegistryCodec[T]{registry: registry}
+}
+
+// Marshal encodes the value together with the registered name of its concrete type
+func (c RegistryCodec[T]) Marshal(value T) ([]byte, error) {
+	v := any(value)
+	if v == nil {
+		return nil, fmt.Errorf("could not marshal nil value: concrete type is unknown")
+	}
+
+	t := reflect.TypeOf(v)
+	name, ok := c.registry.nameOf(t)
+	if !ok {
+		return nil, NewUnregisteredTypeError(t.String())
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal value of type %s: %w", name, err)
+	}
+
+	return json.Marshal(registryEnvelope{Type: name, Value: data})
+}
+
+// Unmarshal decodes the envelope and restores the value as its registered concrete type
+func (c RegistryCodec[T]) Unmarshal(data []byte) (T, error) {
+	var envelope registryEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return *new(T), fmt.Errorf("could not unmarshal type envelope: %w", err)
+	}
+
+	t, ok := c.registry.typeOf(envelope.Type)
+	if !ok {
+		return *new(T), NewUnregisteredTypeError(envelope.Type)
+	}
+
+	ptr := reflect.New(t)
+	if err := json.Unmarshal(envelope.Value, ptr.Interface()); err != nil {
+		return *new(T), fmt.Errorf("could not unmarshal value of type %s: %w", envelope.Type, err)
+	}
+
+	out, ok := ptr.Elem().Interface().(T)
+	if !ok {
+		return *new(T), fmt.Errorf("registered type %s is not assignable to %s", envelope.Type, reflect.TypeFor[T]())
+	}
+
+	return out, nil
+}
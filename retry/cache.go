@@ -0,0 +1,251 @@
+// Package retry provides a cache wrapper retrying calls failed with transient backend errors
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+const (
+	defaultAttempts  = 3
+	defaultBaseDelay = 50 * time.Millisecond
+	defaultMaxDelay  = time.Second
+)
+
+// Cache wraps a cache.Cacher retrying failed calls with exponential backoff
+//
+// The delay before the n-th retry is the base delay doubled n-1 times, capped by the max delay and shortened by a
+// random share of it up to the jitter. By default calls failed with cache.ErrBackendUnavailable are retried up to 3
+// attempts in total, starting from 50ms delay capped by 1s, with full jitter. Retries stop once the context is done.
+// Safe for concurrent usage if the wrapped cache is
+type Cache[T any] struct {
+	backend cache.Cacher[T]
+
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	jitter    float64
+	retryable func(err error) bool
+}
+
+// Wrap creates a Cache with the default retry policy
+func Wrap[T any](c cache.Cacher[T]) *Cache[T] {
+	return &Cache[T]{
+		backend:   c,
+		attempts:  defaultAttempts,
+		baseDelay: defaultBaseDelay,
+		maxDelay:  defaultMaxDelay,
+		jitter:    1,
+		retryable: isTransient,
+	}
+}
+
+// WithAttempts assigns the total number of attempts per call, including the first one
+func (c *Cache[T]) WithAttempts(attempts int) *Cache[T] {
+	c.attempts = max(attempts, 1)
+	return c
+}
+
+// WithBackoff assigns the delay before the first retry and the cap of the delays growing exponentially from it
+func (c *Cache[T]) WithBackoff(base, maxDelay time.Duration) *Cache[T] {
+	c.baseDelay = base
+	c.maxDelay = max(maxDelay, base)
+	return c
+}
+
+// WithJitter shortens every delay by a random share of it up to fraction, in [0, 1]. Zero disables jitter
+func (c *Cache[T]) WithJitter(fraction float64) *Cache[T] {
+	c.jitter = min(max(fraction, 0), 1)
+	return c
+}
+
+// WithRetryable assigns the function deciding which errors are transient and worth retrying, replacing the default
+// one. Missing entries are never retried
+func (c *Cache[T]) WithRetryable(fn func(err error) bool) *Cache[T] {
+	c.retryable = func(err error) bool {
+		return !isMiss(err) && fn(err)
+	}
+	return c
+}
+
+// Unwrap returns the wrapped cache
+func (c *Cache[T]) Unwrap() cache.Cacher[T] {
+	return c.backend
+}
+
+// Get retrieves an item by key
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	var value T
+	err := c.do(ctx, func() (err error) {
+		value, err = c.backend.Get(ctx, key)
+		return err
+	})
+
+	return value, err
+}
+
+// Set puts the provided value by key
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.do(ctx, func() error {
+		return c.backend.Set(ctx, key, value)
+	})
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	var items []cache.StorageItemMulti[T]
+	err := c.do(ctx, func() (err error) {
+		items, err = c.backend.GetMulti(ctx, keys)
+		return err
+	})
+
+	return items, err
+}
+
+// SetMulti puts provided k/v pairs
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	return c.do(ctx, func() error {
+		return c.backend.SetMulti(ctx, kvs)
+	})
+}
+
+// Delete removes cached value by key
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	return c.do(ctx, func() error {
+		return c.backend.Delete(ctx, key)
+	})
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.do(ctx, func() error {
+		return c.backend.DeleteMulti(ctx, keys)
+	})
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return c.do(ctx, func() error {
+		return ttlCacher.SetWithTTL(ctx, key, value, ttl)
+	})
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(cache.TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return c.do(ctx, func() error {
+		return ttlCacher.SetMultiWithTTL(ctx, kvs, ttl)
+	})
+}
+
+// GetOrFetch obtains the value by key with Get, calling the fetcher and storing its result with Set if the value was
+// not found
+//
+// Cache calls are retried, the fetcher is called once. The wrapped cache GetOrFetch is not used, as retrying it would
+// call the fetcher again
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement cache.TTLCacher
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	if !cache.IsForceRefresh(ctx) {
+		value, err := c.Get(ctx, key)
+		if !isMiss(err) {
+			return value, err
+		}
+	}
+
+	value, err := fetcher(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	if ttl != nil {
+		return value, c.SetWithTTL(ctx, key, value, *ttl)
+	}
+
+	return value, c.Set(ctx, key, value)
+}
+
+// do calls fn until it succeeds, fails with an error not worth retrying, runs out of attempts or the context is done
+func (c *Cache[T]) do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= c.attempts || !c.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(c.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns the backoff before the retry following the attempt
+func (c *Cache[T]) delay(attempt int) time.Duration {
+	delay := c.maxDelay
+	if shift := attempt - 1; shift < 62 && c.baseDelay<<shift > 0 && c.baseDelay<<shift < c.maxDelay {
+		delay = c.baseDelay << shift
+	}
+
+	return delay - time.Duration(rand.Float64()*c.jitter*float64(delay))
+}
+
+// isTransient is the default retry predicate
+func isTransient(err error) bool {
+	return errors.Is(err, cache.ErrBackendUnavailable)
+}
+
+// isMiss reports whether the read failed because the entry is absent
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
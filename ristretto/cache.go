@@ -0,0 +1,487 @@
+// Package ristretto provides a typed in-memory cache based on github.com/dgraph-io/ristretto
+//
+// Unlike inmem and lru, entries are admitted by TinyLFU and evicted by sampled LFU, bounded by the total cost of
+// entries rather than their count. Suited for hot, high-cardinality workloads
+package ristretto
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/dgraph-io/ristretto/v2"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+// ErrRejected is returned by writes of a cache created WithSyncWrites when the entry was not admitted or was dropped
+// because of contention
+var ErrRejected = errors.New("entry was rejected by the cache")
+
+// Cache represents typed ristretto cache wrapped
+//
+// Writes are applied asynchronously by default: an entry may be not readable right after Set, or may be never
+// stored if it was not admitted. Safe for concurrent usage
+type Cache[T any] struct {
+	storage    *ristretto.Cache[string, T]
+	defaultTTL *time.Duration
+	costFunc   cache.CostFunc[T]
+	syncWrites bool
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[T]
+}
+
+// NewCache creates a Cache instance bounded by maxCost with no TTL
+//
+// Entry costs are estimated with cache.EstimateCost, so maxCost is roughly the memory limit in bytes.
+// Metrics collection is enabled
+func NewCache[T any](maxCost int64) (*Cache[T], error) {
+	// ristretto recommends 10 counters per entry, assume an average entry of 100 bytes
+	numCounters := max(maxCost/10, 100)
+
+	return NewCacheWithConfig(&ristretto.Config[string, T]{
+		NumCounters: numCounters,
+		MaxCost:     maxCost,
+		BufferItems: 64,
+		Metrics:     true,
+	})
+}
+
+// NewCacheWithConfig creates a Cache instance using the provided ristretto config
+//
+// When config.Cost is not set, entry costs are computed by the cache cost function, see WithCostFunc
+func NewCacheWithConfig[T any](config *ristretto.Config[string, T]) (*Cache[T], error) {
+	storage, err := ristretto.NewCache(config)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache[T]{storage: storage}
+	if config.Cost == nil {
+		c.costFunc = cache.EstimateCost[T]
+	}
+
+	return c, nil
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
+	c.defaultTTL = &ttl
+	return c
+}
+
+// WithCostFunc assigns the function used to compute entry costs. Ignored when the ristretto config has Cost set
+//
+// Previous items keep the cost computed when they were written
+func (c *Cache[T]) WithCostFunc(costFunc cache.CostFunc[T]) *Cache[T] {
+	c.costFunc = costFunc
+	return c
+}
+
+// WithSyncWrites makes writes wait until the entry is applied, so it is readable by the next Get
+//
+// Writes of entries not admitted by the cache fail with ErrRejected. Synchronous writes are considerably slower
+func (c *Cache[T]) WithSyncWrites() *Cache[T] {
+	c.syncWrites = true
+	return c
+}
+
+// WithIsolatedFetchErrors stops sharing fetch errors between concurrent GetOrFetch callers of the same key
+//
+// By default callers waiting for a fetch receive its error. When isolated, they run their own fetch instead
+func (c *Cache[T]) WithIsolatedFetchErrors() *Cache[T] {
+	c.flights.IsolateErrors(true)
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache[T]) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache[T] {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache[T]) WithMaxConcurrentFetches(n int) *Cache[T] {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache[T]) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache[T] {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// Metrics returns hit, miss, admission and eviction counters. Nil if metrics are disabled in the config
+func (c *Cache[T]) Metrics() *ristretto.Metrics {
+	return c.storage.Metrics
+}
+
+// Wait blocks until all the writes made before the call are applied
+func (c *Cache[T]) Wait() {
+	c.storage.Wait()
+}
+
+// Close stops ristretto background goroutines. The cache can not be used afterwards
+func (c *Cache[T]) Close() {
+	c.storage.Close()
+}
+
+// Clear removes items from internal storages
+func (c *Cache[T]) Clear() {
+	c.storage.Clear()
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return c.get(key)
+}
+
+// GetOrFetch tries to obtain cached value from internal storage. If multiple callers are accessing the same key,
+// later callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		if result, err := c.get(key); err == nil {
+			return result, nil
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			if result, err := c.get(key); err == nil {
+				return result, nil
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			// not admitted values are still returned to the caller
+			_ = c.set(key, result, ttl, 0)
+		}
+
+		return result, err
+	})
+}
+
+// GetOrFetchMulti returns cached values by provided keys, loading all the missing ones with a single fetch call and
+// storing the results. Result slice follows the order of keys and may have fewer items than keys, it means that
+// fetch did not return items by that key
+//
+// Concurrent loads of the same key are deduplicated: keys being loaded by other callers are waited for.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return fetch(ctx, keys)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	found := make(map[string]T, len(keys))
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !force {
+			if value, ok := c.storage.Get(key); ok {
+				found[key] = value
+				continue
+			}
+		}
+
+		missing = append(missing, key)
+	}
+
+	if len(missing) > 0 {
+		loaded, err := c.flights.DoMulti(ctx, missing, func(ctx context.Context, owned []string) (map[string]T, error) {
+			return c.fetchMulti(ctx, owned, fetch)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(found, loaded)
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, ok := found[key]
+		if !ok {
+			continue
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// Set puts the provided value by cache key to internal storage
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(key, value, nil, 0)
+}
+
+// SetWithOptions puts the provided value by cache key using provided per-call options
+//
+// Supports all the options. Writes are asynchronous unless WithSyncWrites is used, cache.Async does not change it
+func (c *Cache[T]) SetWithOptions(ctx context.Context, key string, value T, opts ...cache.SetOption) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	o := cache.NewSetOptions(opts...)
+	return c.set(key, value, o.TTL, o.Cost)
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[T]{}, nil
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		val, ok := c.storage.Get(key)
+		if !ok {
+			continue
+		}
+
+		item := cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: val,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// GetMultiWithTTL returns cached values by provided keys along with their remaining TTL.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMultiWithTTL(ctx context.Context, keys []string) ([]cache.StorageItemMultiWithTTL[T], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMultiWithTTL[T]{}, nil
+	}
+
+	res := make([]cache.StorageItemMultiWithTTL[T], 0, len(keys))
+	for _, key := range keys {
+		val, ok := c.storage.Get(key)
+		if !ok {
+			continue
+		}
+
+		ttl, ok := c.storage.GetTTL(key)
+		if !ok {
+			continue
+		}
+
+		if ttl == 0 {
+			ttl = cache.NoExpiration
+		}
+
+		item := cache.StorageItemMultiWithTTL[T]{
+			Key:   key,
+			Value: val,
+			TTL:   ttl,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to cache
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(kv.Key, kv.Value, nil, 0))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Delete removes cached value from internal storage by key
+func (c *Cache[T]) Delete(_ context.Context, key string) error {
+	c.storage.Del(key)
+	if c.syncWrites {
+		c.storage.Wait()
+	}
+
+	return nil
+}
+
+// DeleteMulti removes cached values by keys
+func (c *Cache[T]) DeleteMulti(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		c.storage.Del(key)
+	}
+
+	if c.syncWrites {
+		c.storage.Wait()
+	}
+
+	return nil
+}
+
+// SetWithTTL puts provided value by cache key using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.set(key, value, &ttl, 0)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to cache using provided ttl duration
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	errs := make([]error, 0, len(kvs))
+	for _, kv := range kvs {
+		errs = append(errs, c.set(kv.Key, kv.Value, &ttl, 0))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Cache[T]) get(key string) (T, error) {
+	value, ok := c.storage.Get(key)
+	if !ok {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return value, nil
+}
+
+func (c *Cache[T]) set(key string, value T, ttl *time.Duration, cost int64) error {
+	finalTTL := c.defaultTTL
+	if ttl != nil {
+		finalTTL = ttl
+	}
+
+	if cost <= 0 && c.costFunc != nil {
+		cost = c.costFunc(key, value)
+	}
+
+	var admitted bool
+	if finalTTL != nil && *finalTTL > 0 {
+		admitted = c.storage.SetWithTTL(key, value, cost, *finalTTL)
+	} else {
+		admitted = c.storage.Set(key, value, cost)
+	}
+
+	if !c.syncWrites {
+		return nil
+	}
+
+	c.storage.Wait()
+	if !admitted {
+		return ErrRejected
+	}
+
+	// admission is decided asynchronously, GetTTL does not affect hit metrics
+	if _, ok := c.storage.GetTTL(key); !ok {
+		return ErrRejected
+	}
+
+	return nil
+}
+
+// fetchMulti loads the values of keys claimed by GetOrFetchMulti with a single fetch call and stores them
+func (c *Cache[T]) fetchMulti(
+	ctx context.Context,
+	keys []string,
+	fetch func(ctx context.Context, missing []string) ([]cache.StorageItemMulti[T], error),
+) (map[string]T, error) {
+	if err := c.fetchRateLimiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	release, err := c.fetchLimiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	items, err := fetch(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(items))
+	for _, item := range items {
+		_ = c.set(item.Key, item.Value, nil, 0)
+		values[item.Key] = item.Value
+	}
+
+	return values, nil
+}
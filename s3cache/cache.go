@@ -0,0 +1,508 @@
+// Package s3cache provides a cache storing entries as objects of an S3-compatible object storage, for payloads too
+// large for memory-backed caches, e.g. build artifacts
+//
+// Expiration times are stored in object metadata and checked on reads, expired objects are deleted lazily. Configure a
+// bucket lifecycle rule to remove objects which are never read again. Use cache.Typed or NewTypedCache to store other
+// types
+package s3cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+const (
+	// expiresAtMeta is the object metadata entry holding the expiration time
+	expiresAtMeta = "expires-at"
+
+	// maxDeleteObjects is the maximal number of objects removed by a single DeleteObjects call
+	maxDeleteObjects = 1000
+)
+
+// Client is the subset of the S3 API used by Cache, implemented by *s3.Client
+type Client interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(
+		ctx context.Context,
+		params *s3.DeleteObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(
+		ctx context.Context,
+		params *s3.DeleteObjectsInput,
+		optFns ...func(*s3.Options),
+	) (*s3.DeleteObjectsOutput, error)
+}
+
+// Cache represents a cache storing byte slices as objects of a bucket
+//
+// Safe for concurrent usage
+type Cache struct {
+	client     Client
+	bucket     string
+	prefix     string
+	defaultTTL *time.Duration
+
+	fetchLimiter     *cache.FetchLimiter
+	fetchRateLimiter *cache.FetchRateLimiter
+	flights          singleflight.Group[[]byte]
+}
+
+// NewCache creates a Cache instance storing objects in the bucket
+func NewCache(client Client, bucket string) *Cache {
+	return &Cache{
+		client: client,
+		bucket: bucket,
+	}
+}
+
+// NewTypedCache creates a Cache instance storing objects in the bucket, converting values with the codec
+func NewTypedCache[T any](client Client, bucket string, codec cache.Codec[T]) cache.Cacher[T] {
+	return cache.Typed[T](NewCache(client, bucket), codec)
+}
+
+// WithTTL assigns provided ttl value
+//
+// Previous items are not updated automatically. Only newly added items would receive TTL settings
+func (c *Cache) WithTTL(ttl time.Duration) *Cache {
+	c.defaultTTL = &ttl
+	return c
+}
+
+// WithPrefix stores objects under the prefix, e.g. "cache/", so the bucket can be shared with other data
+func (c *Cache) WithPrefix(prefix string) *Cache {
+	c.prefix = prefix
+	return c
+}
+
+// WithFetchLimiter bounds the number of GetOrFetch fetchers running simultaneously across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.TooManyFetchesError
+func (c *Cache) WithFetchLimiter(limiter *cache.FetchLimiter) *Cache {
+	c.fetchLimiter = limiter
+	return c
+}
+
+// WithMaxConcurrentFetches bounds the number of GetOrFetch fetchers running simultaneously across all keys by n
+//
+// Up to n more fetchers wait for a free slot, the rest are shed with cache.TooManyFetchesError. Use WithFetchLimiter
+// for other queue lengths or to share the limit between caches
+func (c *Cache) WithMaxConcurrentFetches(n int) *Cache {
+	return c.WithFetchLimiter(cache.NewFetchLimiter(n, n))
+}
+
+// WithFetchRateLimiter bounds the rate of GetOrFetch fetchers across all keys
+//
+// The limiter can be shared between caches. Callers rejected by the limiter receive cache.FetchRateLimitedError
+func (c *Cache) WithFetchRateLimiter(limiter *cache.FetchRateLimiter) *Cache {
+	c.fetchRateLimiter = limiter
+	return c
+}
+
+// Get retrieves an item from cache by key. Does not return expired by TTL items
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	if cache.IsBypassed(ctx) {
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	return c.get(ctx, key)
+}
+
+// Open returns the stream of the value by key, so large values do not have to be held in memory. Does not return
+// expired by TTL items
+//
+// The caller has to close the stream
+func (c *Cache) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if cache.IsBypassed(ctx) {
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	return c.open(ctx, key)
+}
+
+// Exists reports whether a live entry by key is stored, reading object metadata only
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	if cache.IsBypassed(ctx) {
+		return false, nil
+	}
+
+	out, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.objectKey(key)),
+	})
+	if isNotFound(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	expired, err := expired(out.Metadata, time.Now())
+	return !expired, err
+}
+
+// GetOrFetch tries to obtain cached value from the bucket. If multiple callers are accessing the same key, later
+// callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to the cache. Panics of the fetcher are propagated to all the waiting callers.
+// Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) ([]byte, error),
+) ([]byte, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) ([]byte, error),
+) ([]byte, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) ([]byte, error),
+	ttl *time.Duration,
+) ([]byte, error) {
+	fetcher = cache.LimitFetch(c.fetchLimiter, fetcher)
+	fetcher = cache.RateLimitFetch(c.fetchRateLimiter, fetcher)
+
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.get(ctx, key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.get(ctx, key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err == nil {
+			err = c.put(ctx, key, bytes.NewReader(result), ttl)
+		}
+
+		return result, err
+	})
+}
+
+// Set puts the provided value by cache key to the bucket
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetWithTTL
+func (c *Cache) Set(ctx context.Context, key string, value []byte) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.put(ctx, key, bytes.NewReader(value), nil)
+}
+
+// SetReader puts the value read from body by cache key to the bucket, so large values do not have to be held in memory
+//
+// body is seekable, so the client can compute checksums and retry uploads. By default uses TTL value provided during
+// instantiation. If specific TTL is needed, use SetReaderWithTTL
+func (c *Cache) SetReader(ctx context.Context, key string, body io.ReadSeeker) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.put(ctx, key, body, nil)
+}
+
+// SetReaderWithTTL works as SetReader using provided ttl duration
+func (c *Cache) SetReaderWithTTL(ctx context.Context, key string, body io.ReadSeeker, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.put(ctx, key, body, &ttl)
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+//
+// Reads the objects one by one
+func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[[]byte], error) {
+	if cache.IsBypassed(ctx) {
+		return []cache.StorageItemMulti[[]byte]{}, nil
+	}
+
+	res := make([]cache.StorageItemMulti[[]byte], 0, len(keys))
+	for _, key := range keys {
+		val, err := c.get(ctx, key)
+		if isMiss(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		item := cache.StorageItemMulti[[]byte]{
+			Key:   key,
+			Value: val,
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to the bucket
+//
+// By default uses TTL value provided during instantiation. If specific TTL is needed, use SetMultiWithTTL
+func (c *Cache) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[[]byte]) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	for _, kv := range kvs {
+		if err := c.put(ctx, kv.Key, bytes.NewReader(kv.Value), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes cached value by key
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.delete(ctx, key)
+}
+
+// DeleteMulti removes cached values by keys with DeleteObjects, removing up to 1000 objects per call
+func (c *Cache) DeleteMulti(ctx context.Context, keys []string) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	var errs []error
+	for start := 0; start < len(keys); start += maxDeleteObjects {
+		batch := keys[start:min(start+maxDeleteObjects, len(keys))]
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(c.objectKey(key))})
+		}
+
+		out, err := c.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(c.bucket),
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, failed := range out.Errors {
+			errs = append(errs, fmt.Errorf("could not delete object %s: %s", aws.ToString(failed.Key),
+				aws.ToString(failed.Message)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+func (c *Cache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	return c.put(ctx, key, bytes.NewReader(value), &ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+func (c *Cache) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[[]byte], ttl time.Duration) error {
+	if cache.IsBypassed(ctx) {
+		return nil
+	}
+
+	for _, kv := range kvs {
+		if err := c.put(ctx, kv.Key, bytes.NewReader(kv.Value), &ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keys returns slice of stored keys
+//
+// The order of keys are not guaranteed. Lists all the objects under the prefix, expired but not yet removed ones
+// are included
+func (c *Cache) Keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(c.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key)[len(c.prefix):])
+		}
+	}
+
+	return keys, nil
+}
+
+func (c *Cache) get(ctx context.Context, key string) ([]byte, error) {
+	body, err := c.open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read object by key %s: %w", key, err)
+	}
+
+	return data, nil
+}
+
+func (c *Cache) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.objectKey(key)),
+	})
+	if isNotFound(err) {
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	expired, err := expired(out.Metadata, time.Now())
+	if err != nil || expired {
+		_ = out.Body.Close()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read expiration time of key %s: %w", key, err)
+	}
+
+	if expired {
+		_ = c.delete(ctx, key)
+		return nil, cache.NewMissingEntryError(key)
+	}
+
+	return out.Body, nil
+}
+
+func (c *Cache) put(ctx context.Context, key string, body io.Reader, ttl *time.Duration) error {
+	if ttl == nil {
+		ttl = c.defaultTTL
+	}
+
+	var metadata map[string]string
+	if ttl != nil && *ttl > 0 {
+		metadata = map[string]string{
+			expiresAtMeta: time.Now().Add(*ttl).UTC().Format(time.RFC3339Nano),
+		}
+	}
+
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(c.bucket),
+		Key:      aws.String(c.objectKey(key)),
+		Body:     body,
+		Metadata: metadata,
+	})
+
+	return err
+}
+
+func (c *Cache) delete(ctx context.Context, key string) error {
+	_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.objectKey(key)),
+	})
+
+	return err
+}
+
+func (c *Cache) objectKey(key string) string {
+	return c.prefix + key
+}
+
+// expired reports whether the expiration time in the object metadata has passed. Objects without it never expire
+func expired(metadata map[string]string, now time.Time) (bool, error) {
+	value, ok := metadata[expiresAtMeta]
+	if !ok {
+		return false, nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return false, err
+	}
+
+	return !now.Before(expiresAt), nil
+}
+
+// isNotFound reports whether the request failed as the object does not exist. Matches error codes rather than types,
+// so S3-compatible stores are supported
+func isNotFound(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "NoSuchKey", "NotFound":
+		return true
+	default:
+		return false
+	}
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
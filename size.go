@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+	"unsafe"
+)
+
+// CostFunc computes the approximate size of an entry in bytes
+type CostFunc[T any] func(key string, value T) int64
+
+// EstimateCost approximates the entry size as the key length plus the shallow size of the value
+//
+// Contents of string and []byte values are counted, other referenced memory (maps, slices, pointers) is not.
+// Use a custom CostFunc when values hold significant indirect data
+func EstimateCost[T any](key string, value T) int64 {
+	size := int64(len(key)) + int64(unsafe.Sizeof(value))
+
+	switch v := any(value).(type) {
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	}
+
+	return size
+}
+
+// SizeReporter is implemented by caches able to report their approximate memory usage
+type SizeReporter interface {
+	SizeBytes(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,36 @@
+package cache
+
+import "context"
+
+// Stats is a snapshot of cache counters accumulated since the cache was created
+type Stats struct {
+	// Hits is the number of reads that found the entry
+	Hits uint64
+	// Misses is the number of reads that did not find the entry
+	Misses uint64
+	// Sets is the number of written entries
+	Sets uint64
+	// Deletes is the number of entries removed by Delete calls or invalidations
+	Deletes uint64
+	// Evictions is the number of entries removed because of capacity
+	Evictions uint64
+	// Expired is the number of entries removed because of their TTL
+	Expired uint64
+	// Entries is the current number of entries, -1 if the cache can not report it
+	Entries int64
+}
+
+// HitRatio returns the share of reads that found the entry, 0 if there were no reads
+func (s Stats) HitRatio() float64 {
+	reads := s.Hits + s.Misses
+	if reads == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(reads)
+}
+
+// StatsProvider is implemented by caches able to report their Stats
+type StatsProvider interface {
+	Stats(ctx context.Context) (Stats, error)
+}
@@ -0,0 +1,523 @@
+// Package tiered provides a two-level cache composed of a fast local L1 and a shared L2
+//
+// Typical use: inmem or lru as L1, redis as L2
+package tiered
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/internal/invalidation"
+	"github.com/sinu5oid/cache/internal/singleflight"
+)
+
+// Cache reads from L1 first, falls back to L2 promoting found entries into L1, and writes through to both levels
+//
+// L2 is the source of truth: its errors are returned, while L1 failures are treated as misses on reads.
+// Safe for concurrent usage if both levels are
+type Cache[T any] struct {
+	l1 cache.TTLCacher[T]
+	l2 cache.TTLCacher[T]
+
+	l1TTL     *time.Duration
+	ttlPolicy TTLPolicy
+	policy    PromotionPolicy
+	guard     *guard
+
+	flights      singleflight.Group[T]
+	invalidation *invalidation.Subscription
+}
+
+// Option configures the tiered Cache
+type Option func(*options)
+
+type options struct {
+	l1TTL       *time.Duration
+	ttlPolicy   TTLPolicy
+	invalidator cache.Invalidator
+	policy      PromotionPolicy
+
+	readYourWrites bool
+}
+
+// WithL1TTL caps the TTL of entries written to L1, so local copies are refreshed from L2 at least that often
+//
+// Promotions use CapRemainingTTL(ttl) unless WithTTLPolicy is used
+func WithL1TTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.l1TTL = &ttl
+	}
+}
+
+// WithTTLPolicy assigns the policy deriving the L1 TTL of entries promoted from L2 from their remaining L2 TTL,
+// KeepRemainingTTL by default
+func WithTTLPolicy(policy TTLPolicy) Option {
+	return func(o *options) {
+		o.ttlPolicy = policy
+	}
+}
+
+// WithInvalidator subscribes L1 to the invalidator, e.g. redis.Invalidator shared by all the service instances
+//
+// Writes, deletes and clears made through the Cache are published, so other instances evict their L1 copies.
+// Invalidations published by others evict entries from L1, clears empty L1 if it has a Clear method
+func WithInvalidator(invalidator cache.Invalidator) Option {
+	return func(o *options) {
+		o.invalidator = invalidator
+	}
+}
+
+// WithPromotionPolicy assigns the policy deciding which L2 hits are promoted to L1 and which L1 evictions are
+// demoted to L2, PromoteAlways by default
+//
+// Writes made through the Cache always update both levels
+func WithPromotionPolicy(policy PromotionPolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithReadYourWrites makes writes made through the Cache visible to the following reads of the same process
+//
+// By default a read which found an older value in L2 may promote it to L1 after a concurrent write, so the write is
+// not observed until the L1 entry expires. In this mode writes, deletes and promotions of the same key are ordered and
+// promotions of values read before a write are skipped. If the L1 write fails, the keys are removed from L1. Writes
+// hold the order while both levels are written, delaying promotions of the keys sharing it
+func WithReadYourWrites() Option {
+	return func(o *options) {
+		o.readYourWrites = true
+	}
+}
+
+// NewTiered creates a Cache composed of provided levels
+//
+// The L1 TTL of entries promoted from L2 is derived from their remaining L2 TTL by the TTL policy, so L1 does not
+// outlive L2. L2 has to implement cache.TTLMultiGetter or cache.TTLGetter to report it, otherwise entries found in L2
+// are not promoted
+func NewTiered[T any](l1, l2 cache.TTLCacher[T], opts ...Option) *Cache[T] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Cache[T]{
+		l1:        l1,
+		l2:        l2,
+		l1TTL:     o.l1TTL,
+		ttlPolicy: o.ttlPolicy,
+		policy:    o.policy,
+	}
+
+	switch {
+	case c.ttlPolicy != nil:
+	case c.l1TTL != nil:
+		c.ttlPolicy = CapRemainingTTL(*c.l1TTL)
+	default:
+		c.ttlPolicy = KeepRemainingTTL()
+	}
+
+	if c.policy == nil {
+		c.policy = PromoteAlways()
+	}
+
+	if o.readYourWrites {
+		c.guard = newGuard()
+	}
+
+	if o.invalidator != nil {
+		c.invalidation = invalidation.Subscribe(o.invalidator, c.evict, c.clearL1)
+	}
+
+	return c
+}
+
+// Close removes the invalidator subscription, if any. The levels are not closed
+func (c *Cache[T]) Close() {
+	c.invalidation.Close()
+}
+
+// Clear empties the levels having a Clear method and publishes the clear to the invalidator
+func (c *Cache[T]) Clear(ctx context.Context) error {
+	if err := clearLevel(c.l2); err != nil {
+		return err
+	}
+
+	if err := clearLevel(c.l1); err != nil {
+		return err
+	}
+
+	return c.invalidation.PublishClear(ctx)
+}
+
+// Get retrieves an item from L1, falling back to L2
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if value, err := c.l1.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	items, err := c.getL2(ctx, []string{key})
+	if err != nil {
+		return *new(T), err
+	}
+
+	if len(items) == 0 {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return items[0].Value, nil
+}
+
+// Exists reports whether a live entry by key is stored in L1 or L2, see cache.Exists
+//
+// Unlike Get, entries found in L2 are not promoted to L1
+func (c *Cache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	if ok, err := cache.Exists(ctx, c.l1, key); err == nil && ok {
+		return true, nil
+	}
+
+	return cache.Exists(ctx, c.l2, key)
+}
+
+// GetOrFetch tries to obtain cached value from L1, then from L2. If multiple callers are accessing the same key,
+// later callers wait for the result or error of the first one, or until their context is done
+//
+// If the value was not found - calls provided fetcher function with the context of the first caller, detached from its
+// cancellation, saves received value to both levels. Honors cache.WithBypass and cache.WithForceRefresh context markers
+func (c *Cache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+func (c *Cache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetcher func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetcher, &ttl)
+}
+
+func (c *Cache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetcher func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if cache.IsBypassed(ctx) {
+		return fetcher(ctx)
+	}
+
+	force := cache.IsForceRefresh(ctx)
+	if !force {
+		result, err := c.Get(ctx, key)
+		if err == nil || !isMiss(err) {
+			return result, err
+		}
+	}
+
+	return c.flights.Do(ctx, key, func(ctx context.Context) (T, error) {
+		if !force {
+			// the value may have been stored by a call finished in the meantime
+			result, err := c.Get(ctx, key)
+			if err == nil || !isMiss(err) {
+				return result, err
+			}
+		}
+
+		result, err := fetcher(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		kvs := []cache.StorageItemMulti[T]{{Key: key, Value: result}}
+		if ttl != nil {
+			return result, c.SetMultiWithTTL(ctx, kvs, *ttl)
+		}
+
+		return result, c.SetMulti(ctx, kvs)
+	})
+}
+
+// Set puts the provided value by cache key to L2, then to L1
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.SetMulti(ctx, []cache.StorageItemMulti[T]{{Key: key, Value: value}})
+}
+
+// GetMulti returns cached values found in L1, reading the rest from L2.
+// Result slice follows the order of keys and may have fewer items than keys, it means that items by that key were
+// not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	found := make(map[string]T, len(keys))
+	if items, err := c.l1.GetMulti(ctx, keys); err == nil {
+		for _, item := range items {
+			found[item.Key] = item.Value
+		}
+	}
+
+	missing := make([]string, 0, len(keys)-len(found))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		items, err := c.getL2(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			found[item.Key] = item.Value
+		}
+	}
+
+	res := make([]cache.StorageItemMulti[T], 0, len(found))
+	for _, key := range keys {
+		value, ok := found[key]
+		if !ok {
+			continue
+		}
+
+		res = append(res, cache.StorageItemMulti[T]{
+			Key:   key,
+			Value: value,
+		})
+	}
+
+	return res, nil
+}
+
+// SetMulti puts provided k/v pairs to L2, then to L1
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	keys := keysOf(kvs)
+	defer c.guard.lock(keys, true)()
+
+	if err := c.l2.SetMulti(ctx, kvs); err != nil {
+		return err
+	}
+
+	var err error
+	if c.l1TTL != nil {
+		err = c.l1.SetMultiWithTTL(ctx, kvs, *c.l1TTL)
+	} else {
+		err = c.l1.SetMulti(ctx, kvs)
+	}
+
+	return errors.Join(c.dropFailed(ctx, keys, err), c.invalidation.Publish(ctx, keys...))
+}
+
+// Delete removes cached value by key from both levels
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	defer c.guard.lock([]string{key}, true)()
+
+	if err := errors.Join(c.l1.Delete(ctx, key), c.l2.Delete(ctx, key)); err != nil {
+		return err
+	}
+
+	return c.invalidation.Publish(ctx, key)
+}
+
+// DeleteMulti removes cached values by keys from both levels
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	defer c.guard.lock(keys, true)()
+
+	if err := errors.Join(c.l1.DeleteMulti(ctx, keys), c.l2.DeleteMulti(ctx, keys)); err != nil {
+		return err
+	}
+
+	return c.invalidation.Publish(ctx, keys...)
+}
+
+// SetWithTTL puts provided value by cache key to both levels using provided ttl duration
+//
+// The L1 TTL is capped by WithL1TTL
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	return c.SetMultiWithTTL(ctx, []cache.StorageItemMulti[T]{{Key: key, Value: value}}, ttl)
+}
+
+// SetMultiWithTTL puts provided k/v pairs to both levels using provided ttl duration
+//
+// The L1 TTL is capped by WithL1TTL
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	keys := keysOf(kvs)
+	defer c.guard.lock(keys, true)()
+
+	if err := c.l2.SetMultiWithTTL(ctx, kvs, ttl); err != nil {
+		return err
+	}
+
+	err := c.l1.SetMultiWithTTL(ctx, kvs, c.capTTL(ttl))
+	return errors.Join(c.dropFailed(ctx, keys, err), c.invalidation.Publish(ctx, keys...))
+}
+
+// dropFailed removes the keys from L1 in read-your-writes mode if writing them to L1 failed with err, so older values
+// are not served. Returns err joined with the removal error
+func (c *Cache[T]) dropFailed(ctx context.Context, keys []string, err error) error {
+	if err == nil || c.guard == nil {
+		return err
+	}
+
+	return errors.Join(err, c.l1.DeleteMulti(ctx, keys))
+}
+
+// evict removes the entry invalidated by another instance from L1
+func (c *Cache[T]) evict(key string) {
+	_ = c.l1.Delete(context.Background(), key)
+}
+
+// OnL1Evict demotes the entry removed from L1 to L2 if the promotion policy decides so and L2 no longer holds the
+// key, so values evicted from a small L1 stay available in L2
+//
+// Pass it to the L1 eviction notifications, e.g. inmem.Cache.OnEvict. The entry is written synchronously with the L2
+// default TTL, errors are ignored
+func (c *Cache[T]) OnL1Evict(key string, value T, reason cache.EvictionReason) {
+	if !c.policy.Demote(key, reason) {
+		return
+	}
+
+	ctx := context.Background()
+	if ok, err := cache.Exists(ctx, c.l2, key); err != nil || ok {
+		return
+	}
+
+	_ = c.l2.Set(ctx, key, value)
+}
+
+// clearL1 empties L1 cleared by another instance
+func (c *Cache[T]) clearL1() {
+	_ = clearLevel(c.l1)
+}
+
+// getL2 reads keys from L2 and promotes the found entries allowed by the policies into L1
+//
+// Entries are not promoted if L2 can not report their TTL. In read-your-writes mode entries of the keys written since
+// the read started are not promoted
+func (c *Cache[T]) getL2(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	versions := c.guard.versions(keys)
+
+	withTTL, reported, err := c.readL2(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]cache.StorageItemMulti[T], 0, len(withTTL))
+	promoted := make([]cache.StorageItemMultiWithTTL[T], 0, len(withTTL))
+	promotedKeys := make([]string, 0, len(withTTL))
+	for _, item := range withTTL {
+		kv := cache.StorageItemMulti[T]{Key: item.Key, Value: item.Value}
+		items = append(items, kv)
+
+		if !reported {
+			continue
+		}
+
+		ttl, ok := c.ttlPolicy.L1TTL(item.Key, item.TTL)
+		if ok && c.policy.Promote(ctx, kv.Key) {
+			item.TTL = ttl
+			promoted = append(promoted, item)
+			promotedKeys = append(promotedKeys, kv.Key)
+		}
+	}
+
+	defer c.guard.lock(promotedKeys, false)()
+
+	for _, item := range promoted {
+		if c.guard.written(item.Key, versions[item.Key]) {
+			continue
+		}
+
+		if item.TTL == cache.NoExpiration {
+			_ = c.l1.Set(ctx, item.Key, item.Value)
+			continue
+		}
+
+		_ = c.l1.SetWithTTL(ctx, item.Key, item.Value, item.TTL)
+	}
+
+	return items, nil
+}
+
+// readL2 reads keys from L2 along with their remaining TTL, reporting whether L2 is able to report it
+func (c *Cache[T]) readL2(ctx context.Context, keys []string) ([]cache.StorageItemMultiWithTTL[T], bool, error) {
+	switch l2 := c.l2.(type) {
+	case cache.TTLMultiGetter[T]:
+		items, err := l2.GetMultiWithTTL(ctx, keys)
+		return items, true, err
+	case cache.TTLGetter[T]:
+		items := make([]cache.StorageItemMultiWithTTL[T], 0, len(keys))
+		for _, key := range keys {
+			value, ttl, err := l2.GetWithTTL(ctx, key)
+			if isMiss(err) {
+				continue
+			}
+
+			if err != nil {
+				return nil, false, err
+			}
+
+			items = append(items, cache.StorageItemMultiWithTTL[T]{Key: key, Value: value, TTL: ttl})
+		}
+
+		return items, true, nil
+	default:
+		found, err := c.l2.GetMulti(ctx, keys)
+		if err != nil {
+			return nil, false, err
+		}
+
+		items := make([]cache.StorageItemMultiWithTTL[T], 0, len(found))
+		for _, item := range found {
+			items = append(items, cache.StorageItemMultiWithTTL[T]{Key: item.Key, Value: item.Value})
+		}
+
+		return items, false, nil
+	}
+}
+
+// capTTL limits ttl by the L1 TTL. cache.NoExpiration is kept if there is no limit
+func (c *Cache[T]) capTTL(ttl time.Duration) time.Duration {
+	if c.l1TTL == nil {
+		return ttl
+	}
+
+	if ttl == cache.NoExpiration || ttl <= 0 {
+		return *c.l1TTL
+	}
+
+	return min(ttl, *c.l1TTL)
+}
+
+// isMiss reports whether GetOrFetch has to fetch a value after the read failed with err
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}
+
+// keysOf returns the keys of kvs
+func keysOf[T any](kvs []cache.StorageItemMulti[T]) []string {
+	keys := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+
+	return keys
+}
+
+// clearLevel empties the level if it has a Clear method
+func clearLevel(level any) error {
+	switch l := level.(type) {
+	case interface{ Clear() }:
+		l.Clear()
+	case interface{ Clear() error }:
+		return l.Clear()
+	}
+
+	return nil
+}
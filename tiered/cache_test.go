@@ -0,0 +1,204 @@
+package tiered_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/inmem"
+	"github.com/sinu5oid/cache/tiered"
+)
+
+func TestPromoteAfterHits(t *testing.T) {
+	ctx := context.Background()
+	l1 := inmem.New[int]()
+	l2 := inmem.New[int]()
+	c := tiered.NewTiered[int](l1, l2, tiered.WithPromotionPolicy(tiered.PromoteAfterHits(2)))
+
+	if err := l2.Set(ctx, "key", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	for hit, promoted := range []bool{false, true} {
+		if got, err := c.Get(ctx, "key"); err != nil || got != 1 {
+			t.Fatalf("Get() = %v, %v, want 1", got, err)
+		}
+
+		if ok, _ := l1.Exists(ctx, "key"); ok != promoted {
+			t.Errorf("L1 holds the key after %d hits = %v, want %v", hit+1, ok, promoted)
+		}
+	}
+}
+
+func TestDemoteEvicted(t *testing.T) {
+	ctx := context.Background()
+	l1 := inmem.New[int](inmem.WithSize(1))
+	l2 := inmem.New[int]()
+	c := tiered.NewTiered[int](l1, l2, tiered.WithPromotionPolicy(tiered.DemoteEvicted(tiered.PromoteAlways())))
+	l1.OnEvict(c.OnL1Evict)
+
+	if err := l1.Set(ctx, "old", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := c.Set(ctx, "new", 2); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if got, err := l2.Get(ctx, "old"); err != nil || got != 1 {
+		t.Errorf("L2 Get() of the evicted entry = %v, %v, want 1", got, err)
+	}
+
+	if err := c.Delete(ctx, "new"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if ok, _ := cache.Exists(ctx, l2, "new"); ok {
+		t.Error("deleted entry was demoted to L2")
+	}
+}
+
+// pausedL2 holds the first GetMultiWithTTL call after reading from the level until release is closed
+type pausedL2 struct {
+	*inmem.Cache[int]
+
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (l *pausedL2) GetMultiWithTTL(ctx context.Context, keys []string) ([]cache.StorageItemMultiWithTTL[int], error) {
+	items, err := l.Cache.GetMultiWithTTL(ctx, keys)
+	if l.read != nil {
+		close(l.read)
+		l.read = nil
+		<-l.release
+	}
+
+	return items, err
+}
+
+func TestReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+	l1 := inmem.New[int]()
+	l2 := &pausedL2{
+		Cache:   inmem.New[int](),
+		read:    make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	c := tiered.NewTiered[int](l1, l2, tiered.WithReadYourWrites())
+
+	if err := l2.Cache.Set(ctx, "key", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	read := l2.read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = c.Get(ctx, "key") // finds the older value in L2
+	}()
+	<-read
+
+	if err := c.Set(ctx, "key", 2); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	close(l2.release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Get() did not finish")
+	}
+
+	if got, err := c.Get(ctx, "key"); err != nil || got != 2 {
+		t.Errorf("Get() after Set() = %v, %v, want 2", got, err)
+	}
+}
+
+// plainL2 hides the TTL reporting methods of the level
+type plainL2 struct {
+	cache.TTLCacher[int]
+}
+
+func TestTTLPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []tiered.Option
+		minTTL  time.Duration
+		maxTTL  time.Duration
+		promote bool
+	}{
+		{
+			name:    "remaining L2 TTL by default",
+			minTTL:  50 * time.Second,
+			maxTTL:  time.Minute,
+			promote: true,
+		},
+		{
+			name:    "capped by WithL1TTL",
+			opts:    []tiered.Option{tiered.WithL1TTL(10 * time.Second)},
+			minTTL:  5 * time.Second,
+			maxTTL:  10 * time.Second,
+			promote: true,
+		},
+		{
+			name:    "capped by policy",
+			opts:    []tiered.Option{tiered.WithTTLPolicy(tiered.CapRemainingTTL(10 * time.Second))},
+			minTTL:  5 * time.Second,
+			maxTTL:  10 * time.Second,
+			promote: true,
+		},
+		{
+			name: "skipped by policy",
+			opts: []tiered.Option{tiered.WithTTLPolicy(tiered.TTLPolicyFunc(
+				func(string, time.Duration) (time.Duration, bool) { return 0, false },
+			))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			l1 := inmem.New[int]()
+			l2 := inmem.New[int]()
+			c := tiered.NewTiered[int](l1, l2, tt.opts...)
+
+			if err := l2.SetWithTTL(ctx, "key", 1, time.Minute); err != nil {
+				t.Fatalf("SetWithTTL() error = %v", err)
+			}
+
+			if got, err := c.Get(ctx, "key"); err != nil || got != 1 {
+				t.Fatalf("Get() = %v, %v, want 1", got, err)
+			}
+
+			_, ttl, err := l1.GetWithTTL(ctx, "key")
+			if promoted := err == nil; promoted != tt.promote {
+				t.Fatalf("promoted = %v, want %v", promoted, tt.promote)
+			}
+
+			if tt.promote && (ttl < tt.minTTL || ttl > tt.maxTTL) {
+				t.Errorf("L1 TTL = %v, want within [%v, %v]", ttl, tt.minTTL, tt.maxTTL)
+			}
+		})
+	}
+}
+
+func TestUnknownL2TTLIsNotPromoted(t *testing.T) {
+	ctx := context.Background()
+	l1 := inmem.New[int]()
+	l2 := inmem.New[int]()
+	c := tiered.NewTiered[int](l1, plainL2{TTLCacher: l2}, tiered.WithL1TTL(time.Minute))
+
+	if err := l2.SetWithTTL(ctx, "key", 1, time.Second); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+
+	if got, err := c.Get(ctx, "key"); err != nil || got != 1 {
+		t.Fatalf("Get() = %v, %v, want 1", got, err)
+	}
+
+	if ok, _ := l1.Exists(ctx, "key"); ok {
+		t.Error("entry with unknown L2 TTL was promoted to L1")
+	}
+}
@@ -0,0 +1,89 @@
+package tiered
+
+import (
+	"hash/maphash"
+	"slices"
+	"sync"
+)
+
+// guardStripes is the number of stripes the read-your-writes guard spreads keys over
+const guardStripes = 256
+
+// guard orders writes and promotions of the same keys, so values read from L2 before a write are not promoted to L1
+// after it. Keys are spread over a fixed number of stripes, unrelated keys sharing a stripe only skip promotions
+//
+// A nil guard does not order anything
+type guard struct {
+	seed    maphash.Seed
+	stripes [guardStripes]stripe
+}
+
+type stripe struct {
+	mu      sync.Mutex
+	version uint64
+}
+
+func newGuard() *guard {
+	return &guard{seed: maphash.MakeSeed()}
+}
+
+// versions returns the versions of the key stripes, taken before reading the keys from L2
+func (g *guard) versions(keys []string) map[string]uint64 {
+	if g == nil {
+		return nil
+	}
+
+	res := make(map[string]uint64, len(keys))
+	for _, key := range keys {
+		s := g.stripe(key)
+
+		s.mu.Lock()
+		res[key] = s.version
+		s.mu.Unlock()
+	}
+
+	return res
+}
+
+// lock locks the stripes of keys in ascending order. The returned function unlocks them, advancing their versions if
+// written is set
+func (g *guard) lock(keys []string, written bool) (unlock func()) {
+	if g == nil {
+		return func() {}
+	}
+
+	indexes := make([]uint64, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, g.index(key))
+	}
+
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	for _, i := range indexes {
+		g.stripes[i].mu.Lock()
+	}
+
+	return func() {
+		for _, i := range indexes {
+			if written {
+				g.stripes[i].version++
+			}
+
+			g.stripes[i].mu.Unlock()
+		}
+	}
+}
+
+// written reports whether the key stripe was written since the version was taken. The stripe must be locked
+func (g *guard) written(key string, version uint64) bool {
+	return g != nil && g.stripe(key).version != version
+}
+
+func (g *guard) stripe(key string) *stripe {
+	return &g.stripes[g.index(key)]
+}
+
+func (g *guard) index(key string) uint64 {
+	return maphash.String(g.seed, key) % guardStripes
+}
@@ -0,0 +1,102 @@
+package tiered
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sinu5oid/cache"
+)
+
+// maxTrackedKeys limits the number of keys PromoteAfterHits counts hits for
+const maxTrackedKeys = 1 << 16
+
+// PromotionPolicy decides which entries move between the levels
+//
+// Implementations must be safe for concurrent usage
+type PromotionPolicy interface {
+	// Promote reports whether the entry by key found in L2 is written to L1
+	Promote(ctx context.Context, key string) bool
+	// Demote reports whether the entry by key removed from L1 for the reason is written back to L2
+	Demote(key string, reason cache.EvictionReason) bool
+}
+
+// PromoteAlways promotes every L2 hit and demotes nothing, the default policy
+func PromoteAlways() PromotionPolicy {
+	return promoteFunc(func(context.Context, string) bool { return true })
+}
+
+// PromoteAfterHits promotes entries found in L2 hits times, so rarely read keys do not take L1 space
+//
+// Hits are counted per process. Counts are forgotten once the key is promoted, or all at once when more than 65536
+// keys are tracked
+func PromoteAfterHits(hits int) PromotionPolicy {
+	return &hitsPolicy{
+		hits:   hits,
+		counts: make(map[string]int),
+	}
+}
+
+// PromoteBelowSize promotes entries while L1 reports using less than maxBytes, e.g. inmem or lru with a CostFunc
+//
+// Entries are not promoted if the size can not be obtained
+func PromoteBelowSize(l1 cache.SizeReporter, maxBytes int64) PromotionPolicy {
+	return promoteFunc(func(ctx context.Context, _ string) bool {
+		size, err := l1.SizeBytes(ctx)
+		return err == nil && size < maxBytes
+	})
+}
+
+// DemoteEvicted extends the policy to demote entries evicted from L1 because of capacity
+//
+// Demotion needs the L1 eviction notifications, see Cache.OnL1Evict
+func DemoteEvicted(policy PromotionPolicy) PromotionPolicy {
+	return demoteEvicted{PromotionPolicy: policy}
+}
+
+// promoteFunc is a policy deciding promotions by the function and demoting nothing
+type promoteFunc func(ctx context.Context, key string) bool
+
+func (f promoteFunc) Promote(ctx context.Context, key string) bool {
+	return f(ctx, key)
+}
+
+func (promoteFunc) Demote(string, cache.EvictionReason) bool {
+	return false
+}
+
+type hitsPolicy struct {
+	hits int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (p *hitsPolicy) Promote(_ context.Context, key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := p.counts[key] + 1
+	if count >= p.hits {
+		delete(p.counts, key)
+		return true
+	}
+
+	if len(p.counts) >= maxTrackedKeys {
+		clear(p.counts)
+	}
+
+	p.counts[key] = count
+	return false
+}
+
+func (*hitsPolicy) Demote(string, cache.EvictionReason) bool {
+	return false
+}
+
+type demoteEvicted struct {
+	PromotionPolicy
+}
+
+func (demoteEvicted) Demote(_ string, reason cache.EvictionReason) bool {
+	return reason == cache.ReasonEvicted
+}
@@ -0,0 +1,43 @@
+package tiered
+
+import (
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// TTLPolicy derives the L1 TTL of entries promoted from L2 from their remaining L2 TTL
+//
+// Implementations must be safe for concurrent usage
+type TTLPolicy interface {
+	// L1TTL returns the L1 TTL of the entry by key, remaining is cache.NoExpiration if the L2 entry never expires.
+	// The entry is not promoted if ok is false
+	L1TTL(key string, remaining time.Duration) (ttl time.Duration, ok bool)
+}
+
+// TTLPolicyFunc adapts a function to the TTLPolicy interface
+type TTLPolicyFunc func(key string, remaining time.Duration) (time.Duration, bool)
+
+// L1TTL calls f(key, remaining)
+func (f TTLPolicyFunc) L1TTL(key string, remaining time.Duration) (time.Duration, bool) {
+	return f(key, remaining)
+}
+
+// KeepRemainingTTL promotes entries with their remaining L2 TTL, the default policy without WithL1TTL
+func KeepRemainingTTL() TTLPolicy {
+	return TTLPolicyFunc(func(_ string, remaining time.Duration) (time.Duration, bool) {
+		return remaining, true
+	})
+}
+
+// CapRemainingTTL promotes entries with their remaining L2 TTL limited by limit, so local copies are refreshed from L2
+// at least that often. The default policy with WithL1TTL
+func CapRemainingTTL(limit time.Duration) TTLPolicy {
+	return TTLPolicyFunc(func(_ string, remaining time.Duration) (time.Duration, bool) {
+		if remaining == cache.NoExpiration || remaining <= 0 {
+			return limit, true
+		}
+
+		return min(remaining, limit), true
+	})
+}
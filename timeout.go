@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutCache bounds every operation of the wrapped cache with a timeout
+//
+// Operations not finished in time fail with TimeoutError, so callers can treat slow backends as misses. Deadlines of
+// the caller contexts are kept if they are earlier and exceeding them is reported as is. Fetchers are not bounded.
+// The timeout is applied through the context, so backends ignoring it are not interrupted.
+// Safe for concurrent usage if the wrapped cache is
+type TimeoutCache[T any] struct {
+	backend Cacher[T]
+	timeout time.Duration
+}
+
+// WithTimeout creates a TimeoutCache bounding operations of the cache by d
+func WithTimeout[T any](c Cacher[T], d time.Duration) *TimeoutCache[T] {
+	return &TimeoutCache[T]{
+		backend: c,
+		timeout: d,
+	}
+}
+
+// Unwrap returns the wrapped cache
+func (c *TimeoutCache[T]) Unwrap() Cacher[T] {
+	return c.backend
+}
+
+// Get retrieves an item by key
+func (c *TimeoutCache[T]) Get(ctx context.Context, key string) (T, error) {
+	var value T
+	err := c.do(ctx, "get", func(ctx context.Context) (err error) {
+		value, err = c.backend.Get(ctx, key)
+		return err
+	})
+
+	return value, err
+}
+
+// Set puts the provided value by key
+func (c *TimeoutCache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.do(ctx, "set", func(ctx context.Context) error {
+		return c.backend.Set(ctx, key, value)
+	})
+}
+
+// GetMulti returns cached values by provided keys.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *TimeoutCache[T]) GetMulti(ctx context.Context, keys []string) ([]StorageItemMulti[T], error) {
+	var items []StorageItemMulti[T]
+	err := c.do(ctx, "get multi", func(ctx context.Context) (err error) {
+		items, err = c.backend.GetMulti(ctx, keys)
+		return err
+	})
+
+	return items, err
+}
+
+// SetMulti puts provided k/v pairs
+func (c *TimeoutCache[T]) SetMulti(ctx context.Context, kvs []StorageItemMulti[T]) error {
+	return c.do(ctx, "set multi", func(ctx context.Context) error {
+		return c.backend.SetMulti(ctx, kvs)
+	})
+}
+
+// Delete removes cached value by key
+func (c *TimeoutCache[T]) Delete(ctx context.Context, key string) error {
+	return c.do(ctx, "delete", func(ctx context.Context) error {
+		return c.backend.Delete(ctx, key)
+	})
+}
+
+// DeleteMulti removes cached values by keys
+func (c *TimeoutCache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.do(ctx, "delete multi", func(ctx context.Context) error {
+		return c.backend.DeleteMulti(ctx, keys)
+	})
+}
+
+// SetWithTTL puts provided value by key using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement TTLCacher
+func (c *TimeoutCache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return c.do(ctx, "set with TTL", func(ctx context.Context) error {
+		return ttlCacher.SetWithTTL(ctx, key, value, ttl)
+	})
+}
+
+// SetMultiWithTTL puts provided k/v pairs using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement TTLCacher
+func (c *TimeoutCache[T]) SetMultiWithTTL(ctx context.Context, kvs []StorageItemMulti[T], ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(TTLCacher[T])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	return c.do(ctx, "set multi with TTL", func(ctx context.Context) error {
+		return ttlCacher.SetMultiWithTTL(ctx, kvs, ttl)
+	})
+}
+
+// GetOrFetch obtains the value by key with Get, calling the fetcher and storing its result with Set if the value was
+// not found or the read timed out
+//
+// The fetcher runs with the caller context, only the cache calls are bounded
+func (c *TimeoutCache[T]) GetOrFetch(
+	ctx context.Context,
+	key string,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetch, nil)
+}
+
+// GetOrFetchWithTTL works as GetOrFetch, storing the fetched value using provided ttl duration
+//
+// Returns errors.ErrUnsupported if the wrapped cache does not implement TTLCacher
+func (c *TimeoutCache[T]) GetOrFetchWithTTL(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetch func(ctx context.Context) (T, error),
+) (T, error) {
+	return c.getOrFetch(ctx, key, fetch, &ttl)
+}
+
+func (c *TimeoutCache[T]) getOrFetch(
+	ctx context.Context,
+	key string,
+	fetch func(ctx context.Context) (T, error),
+	ttl *time.Duration,
+) (T, error) {
+	if IsBypassed(ctx) {
+		return fetch(ctx)
+	}
+
+	if !IsForceRefresh(ctx) {
+		value, err := c.Get(ctx, key)
+		var (
+			missingEntryError MissingEntryError
+			timeoutError      TimeoutError
+		)
+		if !errors.As(err, &missingEntryError) && !errors.As(err, &timeoutError) {
+			return value, err
+		}
+	}
+
+	value, err := fetch(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	if ttl != nil {
+		return value, c.SetWithTTL(ctx, key, value, *ttl)
+	}
+
+	return value, c.Set(ctx, key, value)
+}
+
+// do runs fn with a context bounded by the timeout, converting exceeding it into TimeoutError
+func (c *TimeoutCache[T]) do(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	bounded, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	err := fn(bounded)
+	if err != nil && ctx.Err() == nil && bounded.Err() != nil {
+		return NewTimeoutError(op, c.timeout)
+	}
+
+	return err
+}
@@ -0,0 +1,18 @@
+package cache
+
+import "time"
+
+// TTLPolicy computes the TTL of an entry from its value, e.g. to expire pending results sooner than completed ones
+//
+// Applies to writes without explicit TTL. Non-positive durations fall back to the cache default TTL
+type TTLPolicy[T any] interface {
+	TTL(key string, value T) time.Duration
+}
+
+// TTLPolicyFunc adapts a function to the TTLPolicy interface
+type TTLPolicyFunc[T any] func(key string, value T) time.Duration
+
+// TTL calls f(key, value)
+func (f TTLPolicyFunc[T]) TTL(key string, value T) time.Duration {
+	return f(key, value)
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Typed adapts a byte-oriented cache into Cacher[T], converting values with the codec
+//
+// Lets backends storing bytes, e.g. bigcache, be reused for any value type. Values failing to decode are reported as
+// FailedToCastEntryError and skipped by GetMulti. TTL writes and key listing are passed through, returning
+// errors.ErrUnsupported if the wrapped cache does not implement TTLCacher or KeyLister
+func Typed[T any](raw Cacher[[]byte], codec Codec[T]) Cacher[T] {
+	return &typedCache[T]{
+		backend: raw,
+		codec:   codec,
+	}
+}
+
+type typedCache[T any] struct {
+	backend Cacher[[]byte]
+	codec   Codec[T]
+}
+
+func (c *typedCache[T]) Unwrap() Cacher[[]byte] {
+	return c.backend
+}
+
+func (c *typedCache[T]) Get(ctx context.Context, key string) (T, error) {
+	data, err := c.backend.Get(ctx, key)
+	if err != nil {
+		return *new(T), err
+	}
+
+	return c.decode(key, data)
+}
+
+func (c *typedCache[T]) Set(ctx context.Context, key string, value T) error {
+	data, err := c.encode(key, value)
+	if err != nil {
+		return err
+	}
+
+	return c.backend.Set(ctx, key, data)
+}
+
+func (c *typedCache[T]) GetMulti(ctx context.Context, keys []string) ([]StorageItemMulti[T], error) {
+	items, err := c.backend.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]StorageItemMulti[T], 0, len(items))
+	for _, item := range items {
+		value, err := c.decode(item.Key, item.Value)
+		if err != nil {
+			continue
+		}
+
+		res = append(res, StorageItemMulti[T]{
+			Key:   item.Key,
+			Value: value,
+		})
+	}
+
+	return res, nil
+}
+
+func (c *typedCache[T]) SetMulti(ctx context.Context, kvs []StorageItemMulti[T]) error {
+	items, err := c.encodeMulti(kvs)
+	if err != nil {
+		return err
+	}
+
+	return c.backend.SetMulti(ctx, items)
+}
+
+func (c *typedCache[T]) Exists(ctx context.Context, key string) (bool, error) {
+	return Exists(ctx, c.backend, key)
+}
+
+func (c *typedCache[T]) Delete(ctx context.Context, key string) error {
+	return c.backend.Delete(ctx, key)
+}
+
+func (c *typedCache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.backend.DeleteMulti(ctx, keys)
+}
+
+func (c *typedCache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(TTLCacher[[]byte])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	data, err := c.encode(key, value)
+	if err != nil {
+		return err
+	}
+
+	return ttlCacher.SetWithTTL(ctx, key, data, ttl)
+}
+
+func (c *typedCache[T]) SetMultiWithTTL(ctx context.Context, kvs []StorageItemMulti[T], ttl time.Duration) error {
+	ttlCacher, ok := c.backend.(TTLCacher[[]byte])
+	if !ok {
+		return fmt.Errorf("cache does not support TTL: %w", errors.ErrUnsupported)
+	}
+
+	items, err := c.encodeMulti(kvs)
+	if err != nil {
+		return err
+	}
+
+	return ttlCacher.SetMultiWithTTL(ctx, items, ttl)
+}
+
+func (c *typedCache[T]) Keys(ctx context.Context) ([]string, error) {
+	lister, ok := c.backend.(KeyLister)
+	if !ok {
+		return nil, fmt.Errorf("cache does not list keys: %w", errors.ErrUnsupported)
+	}
+
+	return lister.Keys(ctx)
+}
+
+func (c *typedCache[T]) encode(key string, value T) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode value for key %s: %w", key, err)
+	}
+
+	return data, nil
+}
+
+func (c *typedCache[T]) encodeMulti(kvs []StorageItemMulti[T]) ([]StorageItemMulti[[]byte], error) {
+	items := make([]StorageItemMulti[[]byte], 0, len(kvs))
+	for _, kv := range kvs {
+		data, err := c.encode(kv.Key, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, StorageItemMulti[[]byte]{
+			Key:   kv.Key,
+			Value: data,
+		})
+	}
+
+	return items, nil
+}
+
+func (c *typedCache[T]) decode(key string, data []byte) (T, error) {
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		return *new(T), NewFailedToCastEntryError(key, err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,146 @@
+// Package warmup preloads caches at startup, so the first requests do not hit the origin
+package warmup
+
+import (
+	"context"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// defaultBatchSize is the number of entries written with a single SetMulti call unless configured with WithBatchSize
+const defaultBatchSize = 100
+
+// Option configures Warm
+type Option func(*options)
+
+type options struct {
+	batchSize   int
+	concurrency int
+	ttl         *time.Duration
+	progress    func(cache.LoadProgress)
+	interval    time.Duration
+	onError     func(err error)
+}
+
+// WithBatchSize assigns the number of entries written with a single SetMulti call, 100 by default
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.batchSize = size
+	}
+}
+
+// WithConcurrency assigns the number of batches written simultaneously, 1 by default
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithTTL assigns the TTL of loaded entries instead of the cache default TTL
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = &ttl
+	}
+}
+
+// WithProgress assigns the function called after every written batch with the progress of the current load
+func WithProgress(fn func(cache.LoadProgress)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// WithInterval repeats the load every interval in background after the initial one, until the Warm context is done
+func WithInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithErrorHandler assigns the function receiving errors of the repeated loads, see WithInterval
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// Warm calls the loader and writes the entries it returns to the cache in batches, see cache.LoadAll
+//
+// Returns once the initial load is finished, with its progress and the first loader or cache error. Repeated loads
+// configured with WithInterval are started only if the initial one succeeds
+func Warm[T any](
+	ctx context.Context,
+	c cache.TTLCacher[T],
+	loader func(ctx context.Context) ([]cache.StorageItemMulti[T], error),
+	opts ...Option,
+) (cache.LoadProgress, error) {
+	o := options{batchSize: defaultBatchSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	progress, err := load(ctx, c, loader, o)
+	if err != nil {
+		return progress, err
+	}
+
+	if o.interval > 0 {
+		go repeat(ctx, c, loader, o)
+	}
+
+	return progress, nil
+}
+
+func repeat[T any](
+	ctx context.Context,
+	c cache.TTLCacher[T],
+	loader func(ctx context.Context) ([]cache.StorageItemMulti[T], error),
+	o options,
+) {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := load(ctx, c, loader, o); err != nil && ctx.Err() == nil && o.onError != nil {
+			o.onError(err)
+		}
+	}
+}
+
+func load[T any](
+	ctx context.Context,
+	c cache.TTLCacher[T],
+	loader func(ctx context.Context) ([]cache.StorageItemMulti[T], error),
+	o options,
+) (cache.LoadProgress, error) {
+	items, err := loader(ctx)
+	if err != nil {
+		return cache.LoadProgress{}, err
+	}
+
+	batches := slices.Collect(slices.Chunk(items, max(o.batchSize, 1)))
+	next := func(context.Context) ([]cache.StorageItemMulti[T], error) {
+		if len(batches) == 0 {
+			return nil, io.EOF
+		}
+
+		batch := batches[0]
+		batches = batches[1:]
+		return batch, nil
+	}
+
+	return cache.LoadAll[T](ctx, c, cache.BulkLoaderFunc[T](next), cache.LoadOptions{
+		Concurrency: o.concurrency,
+		TTL:         o.ttl,
+		Progress:    o.progress,
+	})
+}
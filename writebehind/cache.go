@@ -0,0 +1,551 @@
+// Package writebehind provides a cache wrapper acknowledging writes immediately and flushing them to the backend in
+// background
+//
+// Trades durability for write latency: buffered writes are lost if the process dies before they are flushed
+package writebehind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+const (
+	defaultCapacity  = 10000
+	defaultBatchSize = 100
+	defaultInterval  = 100 * time.Millisecond
+)
+
+// ErrQueueFull is returned by writes rejected because the buffer is full, see Reject
+var ErrQueueFull = errors.New("write-behind queue is full")
+
+// ErrClosed is returned by writes made after Close
+var ErrClosed = errors.New("write-behind cache is closed")
+
+// OverflowPolicy decides what happens to writes arriving while the buffer is full
+type OverflowPolicy int
+
+const (
+	// Block waits for room in the buffer until the context is done. The default policy
+	Block OverflowPolicy = iota
+	// Reject fails the write with ErrQueueFull
+	Reject
+	// WriteThrough writes to the backend synchronously, bypassing the buffer
+	WriteThrough
+)
+
+// String returns the policy name
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case Reject:
+		return "reject"
+	case WriteThrough:
+		return "write-through"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// write is a buffered write, the latest one by key
+type write[T any] struct {
+	value  T
+	ttl    *time.Duration
+	delete bool
+}
+
+// Cache wraps a cache.TTLCacher buffering writes and deletes, flushing them in batches from a pool of workers
+//
+// Writes by the same key are coalesced, only the latest one is flushed, and a key is never flushed by two workers
+// at once, so the backend sees writes of a key in order. Reads see buffered writes. Batches are flushed once they
+// are full or every interval. Failed batches are dropped and reported to the error handler, wrap the backend with
+// retry.Cache to retry them. Safe for concurrent usage
+type Cache[T any] struct {
+	backend cache.TTLCacher[T]
+
+	capacity  int
+	batchSize int
+	interval  time.Duration
+	workers   int
+	overflow  OverflowPolicy
+	onError   func(err error)
+
+	mu       sync.Mutex
+	pending  map[string]write[T]
+	order    []string // pending keys, oldest first
+	inflight map[string]write[T]
+	changed  chan struct{} // closed and replaced every time a batch is flushed
+	closed   bool
+
+	wake      chan struct{}
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// Wrap creates a Cache buffering up to 10000 keys, flushing batches of up to 100 keys every 100ms with a single worker
+//
+// Workers are started by the first write
+func Wrap[T any](backend cache.TTLCacher[T]) *Cache[T] {
+	return &Cache[T]{
+		backend:   backend,
+		capacity:  defaultCapacity,
+		batchSize: defaultBatchSize,
+		interval:  defaultInterval,
+		workers:   1,
+		pending:   make(map[string]write[T]),
+		inflight:  make(map[string]write[T]),
+		changed:   make(chan struct{}),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+}
+
+// WithCapacity assigns the maximal number of buffered keys
+func (c *Cache[T]) WithCapacity(capacity int) *Cache[T] {
+	c.capacity = max(capacity, 1)
+	return c
+}
+
+// WithBatchSize assigns the maximal number of keys flushed with a single backend call
+func (c *Cache[T]) WithBatchSize(size int) *Cache[T] {
+	c.batchSize = max(size, 1)
+	return c
+}
+
+// WithFlushInterval assigns the period buffered writes are flushed with even if they do not fill a batch
+func (c *Cache[T]) WithFlushInterval(interval time.Duration) *Cache[T] {
+	c.interval = interval
+	return c
+}
+
+// WithWorkers assigns the number of batches flushed simultaneously
+func (c *Cache[T]) WithWorkers(n int) *Cache[T] {
+	c.workers = max(n, 1)
+	return c
+}
+
+// WithOverflowPolicy assigns the policy applied to writes arriving while the buffer is full, Block by default
+func (c *Cache[T]) WithOverflowPolicy(policy OverflowPolicy) *Cache[T] {
+	c.overflow = policy
+	return c
+}
+
+// WithErrorHandler assigns the function receiving errors of failed flushes
+func (c *Cache[T]) WithErrorHandler(fn func(err error)) *Cache[T] {
+	c.onError = fn
+	return c
+}
+
+// Unwrap returns the wrapped cache
+func (c *Cache[T]) Unwrap() cache.Cacher[T] {
+	return c.backend
+}
+
+// Get retrieves an item by key, a buffered one first
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	if w, ok := c.buffered(key); ok {
+		if w.delete {
+			return *new(T), cache.NewMissingEntryError(key)
+		}
+
+		return w.value, nil
+	}
+
+	return c.backend.Get(ctx, key)
+}
+
+// GetMulti returns cached values by provided keys, buffered ones first.
+// Result slice may have fewer items than keys, it means that items by that key were not found
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	res := make([]cache.StorageItemMulti[T], 0, len(keys))
+	rest := make([]string, 0, len(keys))
+	for _, key := range keys {
+		w, ok := c.buffered(key)
+		switch {
+		case !ok:
+			rest = append(rest, key)
+		case !w.delete:
+			res = append(res, cache.StorageItemMulti[T]{Key: key, Value: w.value})
+		}
+	}
+
+	if len(rest) == 0 {
+		return res, nil
+	}
+
+	items, err := c.backend.GetMulti(ctx, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(res, items...), nil
+}
+
+// Set buffers the value by key
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.enqueue(ctx, []cache.StorageItemMulti[T]{{Key: key, Value: value}}, nil, false)
+}
+
+// SetMulti buffers provided k/v pairs
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	return c.enqueue(ctx, kvs, nil, false)
+}
+
+// SetWithTTL buffers the value by key, to be written using provided ttl duration
+//
+// The TTL starts once the value is flushed
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	return c.enqueue(ctx, []cache.StorageItemMulti[T]{{Key: key, Value: value}}, &ttl, false)
+}
+
+// SetMultiWithTTL buffers provided k/v pairs, to be written using provided ttl duration
+//
+// The TTL starts once the values are flushed
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	return c.enqueue(ctx, kvs, &ttl, false)
+}
+
+// Delete buffers the removal of the value by key
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	return c.enqueue(ctx, []cache.StorageItemMulti[T]{{Key: key}}, nil, true)
+}
+
+// DeleteMulti buffers the removal of the values by keys
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	kvs := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, cache.StorageItemMulti[T]{Key: key})
+	}
+
+	return c.enqueue(ctx, kvs, nil, true)
+}
+
+// Flush waits until the writes buffered so far and the ones arriving meanwhile are flushed, or the context is done
+func (c *Cache[T]) Flush(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if len(c.pending) == 0 && len(c.inflight) == 0 {
+			c.mu.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		c.kick()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close rejects further writes with ErrClosed, flushes the buffered ones and stops the workers
+//
+// The backend is not closed
+func (c *Cache[T]) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+
+		err = c.Flush(context.Background())
+
+		close(c.stop)
+		c.wg.Wait()
+	})
+
+	return err
+}
+
+func (c *Cache[T]) buffered(key string) (write[T], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if w, ok := c.pending[key]; ok {
+		return w, true
+	}
+
+	w, ok := c.inflight[key]
+	return w, ok
+}
+
+// enqueue buffers the writes, applying the overflow policy if they do not fit
+func (c *Cache[T]) enqueue(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl *time.Duration, del bool) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	c.startOnce.Do(c.start)
+
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return ErrClosed
+		}
+
+		if c.fits(kvs) {
+			for _, kv := range kvs {
+				if _, ok := c.pending[kv.Key]; !ok {
+					c.order = append(c.order, kv.Key)
+				}
+				c.pending[kv.Key] = write[T]{value: kv.Value, ttl: ttl, delete: del}
+			}
+			full := len(c.pending) >= c.batchSize
+			c.mu.Unlock()
+
+			if full {
+				c.kick()
+			}
+			return nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		switch c.overflow {
+		case Reject:
+			return ErrQueueFull
+		case WriteThrough:
+			return c.writeThrough(ctx, kvs, ttl, del)
+		}
+
+		c.kick()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fits reports whether the buffer has room for the keys not buffered yet. Batches larger than the capacity fit an
+// empty buffer, so they do not wait forever
+func (c *Cache[T]) fits(kvs []cache.StorageItemMulti[T]) bool {
+	added := 0
+	for _, kv := range kvs {
+		if _, ok := c.pending[kv.Key]; !ok {
+			added++
+		}
+	}
+
+	return added == 0 || len(c.pending)+added <= c.capacity || len(c.pending) == 0
+}
+
+// writeThrough writes to the backend directly, dropping buffered writes by the same keys as they are older
+//
+// Waits for in-flight flushes of the keys first, then keeps the keys in flight until written, so workers do not write
+// them meanwhile and the backend sees writes of a key in order
+func (c *Cache[T]) writeThrough(
+	ctx context.Context,
+	kvs []cache.StorageItemMulti[T],
+	ttl *time.Duration,
+	del bool,
+) error {
+	if err := c.claim(ctx, kvs, ttl, del); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+
+	var err error
+	switch {
+	case del:
+		err = c.backend.DeleteMulti(ctx, keys)
+	case ttl != nil:
+		err = c.backend.SetMultiWithTTL(ctx, kvs, *ttl)
+	default:
+		err = c.backend.SetMulti(ctx, kvs)
+	}
+
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.inflight, key)
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.mu.Unlock()
+
+	return err
+}
+
+// claim waits until none of the keys are in flight, then moves them to the in-flight set with the provided writes,
+// dropping their buffered writes
+func (c *Cache[T]) claim(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl *time.Duration, del bool) error {
+	for {
+		c.mu.Lock()
+		busy := slices.ContainsFunc(kvs, func(kv cache.StorageItemMulti[T]) bool {
+			_, ok := c.inflight[kv.Key]
+			return ok
+		})
+		if !busy {
+			for _, kv := range kvs {
+				delete(c.pending, kv.Key)
+				c.inflight[kv.Key] = write[T]{value: kv.Value, ttl: ttl, delete: del}
+			}
+			c.mu.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Cache[T]) start() {
+	for range c.workers {
+		c.wg.Add(1)
+		go c.work()
+	}
+}
+
+func (c *Cache[T]) kick() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Cache[T]) work() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.wake:
+		case <-ticker.C:
+		}
+
+		for c.flushBatch() {
+		}
+	}
+}
+
+// flushBatch writes a batch of buffered keys not flushed by other workers. Reports whether a batch was flushed
+func (c *Cache[T]) flushBatch() bool {
+	batch := c.take()
+	if len(batch) == 0 {
+		return false
+	}
+
+	if c.pendingLen() > 0 {
+		// let other workers take the rest meanwhile
+		c.kick()
+	}
+
+	err := c.write(batch)
+
+	c.mu.Lock()
+	for key := range batch {
+		delete(c.inflight, key)
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.mu.Unlock()
+
+	if err != nil && c.onError != nil {
+		c.onError(err)
+	}
+
+	return true
+}
+
+// take moves up to a batch of the oldest buffered keys not in flight to the in-flight set
+func (c *Cache[T]) take() map[string]write[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batch := make(map[string]write[T], min(c.batchSize, len(c.pending)))
+	kept := c.order[:0]
+	for _, key := range c.order {
+		w, ok := c.pending[key]
+		if !ok {
+			continue // written through meanwhile
+		}
+
+		if _, busy := c.inflight[key]; busy || len(batch) >= c.batchSize {
+			kept = append(kept, key)
+			continue
+		}
+
+		batch[key] = w
+		c.inflight[key] = w
+		delete(c.pending, key)
+	}
+	c.order = kept
+
+	return batch
+}
+
+func (c *Cache[T]) pendingLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.pending)
+}
+
+// write flushes the batch, grouping its writes by kind and TTL
+func (c *Cache[T]) write(batch map[string]write[T]) error {
+	ctx := context.Background()
+
+	var (
+		deletes []string
+		sets    = make(map[time.Duration][]cache.StorageItemMulti[T])
+		plain   []cache.StorageItemMulti[T]
+	)
+	for key, w := range batch {
+		kv := cache.StorageItemMulti[T]{Key: key, Value: w.value}
+		switch {
+		case w.delete:
+			deletes = append(deletes, key)
+		case w.ttl != nil:
+			sets[*w.ttl] = append(sets[*w.ttl], kv)
+		default:
+			plain = append(plain, kv)
+		}
+	}
+
+	var errs []error
+	if len(deletes) > 0 {
+		if err := c.backend.DeleteMulti(ctx, deletes); err != nil {
+			errs = append(errs, fmt.Errorf("could not flush deletes of %d keys: %w", len(deletes), err))
+		}
+	}
+
+	if len(plain) > 0 {
+		if err := c.backend.SetMulti(ctx, plain); err != nil {
+			errs = append(errs, fmt.Errorf("could not flush writes of %d keys: %w", len(plain), err))
+		}
+	}
+
+	for ttl, kvs := range sets {
+		if err := c.backend.SetMultiWithTTL(ctx, kvs, ttl); err != nil {
+			errs = append(errs, fmt.Errorf("could not flush writes of %d keys: %w", len(kvs), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
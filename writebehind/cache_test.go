@@ -0,0 +1,86 @@
+package writebehind
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sinu5oid/cache"
+	"github.com/sinu5oid/cache/inmem"
+)
+
+// blockingBackend holds the first SetMulti call until release is closed
+type blockingBackend struct {
+	cache.TTLCacher[int]
+
+	calls   atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[int]) error {
+	if b.calls.Add(1) == 1 {
+		close(b.entered)
+		<-b.release
+	}
+
+	return b.TTLCacher.SetMulti(ctx, kvs)
+}
+
+func TestWriteThroughWaitsForInflightFlush(t *testing.T) {
+	ctx := context.Background()
+	backend := &blockingBackend{
+		TTLCacher: inmem.NewCache[int](),
+		entered:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+	c := Wrap[int](backend).
+		WithCapacity(1).
+		WithBatchSize(1).
+		WithFlushInterval(time.Millisecond).
+		WithOverflowPolicy(WriteThrough)
+	release := sync.OnceFunc(func() { close(backend.release) })
+	defer func() {
+		release()
+		_ = c.Close()
+	}()
+
+	if err := c.Set(ctx, "key", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	<-backend.entered // the older value is in flight
+
+	if err := c.Set(ctx, "other", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Set(ctx, "key", 2) // the buffer is full, written through
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("write-through finished before the in-flight flush, error = %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if got, err := c.Get(ctx, "key"); err != nil || got != 2 {
+		t.Errorf("Get() = %v, %v, want 2", got, err)
+	}
+
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if got, err := backend.Get(ctx, "key"); err != nil || got != 2 {
+		t.Errorf("backend Get() = %v, %v, want 2", got, err)
+	}
+}
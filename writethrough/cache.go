@@ -0,0 +1,253 @@
+// Package writethrough provides a cache facade over a store being the source of truth
+//
+// Writes go to the store first, then to the cache, reads are served from the cache, loading missing values from the
+// store
+package writethrough
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sinu5oid/cache"
+)
+
+// Store is the source of truth, e.g. a database table
+//
+// Load returns cache.ErrNotFound if there is no value by the key. Delete of a missing key must succeed
+type Store[T any] interface {
+	Load(ctx context.Context, key string) (T, error)
+	Save(ctx context.Context, key string, value T) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MultiLoader is implemented by stores able to load multiple values with a single call
+//
+// The result may have fewer items than keys, it means that there are no values by the missing keys
+type MultiLoader[T any] interface {
+	LoadMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error)
+}
+
+// Cache writes through to the store and reads through the cache
+//
+// Values missing in the store are reported as cache.MissingEntryError. If the cache write fails after the store was
+// written, the cache entry is deleted, so the previous value is not served, and the error is returned.
+// Safe for concurrent usage if both the store and the cache are
+type Cache[T any] struct {
+	store Store[T]
+	cache cache.TTLCacher[T]
+	ttl   *time.Duration
+}
+
+// New creates a Cache over the store and the cache
+func New[T any](store Store[T], c cache.TTLCacher[T]) *Cache[T] {
+	return &Cache[T]{
+		store: store,
+		cache: c,
+	}
+}
+
+// WithTTL assigns the TTL of the values written to the cache by Set, SetMulti and reads, the cache default TTL is used
+// otherwise
+func (c *Cache[T]) WithTTL(ttl time.Duration) *Cache[T] {
+	c.ttl = &ttl
+	return c
+}
+
+// Unwrap returns the cache
+func (c *Cache[T]) Unwrap() cache.Cacher[T] {
+	return c.cache
+}
+
+// Get retrieves the value by key from the cache, loading it from the store and caching it if it is missing
+//
+// Uses the cache GetOrFetch if it implements cache.FetchingCacher, so concurrent loads of a key are deduplicated
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	value, err := c.get(ctx, key)
+	if errors.Is(err, cache.ErrNotFound) {
+		return *new(T), cache.NewMissingEntryError(key)
+	}
+
+	return value, err
+}
+
+func (c *Cache[T]) get(ctx context.Context, key string) (T, error) {
+	if fetching, ok := c.cache.(cache.FetchingCacher[T]); ok {
+		if c.ttl != nil {
+			return fetching.GetOrFetchWithTTL(ctx, key, *c.ttl, c.load(key))
+		}
+
+		return fetching.GetOrFetch(ctx, key, c.load(key))
+	}
+
+	value, err := c.cache.Get(ctx, key)
+	if !isMiss(err) {
+		return value, err
+	}
+
+	value, err = c.store.Load(ctx, key)
+	if err != nil {
+		return value, err
+	}
+
+	return value, c.setCache(ctx, []cache.StorageItemMulti[T]{{Key: key, Value: value}}, c.ttl)
+}
+
+// Set saves the value by key to the store, then puts it to the cache
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.SetMulti(ctx, []cache.StorageItemMulti[T]{{Key: key, Value: value}})
+}
+
+// SetWithTTL saves the value by key to the store, then puts it to the cache using provided ttl duration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	return c.SetMultiWithTTL(ctx, []cache.StorageItemMulti[T]{{Key: key, Value: value}}, ttl)
+}
+
+// GetMulti returns the values found in the cache, loading the rest from the store and caching them.
+// Result slice may have fewer items than keys, it means that there are no values by that key in the store
+//
+// Missing values are loaded with a single call if the store implements MultiLoader, otherwise one by one
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	items, err := c.cache.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	found := cache.AsMap(items)
+	missing := make([]string, 0, len(keys)-len(found))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return items, nil
+	}
+
+	loaded, err := c.loadMulti(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.setCache(ctx, loaded, c.ttl); err != nil {
+		return nil, err
+	}
+
+	return append(items, loaded...), nil
+}
+
+// SetMulti saves provided k/v pairs to the store one by one, then puts them to the cache
+//
+// Stops at the first store error, the pairs saved before it are not cached
+func (c *Cache[T]) SetMulti(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	if err := c.save(ctx, kvs); err != nil {
+		return err
+	}
+
+	return c.setCache(ctx, kvs, c.ttl)
+}
+
+// SetMultiWithTTL saves provided k/v pairs to the store one by one, then puts them to the cache using provided ttl
+// duration
+//
+// Stops at the first store error, the pairs saved before it are not cached
+func (c *Cache[T]) SetMultiWithTTL(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl time.Duration) error {
+	if err := c.save(ctx, kvs); err != nil {
+		return err
+	}
+
+	return c.setCache(ctx, kvs, &ttl)
+}
+
+// Delete removes the value by key from the store, then from the cache
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	return c.DeleteMulti(ctx, []string{key})
+}
+
+// DeleteMulti removes the values by keys from the store one by one, then from the cache
+//
+// Stops at the first store error, the keys deleted before it are still removed from the cache
+func (c *Cache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	for i, key := range keys {
+		if err := c.store.Delete(ctx, key); err != nil {
+			return errors.Join(
+				fmt.Errorf("could not delete key %s from store: %w", key, err),
+				c.cache.DeleteMulti(ctx, keys[:i]),
+			)
+		}
+	}
+
+	return c.cache.DeleteMulti(ctx, keys)
+}
+
+// load returns the fetcher loading the value by key from the store
+func (c *Cache[T]) load(key string) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		return c.store.Load(ctx, key)
+	}
+}
+
+func (c *Cache[T]) loadMulti(ctx context.Context, keys []string) ([]cache.StorageItemMulti[T], error) {
+	if loader, ok := c.store.(MultiLoader[T]); ok {
+		return loader.LoadMulti(ctx, keys)
+	}
+
+	items := make([]cache.StorageItemMulti[T], 0, len(keys))
+	for _, key := range keys {
+		value, err := c.store.Load(ctx, key)
+		if errors.Is(err, cache.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not load key %s from store: %w", key, err)
+		}
+
+		items = append(items, cache.StorageItemMulti[T]{Key: key, Value: value})
+	}
+
+	return items, nil
+}
+
+func (c *Cache[T]) save(ctx context.Context, kvs []cache.StorageItemMulti[T]) error {
+	for _, kv := range kvs {
+		if err := c.store.Save(ctx, kv.Key, kv.Value); err != nil {
+			return fmt.Errorf("could not save key %s to store: %w", kv.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// setCache puts the values to the cache, deleting them from it if the write fails
+func (c *Cache[T]) setCache(ctx context.Context, kvs []cache.StorageItemMulti[T], ttl *time.Duration) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	var err error
+	if ttl != nil {
+		err = c.cache.SetMultiWithTTL(ctx, kvs, *ttl)
+	} else {
+		err = c.cache.SetMulti(ctx, kvs)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+
+	return errors.Join(fmt.Errorf("could not cache values: %w", err), c.cache.DeleteMulti(ctx, keys))
+}
+
+// isMiss reports whether the read failed because the entry is absent
+func isMiss(err error) bool {
+	var missingEntryError cache.MissingEntryError
+	return errors.As(err, &missingEntryError)
+}